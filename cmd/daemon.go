@@ -24,7 +24,8 @@ import (
 	"github.com/mlayerprotocol/go-mlayer/internal/chain/api"
 	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
 
-	// "github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/service"
 	"github.com/mlayerprotocol/go-mlayer/common/constants"
 	"github.com/mlayerprotocol/go-mlayer/pkg/core/sql"
 	"github.com/mlayerprotocol/go-mlayer/pkg/log"
@@ -292,6 +293,12 @@ func daemonFunc(cmd *cobra.Command, _ []string) {
 	cfg.ArchiveDir = archiveDir
 
 	cfg.SyncHost, _ = cmd.Flags().GetString(string(SYNC_HOST))
+	if cfg.ConflictOrderBlock > 0 {
+		entities.BlockOrderActivationBlock = cfg.ConflictOrderBlock
+	}
+	if cfg.RequirePayloadNonce {
+		service.AllowLegacyNonce = false
+	}
 	
 
 	// ****** INITIALIZE CONTEXT ****** //
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/mlayerprotocol/go-mlayer/internal/service"
+	"github.com/mlayerprotocol/go-mlayer/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+const (
+	REFETCH Flag = "refetch"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Inspect the node's datastores",
+	Long: `Use this command to inspect and check the datastores of a running node:
+
+	mLayer (message layer) is an open, decentralized
+	communication network that enables the creation,
+	transmission and termination of data of all sizes,
+	leveraging modern protocols. mLayer is a comprehensive
+	suite of communication protocols designed to evolve with
+	the ever-advancing realm of cryptography.
+	Visit the mLayer [documentation](https://mlayer.gitbook.io/introduction/what-is-mlayer) to learn more
+	.`,
+}
+
+var dbVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the integrity of the stored event graph",
+	Long: `Walk the node's event store and check event signatures, hash linkage, event models
+and that every current state matches its latest event. Dangling references can be re-fetched from peers.
+The checks run inside the node, which holds the datastore lock, so the node must be running and
+the command must be run on the node's host:
+
+	mLayer (message layer) is an open, decentralized
+	communication network that enables the creation,
+	transmission and termination of data of all sizes,
+	leveraging modern protocols. mLayer is a comprehensive
+	suite of communication protocols designed to evolve with
+	the ever-advancing realm of cryptography.
+	Visit the mLayer [documentation](https://mlayer.gitbook.io/introduction/what-is-mlayer) to learn more
+	.`,
+	Run: dbVerifyFunc,
+}
+
+func init() {
+	dbVerifyCmd.Flags().StringP(string(REST_ADDRESS), "r", "", "Rest api address of the node. Defaults to the configured rest_address")
+	dbVerifyCmd.Flags().BoolP(string(REFETCH), "f", false, "Request dangling events from peers and queue them for processing")
+
+	dbCmd.AddCommand(dbVerifyCmd)
+	rootCmd.AddCommand(dbCmd)
+}
+
+func dbVerifyFunc(_cmd *cobra.Command, _args []string) {
+	restAddress, _ := _cmd.Flags().GetString(string(REST_ADDRESS))
+	refetch, _ := _cmd.Flags().GetBool(string(REFETCH))
+
+	report := service.IntegrityReport{}
+	if err := restRequest(http.MethodPost, restURL(restAddress, "/api/db/verify"), client.VerifyRequest{Refetch: refetch}, &report); err != nil {
+		logger.Fatal(err)
+	}
+	fmt.Printf("\nChecked %d events and %d states\n", report.Events, report.States)
+	if len(report.Issues) == 0 {
+		fmt.Println("No issues found")
+		return
+	}
+	fmt.Printf("\nCHECK  |  MODEL  |  EVENT  |  STATE  |  DETAIL [%d]\n", len(report.Issues))
+	fmt.Println("---------------------------------------------------------------------")
+	for _, issue := range report.Issues {
+		detail := issue.Detail
+		if issue.Ref != nil {
+			detail = fmt.Sprintf("%s (%s)", detail, issue.Ref.ToString())
+		}
+		if issue.Refetched {
+			detail = fmt.Sprintf("%s, refetched", detail)
+		}
+		fmt.Printf("%s  %s  %s  %s  %s\n", issue.Check, issue.Model, dashIfEmpty(issue.Event), dashIfEmpty(issue.State), detail)
+	}
+	fmt.Println()
+}
+
+func dashIfEmpty(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
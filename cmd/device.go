@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/common/encoder"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
+	"github.com/mlayerprotocol/go-mlayer/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+const (
+	ACCOUNT Flag = "account"
+	AGENT   Flag = "agent"
+	SUBNET  Flag = "subnet"
+)
+
+var deviceCmd = &cobra.Command{
+	Use:   "device",
+	Short: "List and revoke the agents (devices) authorized by an account",
+	Long: `Use this command to view and revoke the agents an account has authorized across subnets:
+
+	mLayer (message layer) is an open, decentralized
+	communication network that enables the creation,
+	transmission and termination of data of all sizes,
+	leveraging modern protocols. mLayer is a comprehensive
+	suite of communication protocols designed to evolve with
+	the ever-advancing realm of cryptography.
+	Visit the mLayer [documentation](https://mlayer.gitbook.io/introduction/what-is-mlayer) to learn more
+	.`,
+}
+
+var deviceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all agents authorized by an account",
+	Long: `List every agent an account has authorized, with its priviledge, expiry and when it was last seen:
+
+	mLayer (message layer) is an open, decentralized
+	communication network that enables the creation,
+	transmission and termination of data of all sizes,
+	leveraging modern protocols. mLayer is a comprehensive
+	suite of communication protocols designed to evolve with
+	the ever-advancing realm of cryptography.
+	Visit the mLayer [documentation](https://mlayer.gitbook.io/introduction/what-is-mlayer) to learn more
+	.`,
+	Run: deviceListFunc,
+}
+
+var deviceRevokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Revoke agents authorized by an account",
+	Long: `Sign and submit revocations for one or all agents of an account, for example when a device is lost:
+
+	mLayer (message layer) is an open, decentralized
+	communication network that enables the creation,
+	transmission and termination of data of all sizes,
+	leveraging modern protocols. mLayer is a comprehensive
+	suite of communication protocols designed to evolve with
+	the ever-advancing realm of cryptography.
+	Visit the mLayer [documentation](https://mlayer.gitbook.io/introduction/what-is-mlayer) to learn more
+	.`,
+	Run: deviceRevokeFunc,
+}
+
+func init() {
+	for _, c := range []*cobra.Command{deviceListCmd, deviceRevokeCmd} {
+		c.Flags().StringP(string(ACCOUNT), "a", "", "The account (did) that authorized the agents")
+		c.Flags().StringP(string(REST_ADDRESS), "r", "", "Rest api address of the node. Defaults to the configured rest_address")
+		c.Flags().StringP(string(SUBNET), "s", "", "Only include agents in this subnet")
+	}
+	deviceRevokeCmd.Flags().StringP(string(PRIVATE_KEY), "k", "", "The account's ethereum private key used to sign the revocations")
+	deviceRevokeCmd.Flags().StringP(string(AGENT), "g", "", "The agent to revoke. All agents are revoked when not set")
+
+	deviceCmd.AddCommand(deviceListCmd)
+	deviceCmd.AddCommand(deviceRevokeCmd)
+	rootCmd.AddCommand(deviceCmd)
+}
+
+func restURL(address string, path string) string {
+	if address == "" {
+		address = configs.Config.RestAddress
+	}
+	if !strings.HasPrefix(address, "http://") && !strings.HasPrefix(address, "https://") {
+		address = fmt.Sprintf("http://%s", address)
+	}
+	return fmt.Sprintf("%s%s", strings.TrimSuffix(address, "/"), path)
+}
+
+func restRequest(method string, url string, body any, data any) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	response := entities.ClientResponse{Data: data}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return err
+	}
+	if response.Error != "" {
+		return errors.New(response.Error)
+	}
+	return nil
+}
+
+func getAccountDevices(_cmd *cobra.Command) (string, []client.AccountDevice) {
+	account, _ := _cmd.Flags().GetString(string(ACCOUNT))
+	if account == "" {
+		logger.Fatal("Account is required")
+	}
+	restAddress, _ := _cmd.Flags().GetString(string(REST_ADDRESS))
+	subnet, _ := _cmd.Flags().GetString(string(SUBNET))
+	devices := []client.AccountDevice{}
+	if err := restRequest(http.MethodGet, restURL(restAddress, fmt.Sprintf("/api/accounts/%s/devices", account)), nil, &devices); err != nil {
+		logger.Fatal(err)
+	}
+	filtered := []client.AccountDevice{}
+	for _, device := range devices {
+		if subnet == "" || device.Subnet == subnet {
+			filtered = append(filtered, device)
+		}
+	}
+	return account, filtered
+}
+
+func formatMilli(ts uint64) string {
+	if ts == 0 {
+		return "-"
+	}
+	return time.UnixMilli(int64(ts)).Format(time.RFC3339)
+}
+
+func deviceListFunc(_cmd *cobra.Command, _args []string) {
+	_, devices := getAccountDevices(_cmd)
+	fmt.Printf("\nS/N   |  AGENT  |  SUBNET  |  PRIVILEDGE  |  EXPIRES  |  LAST SEEN [%d]\n", len(devices))
+	fmt.Println("---------------------------------------------------------------------")
+	if len(devices) == 0 {
+		println("0 authorized")
+	}
+	for i, device := range devices {
+		expiry := formatMilli(device.ExpiresAt)
+		if device.Expired {
+			expiry = "expired"
+		}
+		fmt.Printf("%d     %s  %s  %d  %s  %s\n", i+1, device.Agent, device.Subnet, device.Priviledge, expiry, formatMilli(device.LastSeen))
+	}
+	fmt.Println()
+}
+
+/*
+Build a revocation payload signed by the account for the agent
+*/
+func signRevocation(privateKey string, account string, device client.AccountDevice, info *client.NodeInfo) (entities.ClientPayload, error) {
+	now := uint64(time.Now().UnixMilli())
+	priviledge := constants.UnauthorizedPriviledge
+	duration := uint64(0)
+	auth := entities.Authorization{
+		Account:    entities.AddressFromString(account).ToDIDString(),
+		Grantor:    entities.AddressFromString(account).ToDIDString(),
+		Agent:      device.Agent,
+		Subnet:     device.Subnet,
+		Priviledge: &priviledge,
+		Duration:   &duration,
+		Timestamp:  &now,
+	}
+	payload := entities.ClientPayload{
+		Data:      auth,
+		Timestamp: now,
+		EventType: uint16(constants.UnauthorizationEvent),
+		Account:   auth.Account,
+		ChainId:   configs.ChainId(info.ChainId),
+		Validator: info.Account,
+		Subnet:    device.Subnet,
+	}
+	hash, err := payload.GetHash()
+	if err != nil {
+		return payload, err
+	}
+	authMsg := fmt.Sprintf(constants.SignatureMessageString, "write_authorization", entities.AddressFromString(string(device.Agent)).Addr, info.ChainId, encoder.ToBase64Padded(hash))
+	_, authSig := crypto.SignECC(crypto.EthMessage([]byte(authMsg)), privateKey)
+	auth.SignatureData = entities.SignatureData{Type: entities.EthereumPubKey, Signature: authSig}
+	payload.Data = auth
+
+	b, err := payload.EncodeBytes()
+	if err != nil {
+		return payload, err
+	}
+	_, payload.Signature = crypto.SignECC(b, privateKey)
+	return payload, nil
+}
+
+func deviceRevokeFunc(_cmd *cobra.Command, _args []string) {
+	account, devices := getAccountDevices(_cmd)
+	privateKey, _ := _cmd.Flags().GetString(string(PRIVATE_KEY))
+	if privateKey == "" {
+		logger.Fatal("Account private key is required to sign revocations")
+	}
+	privateKey = strings.TrimPrefix(privateKey, "0x")
+	agent, _ := _cmd.Flags().GetString(string(AGENT))
+	restAddress, _ := _cmd.Flags().GetString(string(REST_ADDRESS))
+
+	info := client.NodeInfo{}
+	if err := restRequest(http.MethodGet, restURL(restAddress, "/api/info"), nil, &info); err != nil {
+		logger.Fatal(err)
+	}
+	payloads := []entities.ClientPayload{}
+	for _, device := range devices {
+		if agent != "" && entities.AddressFromString(agent).Addr != entities.AddressFromString(string(device.Agent)).Addr {
+			continue
+		}
+		payload, err := signRevocation(privateKey, account, device, &info)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		payloads = append(payloads, payload)
+	}
+	if len(payloads) == 0 {
+		fmt.Println("No matching agents to revoke")
+		return
+	}
+	results := []client.DeviceRevocation{}
+	if err := restRequest(http.MethodPost, restURL(restAddress, fmt.Sprintf("/api/accounts/%s/devices/revoke", account)), payloads, &results); err != nil {
+		logger.Fatal(err)
+	}
+	for _, result := range results {
+		if result.Error != "" {
+			fmt.Printf("%s  %s  failed: %s\n", result.Agent, result.Subnet, result.Error)
+			continue
+		}
+		fmt.Printf("%s  %s  revoked\n", result.Agent, result.Subnet)
+	}
+}
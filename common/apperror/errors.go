@@ -18,6 +18,7 @@ const (
     BadRequestError ErrorCode = 4003
     InternalError ErrorCode = 5000
     NotFoundError ErrorCode = 4004
+    TooManyRequestsError ErrorCode = 4029
 )
 
 func Unauthorized(message string) error {
@@ -39,7 +40,22 @@ func BadRequest(message string) error {
     return fmt.Errorf("%d: %s", BadRequestError, message)
 }
 
+func TooManyRequests(message string) error {
+    message = strings.ToLower(message)
+    return fmt.Errorf("%d: %s", TooManyRequestsError, message)
+}
+
 func Internal(message string) error {
     message = strings.ToLower(message)
     return fmt.Errorf("%d: %s", InternalError, message)
-}
\ No newline at end of file
+}
+// Parse splits an error created by this package into its code and message. Other errors have no code
+func Parse(err error) (ErrorCode, string) {
+    var code int
+    var message string
+    if _, scanErr := fmt.Sscanf(err.Error(), "%d: ", &code); scanErr == nil {
+        message = strings.TrimPrefix(err.Error(), fmt.Sprintf("%d: ", code))
+        return ErrorCode(code), message
+    }
+    return 0, err.Error()
+}
@@ -53,6 +53,7 @@ type ChannelId string
 const (
 	ConfigKey                       ChannelId = "Config"
 	SQLDB                         ChannelId  = "sqldb"
+	EventBatchKey                 ChannelId  = "EventBatch"
 )
 
 // State store key
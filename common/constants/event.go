@@ -50,11 +50,14 @@ const (
 	// UpdateAvatarEvent      EventType = 1008 //  m.room.avatar
 	// PinMessageEvent        EventType = 1008 //  m.room.avatar
 	UpdateSubnetEvent EventType = 509
+	TransferSubnetOwnershipEvent EventType = 510 // offered by the current owner
+	AcceptSubnetOwnershipEvent   EventType = 511 // accepted by the new owner
 	// UpgradeSubscriberEvent EventType = 1010
 )
 const (
 	AuthorizationEvent   EventType = 600
 	UnauthorizationEvent EventType = 601
+	RenewAuthorizationEvent EventType = 602
 )
 
 // Administrative Topic Actions
@@ -71,6 +74,8 @@ const (
 	PinMessageEvent        EventType = 1008 //  m.room.avatar
 	UpdateTopicEvent       EventType = 1009
 	UpgradeSubscriberEvent EventType = 1010
+	TransferTopicOwnershipEvent EventType = 1011 // offered by the current owner
+	AcceptTopicOwnershipEvent   EventType = 1012 // accepted by the new owner
 )
 
 // Subscription Actions
@@ -98,4 +103,8 @@ const (
 	CreateWalletEvent EventType = 1401 // m.room.create
 
 	UpdateWalletEvent EventType = 1409
+
+	MintTokenEvent     EventType = 1410 // wallet owner only
+	TransferTokenEvent EventType = 1411
+	BurnTokenEvent     EventType = 1412
 )
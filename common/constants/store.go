@@ -26,4 +26,5 @@ const (
 	P2PDhtStore                 DataStore		= "p2p-data-store"
 	SystemStore                 DataStore		= "system-store"
 	NetworkStatsStore                 DataStore		= "network-stats-store"
+	WebhookStore                 DataStore		= "webhook-store"
 )
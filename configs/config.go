@@ -118,6 +118,8 @@ type MainConfiguration struct {
 	PrivateKey               string         `toml:"private_key"`
 	EvmRpcConfig			 map[string]EthConfig `toml:"evm_rpc"`
 	QuicHost                 string         `toml:"quic_host"`
+	ConflictOrderBlock       uint64         `toml:"conflict_order_block"`
+	RequirePayloadNonce      bool           `toml:"require_payload_nonce"` // rejects payloads with nonce 0, to be removed once nonces are always required
 	// PublicKey        string
 	OperatorAddress          string
 	
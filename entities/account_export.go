@@ -0,0 +1,35 @@
+package entities
+
+import (
+	"github.com/mlayerprotocol/go-mlayer/common/encoder"
+	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
+)
+
+// AccountExportRequest is signed by an account to download everything the node holds about it
+type AccountExportRequest struct {
+	Account       DIDString     `json:"acct" binding:"required"`
+	Timestamp     uint64        `json:"ts" binding:"required"`
+	SignatureData SignatureData `json:"sigD"`
+}
+
+// AccountExportRecord is one line of an export bundle
+type AccountExportRecord struct {
+	Model EntityModel `json:"model"`
+	State interface{} `json:"state"`
+	Event *Event      `json:"event,omitempty"`
+}
+
+func (r AccountExportRequest) EncodeBytes() ([]byte, error) {
+	return encoder.EncodeBytes(
+		encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: r.Account},
+		encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: r.Timestamp},
+	)
+}
+
+func (r AccountExportRequest) GetHash() ([]byte, error) {
+	b, err := r.EncodeBytes()
+	if err != nil {
+		return []byte(""), err
+	}
+	return crypto.Sha256(b), nil
+}
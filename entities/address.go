@@ -62,6 +62,20 @@ func (address DID) ToDIDString() DIDString {
 	return DIDString(address.ToString())
 }
 
+// Platform infers the wallet platform from the format of the address
+func (address DID) Platform() string {
+	switch {
+	case strings.HasPrefix(address.Addr, "0x"):
+		return PlatformEthereum
+	case strings.HasPrefix(address.Addr, "cosmos1"):
+		return PlatformCosmos
+	case crypto.IsBitcoinAddress(address.Addr):
+		return PlatformBitcoin
+	case crypto.IsSolanaAddress(address.Addr):
+		return PlatformSolana
+	}
+	return ""
+}
 
 func StringToDeviceString(str string) (DeviceString) {
 	return AddressFromString(str).ToDeviceString()
@@ -4,8 +4,10 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/mlayerprotocol/go-mlayer/common/constants"
 	"github.com/mlayerprotocol/go-mlayer/common/encoder"
 	"github.com/mlayerprotocol/go-mlayer/common/utils"
@@ -17,6 +19,11 @@ type PubKeyType string
 const (
 	TendermintsSecp256k1PubKey PubKeyType = "tendermint/PubKeySecp256k1"
 	EthereumPubKey             PubKeyType = "eth"
+	SolanaPubKey               PubKeyType = "sol"
+	BitcoinPubKey              PubKeyType = "btc"
+	BitcoinBIP322PubKey        PubKeyType = "bip322"
+	EIP712PubKey               PubKeyType = "eip712"
+	EIP1271PubKey              PubKeyType = "eip1271"
 )
 
 type Authorization struct {
@@ -26,6 +33,7 @@ type Authorization struct {
 	Account       DIDString                        	`json:"acct" gorm:"varchar(40);"`
 	Grantor       DIDString                        	`json:"gr" gorm:"index"`
 	Priviledge    *constants.AuthorizationPrivilege	`json:"privi"  gorm:""`
+	// TopicIds is a comma separated list of the topics the agent may act on. Empty or "*" means all topics
 	TopicIds      string                           	`json:"topIds"`
 	Timestamp     *uint64                           `json:"ts"`
 	Duration      *uint64                           `json:"du"`
@@ -74,6 +82,42 @@ func (g Authorization) ToString() (string, error) {
 
 
 
+// ParseTopicIds splits a TopicIds value into its topic ids. It returns nil when all topics are allowed
+func ParseTopicIds(topicIds string) ([]string, error) {
+	if topicIds == "" || topicIds == "*" {
+		return nil, nil
+	}
+	ids := []string{}
+	for _, id := range strings.Split(topicIds, ",") {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, fmt.Errorf("invalid topic id %q", id)
+		}
+		if slices.Contains(ids, id) {
+			return nil, fmt.Errorf("duplicate topic id %q", id)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// TopicIdList returns the topics the authorization is scoped to, nil if it covers the whole subnet
+func (g Authorization) TopicIdList() []string {
+	ids, _ := ParseTopicIds(g.TopicIds)
+	return ids
+}
+
+func (g Authorization) CanActOnTopic(topic string) bool {
+	if g.TopicIds == "" || g.TopicIds == "*" {
+		return true
+	}
+	return slices.Contains(g.TopicIdList(), topic)
+}
+
+// IsRevoked reports whether the authorization no longer grants any priviledge
+func (g Authorization) IsRevoked() bool {
+	return g.Priviledge != nil && *g.Priviledge == constants.UnauthorizedPriviledge
+}
+
 func (g *Authorization) GetKeys() (keys []string)  {
 	if g.ID == "" {
 		g.ID, _ = GetId(g, "")
@@ -83,6 +127,10 @@ func (g *Authorization) GetKeys() (keys []string)  {
 	 keys = append(keys, fmt.Sprintf("%s/%s", g.AccountAuthorizationsKey(), utils.IntMilliToTimestampString(int64(*g.Timestamp))))
 	 keys = append(keys, g.Key())
 	 keys = append(keys, g.DataKey())
+	 keys = append(keys, fmt.Sprintf("%s/%s/%s", g.HistoryKey(), utils.IntMilliToTimestampString(int64(*g.Timestamp)), g.Event.ID))
+	 if g.ExpiresAt() > 0 {
+		keys = append(keys, g.ExpiryQueueKey())
+	 }
 	 if (g.Account != g.Grantor) {
 		keys = append(keys, fmt.Sprintf("%s/%s/%s/%s", AuthModel, g.Grantor, g.Subnet, g.ID))
 	 }
@@ -115,6 +163,38 @@ func (g *Authorization) AccountAuthorizationsKey() (string) {
 	}
 }
 
+// HistoryKey indexes every grant and revocation of an account's agents. Unlike AccountAuthorizationsKey it is never pruned
+func (g *Authorization) HistoryKey() (string) {
+	if (g.Subnet != "") {
+		if g.Agent != ""  {
+			return fmt.Sprintf("%s/hist/%s/%s/%s", AuthModel, g.Account, g.Subnet, g.Agent)
+		}
+		return fmt.Sprintf("%s/hist/%s/%s", AuthModel, g.Account, g.Subnet)
+	}
+	return fmt.Sprintf("%s/hist/%s", AuthModel, g.Account)
+}
+
+// ExpiresAt returns the unix milli time the authorization lapses, 0 if it never does
+func (g Authorization) ExpiresAt() uint64 {
+	if g.Timestamp == nil || g.Duration == nil || *g.Duration == 0 {
+		return 0
+	}
+	return *g.Timestamp + *g.Duration
+}
+
+// ExpiryQueueKey orders timed authorizations by the zero padded unix milli time they expire, so the sweeper can stop at the first one still valid
+func (g *Authorization) ExpiryQueueKey() string {
+	return fmt.Sprintf("%s/%020d/%s", AuthorizationExpiryQueueKey(), g.ExpiresAt(), g.Event.ID)
+}
+
+func AuthorizationExpiryQueueKey() string {
+	return fmt.Sprintf("%s/expq", AuthModel)
+}
+
+func AuthorizationExpiryStatusKey(eventId string) string {
+	return fmt.Sprintf("%s/exst/%s", AuthModel, eventId)
+}
+
 func AccountAuthorizationsKeyToAuthorization(key string) (*Authorization, error) {
 	parts := strings.Split(key, "/")
 	if len(parts) > 3 {
@@ -127,7 +207,7 @@ func AccountAuthorizationsKeyToAuthorization(key string) (*Authorization, error)
 	return auth, nil
 }
 func (item *Authorization) ToAccountAuthKey() string {
-	return fmt.Sprintf("%s/%s/%s", item.Subnet, item)
+	return fmt.Sprintf("%s/%s/%s", item.Subnet, item.Account, item.Agent)
 }
 
 func (item *Authorization) Key() string {
@@ -38,12 +38,12 @@ func (c *WsClientLog) RegisterClient(subscription *ClientWsSubscription) {
 		for _, _type := range val {
 			if _type == "*" {
 				for _, t := range eventModelsAsByte {
-					logger.Debugf("REGISTERING: %s", snet, string(t) )
+					logger.Debugf("REGISTERING: %s, %s", snet, string(t) )
 					keys = append(keys, murmur3.Sum64(append(utils.UuidToBytes(snet), t...)))
 				}
 				
 			} else {
-				logger.Debugf("REGISTERING: %s", snet, string(_type) )
+				logger.Debugf("REGISTERING: %s, %s", snet, string(_type) )
 				keys = append(keys, murmur3.Sum64(append(utils.UuidToBytes(snet), []byte(_type)...)))
 			}
 		}
@@ -69,7 +69,7 @@ func (c *WsClientLog) RegisterClient(subscription *ClientWsSubscription) {
 		// wsClients[key][subscription.Conn] = subscription.Account
 	}
 	c.mutex.Unlock()
-	logger.Debugf("Received PAYLOAD FILTEr: %v, %v", c.Clients)
+	logger.Debugf("Received PAYLOAD FILTEr: %v", c.Clients)
 }
 
 func (c *WsClientLog) RemoveClient(conn *websocket.Conn) {
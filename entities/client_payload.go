@@ -67,9 +67,12 @@ type ClientPayload struct {
 	Validator string `json:"val,omitempty"`
 	// Secondary																								 	AA	`							qaZAA	`q1aZaswq21``		`	`
 	Signature string       `json:"sig"`
+	SignatureType PubKeyType `json:"sigTy,omitempty"` // eip712 when the agent signed typed data
 	Hash      string       `json:"h,omitempty"`
 	Agent     DeviceString `gorm:"-" json:"agt"`
 	Subnet    string       `json:"snet" gorm:"index;"`
+	Approvals []ProposalApproval `json:"apprv,omitempty" gorm:"-"` // subnet admin approvals. Not part of the signed bytes
+	Version   uint16       `json:"v,omitempty" gorm:"-"` // schema version of Data. Not part of the signed bytes
 	Page      uint16       `json:"page,omitempty" gorm:"_"`
 	PerPage   uint16       `json:"perPage,omitempty" gorm:"_"`
 }
@@ -131,7 +134,7 @@ func (msg ClientPayload) GetHash() ([]byte, error) {
 
 func (msg *ClientPayload) GetSigner() (DeviceString, error) {
 
-		b, err := msg.EncodeBytes()
+		b, err := msg.SigningBytes()
 		if err != nil {
 			return "", err
 		}
@@ -141,6 +144,21 @@ func (msg *ClientPayload) GetSigner() (DeviceString, error) {
 		return msg.Agent, nil
 }
 
+// SigningBytes returns the bytes whose keccak256 hash the agent signed
+func (msg ClientPayload) SigningBytes() ([]byte, error) {
+	if msg.SignatureType == EIP712PubKey {
+		return msg.EIP712Bytes()
+	}
+	return msg.EncodeBytes()
+}
+
+// SignatureDataMessage returns the message an account signature of the given type covers
+func (msg ClientPayload) SignatureDataMessage(sigType PubKeyType) ([]byte, error) {
+	if sigType == EIP712PubKey {
+		return msg.EIP712Bytes()
+	}
+	return msg.GetHash()
+}
 
 func (msg ClientPayload) EncodeBytes() ([]byte, error) {
 	hashed := []byte("")
@@ -177,6 +195,26 @@ func (msg ClientPayload) EncodeBytes() ([]byte, error) {
 	)
 }
 
+// AgentNonceKey holds the highest nonce accepted from an agent acting for an account
+func AgentNonceKey(account DIDString, agent DeviceString) string {
+	return fmt.Sprintf("nonce/%s/%s", strings.ToLower(AddressFromString(string(account)).Addr), strings.ToLower(AddressFromString(string(agent)).Addr))
+}
+
+// DeviceNonceKey holds the highest nonce accepted from an agent, whichever account it acted for
+func DeviceNonceKey(agent DeviceString) string {
+	return fmt.Sprintf("nonce/agt/%s", strings.ToLower(AddressFromString(string(agent)).Addr))
+}
+
+// AccountNonceKey holds the highest nonce accepted for an account, whichever of its agents signed it
+func AccountNonceKey(account DIDString) string {
+	return fmt.Sprintf("nonce/acct/%s", strings.ToLower(AddressFromString(string(account)).Addr))
+}
+
+// AgentNonceUseKey holds the id of the event that used an agents nonce for an account
+func AgentNonceUseKey(account DIDString, agent DeviceString, nonce uint64) string {
+	return fmt.Sprintf("nonceuse/%s/%s/%d", strings.ToLower(AddressFromString(string(account)).Addr), strings.ToLower(AddressFromString(string(agent)).Addr), nonce)
+}
+
 func ClientPayloadFromBytes(b []byte) (ClientPayload, error) {
 	var message ClientPayload
 	err := json.Unmarshal(b, &message)
@@ -0,0 +1,57 @@
+package entities
+
+import (
+	"encoding/hex"
+
+	"github.com/mlayerprotocol/go-mlayer/common/encoder"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
+)
+
+/*
+ClientPayloadBatch is an ordered list of client payloads signed by one agent as a single unit.
+Its payloads are applied all-or-nothing: either every one of them becomes an event or none does
+*/
+type ClientPayloadBatch struct {
+	Payloads  []ClientPayload `json:"pls"`
+	Timestamp uint64          `json:"ts"`
+	ChainId   configs.ChainId `json:"chId"`
+	Signature string          `json:"sig"`
+	Agent     DeviceString    `json:"agt,omitempty"`
+}
+
+// EncodeBytes covers the chain, the hash of every payload in order and the batch timestamp
+func (b ClientPayloadBatch) EncodeBytes() ([]byte, error) {
+	params := []encoder.EncoderParam{{Type: encoder.ByteEncoderDataType, Value: b.ChainId.Bytes()}}
+	for _, pl := range b.Payloads {
+		d, err := pl.EncodeBytes()
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, encoder.EncoderParam{Type: encoder.ByteEncoderDataType, Value: crypto.Keccak256Hash(d)})
+	}
+	params = append(params, encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: b.Timestamp})
+	return encoder.EncodeBytes(params...)
+}
+
+// Hash identifies the batch on the events created from it
+func (b ClientPayloadBatch) Hash() (string, error) {
+	d, err := b.EncodeBytes()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(crypto.Keccak256Hash(d)), nil
+}
+
+func (b *ClientPayloadBatch) GetSigner() (DeviceString, error) {
+	d, err := b.EncodeBytes()
+	if err != nil {
+		return "", err
+	}
+	agent, err := crypto.GetSignerECC(&d, &b.Signature)
+	if err != nil {
+		return "", err
+	}
+	b.Agent = AddressFromString(agent).ToDeviceString()
+	return b.Agent, nil
+}
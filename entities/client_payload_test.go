@@ -1,6 +1,7 @@
 package entities
 
 import (
+	"crypto/ed25519"
 	"encoding/hex"
 	"testing"
 
@@ -8,35 +9,48 @@ import (
 	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
 )
 
-// TestHelloName calls greetings.Hello with a name, checking
-// for a valid return value.
-func TestEncodeAuthorityBytes(t *testing.T) {
-	agent := "0xe652d28F89A28adb89e674a6b51852D0C341Ebe9"
-	grantor := "02ebec9d95769bb3d71712f0bf1e7e88b199fc945f67f908bbab81e9b7cb1092d8"
-	topicIds := "*"
+func testAuthorization() Authorization {
 	ts := uint64(1705392177894)
+	duration := uint64(0)
 	privilege := constants.AdminPriviledge
-    auth := Authorization{Agent: agent, Grantor: grantor,  TopicIds: topicIds, Timestamp: ts,  Priviledge: privilege, }
-	hash := hex.EncodeToString(auth.GetHash())
-	expected := "6be68630801cf55ba605dee655ed7742b6f7979706392b7691597c3f8210546c"
-    if hash != expected {
-        t.Fatalf("Hash should match \n%s \n%s", hash, expected )
-    }
+	return Authorization{
+		Account:    "did:0xe652d28F89A28adb89e674a6b51852D0C341Ebe9",
+		Agent:      "did:0x1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d",
+		Subnet:     "6f1c9a8e-3b2d-4c5e-9f10-2a3b4c5d6e7f",
+		TopicIds:   "*",
+		Timestamp:  &ts,
+		Duration:   &duration,
+		Priviledge: &privilege,
+	}
+}
+
+// TestEncodeAuthorityBytes guards the encoding grantors sign, changing it invalidates every signed authorization
+func TestEncodeAuthorityBytes(t *testing.T) {
+	hash, err := testAuthorization().GetHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "c996dd2ceb1927deaa2c25d81e6122961c7ed184de56d3eb5218f7e46c890bf9"
+	if hex.EncodeToString(hash) != expected {
+		t.Fatalf("Hash should match \n%s \n%s", hex.EncodeToString(hash), expected)
+	}
 }
 
 func TestVerifyAuthority(t *testing.T) {
-	agent := "0xe652d28F89A28adb89e674a6b51852D0C341Ebe9"
-	grantor := "02ebec9d95769bb3d71712f0bf1e7e88b199fc945f67f908bbab81e9b7cb1092d8"
-	topicIds := "*"
-	ts := uint64(1705392177894)
-	privilege := constants.AdminPriviledge
-    auth := Authorization{Agent: agent, TopicIds: topicIds, Timestamp: ts,  Priviledge: privilege, }
-	// hash := hex.EncodeToString(auth.GetHash())
-	
-	encoded, err := auth.EncodeBytes();
-	sign := "3044022073761e96c0d29d109ba2290fea1821bf093155d7549956a8144d04f9209f6c1b022048d4635f7e2f3e2864b2c1fe695badad548483ce1988f8bf0c04d5caf4131829"
-	valid, err := crypto.VerifySignatureEDD(grantor, &encoded, sign)
-    if !valid {
-        t.Fatalf("Invalid signature signer: %v", err )
-    }
-}
\ No newline at end of file
+	grantor := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	auth := testAuthorization()
+	encoded, err := auth.EncodeBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := ed25519.Sign(grantor, crypto.Sha256(encoded))
+	valid, err := crypto.VerifySignatureEDD(grantor.Public().(ed25519.PublicKey), &encoded, sign)
+	if !valid {
+		t.Fatalf("Invalid signature signer: %v", err)
+	}
+	*auth.Priviledge = constants.MemberPriviledge
+	encoded, _ = auth.EncodeBytes()
+	if valid, _ := crypto.VerifySignatureEDD(grantor.Public().(ed25519.PublicKey), &encoded, sign); valid {
+		t.Fatal("signature should not verify a changed priviledge")
+	}
+}
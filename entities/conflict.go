@@ -0,0 +1,132 @@
+package entities
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mlayerprotocol/go-mlayer/common/encoder"
+)
+
+// ConflictRule is the field that decided between two conflicting events
+type ConflictRule string
+
+const (
+	BlockNumberRule ConflictRule = "block"
+	TimestampRule   ConflictRule = "timestamp"
+	HashRule        ConflictRule = "hash"
+)
+
+/*
+BlockOrderActivationBlock is the block from which conflicting events are ordered by block first.
+Conflicts between two events from earlier blocks keep the timestamp first order of older releases,
+so every validator must be upgraded before the network sets it (conflict_order_block)
+*/
+var BlockOrderActivationBlock uint64 = math.MaxUint64
+
+/*
+EventOrder is what the tie-break between two conflicting events looks at.
+Hash is the events id, the one identifier events and the states referencing them both have
+*/
+type EventOrder struct {
+	Event       EventPath `json:"e"`
+	BlockNumber uint64    `json:"blk"`
+	Timestamp   uint64    `json:"ts"`
+	Hash        string    `json:"h"`
+}
+
+func (e *Event) Order() EventOrder {
+	order := EventOrder{BlockNumber: e.BlockNumber, Timestamp: e.Timestamp}
+	if path := e.GetPath(); path != nil {
+		order.Event = *path
+		order.Hash = path.ID
+	}
+	return order
+}
+
+func (a *Authorization) Order() EventOrder {
+	order := EventOrder{Event: a.Event, BlockNumber: a.BlockNumber, Hash: a.Event.ID}
+	if a.Timestamp != nil {
+		order.Timestamp = *a.Timestamp
+	}
+	return order
+}
+
+func (t *TokenTransaction) Order() EventOrder {
+	return EventOrder{Event: t.Event, BlockNumber: t.BlockNumber, Timestamp: t.Timestamp, Hash: t.Event.ID}
+}
+
+/*
+CompareEventOrder orders conflicting events by block number, then timestamp, then hash.
+The block number is skipped only when both events are before BlockOrderActivationBlock,
+so an event before it always comes first and the order stays transitive across the activation.
+It returns -1 when a comes first, 1 when b does and 0 for the same event, along with the rule that decided
+*/
+func CompareEventOrder(a EventOrder, b EventOrder) (int, ConflictRule) {
+	blockFirst := a.BlockNumber >= BlockOrderActivationBlock || b.BlockNumber >= BlockOrderActivationBlock
+	switch {
+	case blockFirst && a.BlockNumber < b.BlockNumber:
+		return -1, BlockNumberRule
+	case blockFirst && a.BlockNumber > b.BlockNumber:
+		return 1, BlockNumberRule
+	case a.Timestamp < b.Timestamp:
+		return -1, TimestampRule
+	case a.Timestamp > b.Timestamp:
+		return 1, TimestampRule
+	case a.Hash < b.Hash:
+		return -1, HashRule
+	case a.Hash > b.Hash:
+		return 1, HashRule
+	}
+	return 0, HashRule
+}
+
+// Key sorts orders the way CompareEventOrder does, for stores that apply events in that order
+func (o EventOrder) Key() string {
+	if o.BlockNumber >= BlockOrderActivationBlock {
+		return fmt.Sprintf("1/%020d/%020d/%s", o.BlockNumber, o.Timestamp, o.Hash)
+	}
+	return fmt.Sprintf("0/%020d/%s", o.Timestamp, o.Hash)
+}
+
+// EventConflict records an event that lost to a conflicting event updating the same entity
+type EventConflict struct {
+	Model     EntityModel  `json:"mod"`
+	ID        string       `json:"id"`
+	Winner    EventOrder   `json:"win"`
+	Loser     EventOrder   `json:"lose"`
+	Rule      ConflictRule `json:"rule"`
+	Reason    string       `json:"reason,omitempty"`
+	Timestamp uint64       `json:"ts"`
+}
+
+// NewEventConflict resolves the conflict between events a and b updating the same entity
+func NewEventConflict(model EntityModel, id string, a EventOrder, b EventOrder, reason string) EventConflict {
+	cmp, rule := CompareEventOrder(a, b)
+	conflict := EventConflict{Model: model, ID: id, Winner: b, Loser: a, Rule: rule, Reason: reason, Timestamp: uint64(time.Now().UnixMilli())}
+	if cmp > 0 {
+		conflict.Winner, conflict.Loser = a, b
+	}
+	return conflict
+}
+
+func (c *EventConflict) MsgPack() []byte {
+	b, _ := encoder.MsgPackStruct(c)
+	return b
+}
+
+func UnpackEventConflict(b []byte) (EventConflict, error) {
+	var c EventConflict
+	err := encoder.MsgPackUnpackStruct(b, &c)
+	return c, err
+}
+
+// EntityConflictsKey prefixes the conflicts recorded for an entity
+func EntityConflictsKey(model EntityModel, id string) string {
+	return fmt.Sprintf("conf/%s/%s", model, id)
+}
+
+// Key sorts an entities conflicts by the time they were recorded
+func (c *EventConflict) Key() string {
+	return fmt.Sprintf("%s/%020d/%s", EntityConflictsKey(c.Model, c.ID), c.Timestamp, c.Loser.Event.ID)
+}
@@ -0,0 +1,99 @@
+package entities
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func withBlockOrderActivation(t *testing.T, block uint64) {
+	previous := BlockOrderActivationBlock
+	BlockOrderActivationBlock = block
+	t.Cleanup(func() { BlockOrderActivationBlock = previous })
+}
+
+func TestCompareEventOrder(t *testing.T) {
+	withBlockOrderActivation(t, 100)
+	cases := []struct {
+		name string
+		a, b EventOrder
+		cmp  int
+		rule ConflictRule
+	}{
+		{"earlier block first", EventOrder{BlockNumber: 100, Timestamp: 20, Hash: "b"}, EventOrder{BlockNumber: 101, Timestamp: 10, Hash: "a"}, -1, BlockNumberRule},
+		{"later block last", EventOrder{BlockNumber: 102, Timestamp: 10}, EventOrder{BlockNumber: 101, Timestamp: 20}, 1, BlockNumberRule},
+		{"same block by timestamp", EventOrder{BlockNumber: 101, Timestamp: 10, Hash: "b"}, EventOrder{BlockNumber: 101, Timestamp: 20, Hash: "a"}, -1, TimestampRule},
+		{"same timestamp by hash", EventOrder{BlockNumber: 101, Timestamp: 10, Hash: "b"}, EventOrder{BlockNumber: 101, Timestamp: 10, Hash: "a"}, 1, HashRule},
+		{"same event", EventOrder{BlockNumber: 101, Timestamp: 10, Hash: "a"}, EventOrder{BlockNumber: 101, Timestamp: 10, Hash: "a"}, 0, HashRule},
+		{"before activation by timestamp", EventOrder{BlockNumber: 98, Timestamp: 20}, EventOrder{BlockNumber: 99, Timestamp: 10}, 1, TimestampRule},
+		{"across activation by block", EventOrder{BlockNumber: 99, Timestamp: 20}, EventOrder{BlockNumber: 100, Timestamp: 10}, -1, BlockNumberRule},
+	}
+	for _, c := range cases {
+		cmp, rule := CompareEventOrder(c.a, c.b)
+		if cmp != c.cmp || rule != c.rule {
+			t.Errorf("%s: got %d by %s, expected %d by %s", c.name, cmp, rule, c.cmp, c.rule)
+		}
+		if reverse, _ := CompareEventOrder(c.b, c.a); reverse != -c.cmp {
+			t.Errorf("%s: reversed comparison gave %d", c.name, reverse)
+		}
+	}
+}
+
+func TestCompareEventOrderNotActivated(t *testing.T) {
+	withBlockOrderActivation(t, math.MaxUint64)
+	if cmp, rule := CompareEventOrder(EventOrder{BlockNumber: 1, Timestamp: 20}, EventOrder{BlockNumber: 2, Timestamp: 10}); cmp != 1 || rule != TimestampRule {
+		t.Fatalf("expected the legacy timestamp order, got %d by %s", cmp, rule)
+	}
+}
+
+func TestEventOrderKeySortsLikeCompareEventOrder(t *testing.T) {
+	withBlockOrderActivation(t, 100)
+	orders := []EventOrder{
+		{BlockNumber: 98, Timestamp: 30, Hash: "a"},
+		{BlockNumber: 99, Timestamp: 10, Hash: "b"},
+		{BlockNumber: 99, Timestamp: 10, Hash: "c"},
+		{BlockNumber: 100, Timestamp: 5, Hash: "d"},
+		{BlockNumber: 100, Timestamp: 20, Hash: "a"},
+		{BlockNumber: 101, Timestamp: 1, Hash: "e"},
+	}
+	for _, a := range orders {
+		for _, b := range orders {
+			cmp, _ := CompareEventOrder(a, b)
+			if key := strings.Compare(a.Key(), b.Key()); key != cmp {
+				t.Errorf("%+v and %+v: keys compare %d, CompareEventOrder %d", a, b, key, cmp)
+			}
+		}
+	}
+}
+
+func TestNewEventConflictWinner(t *testing.T) {
+	withBlockOrderActivation(t, 0)
+	earlier := EventOrder{BlockNumber: 1, Timestamp: 20, Hash: "e1"}
+	later := EventOrder{BlockNumber: 2, Timestamp: 10, Hash: "e2"}
+	for _, conflict := range []EventConflict{
+		NewEventConflict(TopicModel, "t1", earlier, later, ""),
+		NewEventConflict(TopicModel, "t1", later, earlier, ""),
+	} {
+		if conflict.Winner.Hash != "e2" || conflict.Loser.Hash != "e1" || conflict.Rule != BlockNumberRule {
+			t.Fatalf("expected the later event to win by block, got %+v", conflict)
+		}
+	}
+}
+
+// events, authorizations and token transactions are ordered by the same event id
+func TestEventOrderHashIsEventId(t *testing.T) {
+	event := Event{Payload: ClientPayload{Data: Topic{}}, Validator: "02ebec9d95769bb3d71712f0bf1e7e88b199fc945f67f908bbab81e9b7cb1092d8", Hash: "payloadhash", Signature: "0123456789abcdef0123456789abcdef0123456789abcdef", Timestamp: 10, BlockNumber: 1}
+	order := event.Order()
+	if order.Hash == "" || order.Hash != order.Event.ID {
+		t.Fatalf("expected the order hash to be the event id, got %q for %q", order.Hash, order.Event.ID)
+	}
+	ts := uint64(10)
+	auth := Authorization{Event: order.Event, BlockNumber: 1, Timestamp: &ts}
+	if cmp, _ := CompareEventOrder(order, auth.Order()); cmp != 0 {
+		t.Fatalf("expected an event and its authorization to be the same in the order, got %d", cmp)
+	}
+	token := TokenTransaction{Event: order.Event, BlockNumber: 1, Timestamp: 10}
+	if cmp, _ := CompareEventOrder(order, token.Order()); cmp != 0 {
+		t.Fatalf("expected an event and its token transaction to be the same in the order, got %d", cmp)
+	}
+}
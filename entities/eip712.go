@@ -0,0 +1,333 @@
+package entities
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/mlayerprotocol/go-mlayer/common/utils"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
+)
+
+const (
+	EIP712DomainName    = "mLayer"
+	EIP712DomainVersion = "1"
+)
+
+/*
+EIP712Payload is implemented by entities that can be signed as EIP-712 typed data,
+so wallets show the fields being signed instead of an opaque hash
+*/
+type EIP712Payload interface {
+	EIP712Type() string
+	EIP712Fields() []apitypes.Type
+	EIP712Message() apitypes.TypedDataMessage
+}
+
+func eip712Int[T ~uint8 | ~uint16 | ~uint32 | ~uint64](v T) *big.Int {
+	return new(big.Int).SetUint64(uint64(v))
+}
+
+// EIP712Domain binds typed signatures to the chain. Non numeric chain ids are bound through the salt
+func EIP712Domain(chainId configs.ChainId) apitypes.TypedDataDomain {
+	domain := apitypes.TypedDataDomain{
+		Name:    EIP712DomainName,
+		Version: EIP712DomainVersion,
+	}
+	if n, err := strconv.ParseUint(string(chainId), 10, 64); err == nil {
+		domain.ChainId = math.NewHexOrDecimal256(int64(n))
+	} else {
+		domain.Salt = hexutil.Encode(crypto.Keccak256Hash([]byte(chainId)))
+	}
+	return domain
+}
+
+func eip712DomainFields(domain apitypes.TypedDataDomain) []apitypes.Type {
+	fields := []apitypes.Type{
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+	}
+	if domain.ChainId != nil {
+		fields = append(fields, apitypes.Type{Name: "chainId", Type: "uint256"})
+	}
+	if domain.Salt != "" {
+		fields = append(fields, apitypes.Type{Name: "salt", Type: "bytes32"})
+	}
+	return fields
+}
+
+/*
+EIP712TypedData returns the typed data wallets sign for the payload.
+The payload data is nested under "data" with its own entity type
+*/
+func (msg ClientPayload) EIP712TypedData() (apitypes.TypedData, error) {
+	domain := EIP712Domain(msg.ChainId)
+	fields := []apitypes.Type{
+		{Name: "id", Type: "string"},
+		{Name: "eventType", Type: "uint16"},
+		{Name: "subnet", Type: "string"},
+		{Name: "account", Type: "string"},
+		{Name: "validator", Type: "string"},
+		{Name: "nonce", Type: "uint64"},
+		{Name: "timestamp", Type: "uint64"},
+	}
+	message := apitypes.TypedDataMessage{
+		"id":        msg.Id,
+		"eventType": eip712Int(msg.EventType),
+		"subnet":    msg.Subnet,
+		"account":   string(msg.Account),
+		"validator": msg.Validator,
+		"nonce":     eip712Int(msg.Nonce),
+		"timestamp": eip712Int(msg.Timestamp),
+	}
+	types := apitypes.Types{
+		"EIP712Domain": eip712DomainFields(domain),
+	}
+	if msg.Data != nil {
+		data, ok := msg.Data.(EIP712Payload)
+		if !ok {
+			return apitypes.TypedData{}, fmt.Errorf("payload data does not support typed signing")
+		}
+		fields = append(fields, apitypes.Type{Name: "data", Type: data.EIP712Type()})
+		message["data"] = data.EIP712Message()
+		types[data.EIP712Type()] = data.EIP712Fields()
+	}
+	types["ClientPayload"] = fields
+	return apitypes.TypedData{
+		Types:       types,
+		PrimaryType: "ClientPayload",
+		Domain:      domain,
+		Message:     message,
+	}, nil
+}
+
+// EIP712Bytes returns the "\x19\x01" prefixed data whose keccak256 hash is signed
+func (msg ClientPayload) EIP712Bytes() ([]byte, error) {
+	typedData, err := msg.EIP712TypedData()
+	if err != nil {
+		return nil, err
+	}
+	_, raw, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(raw), nil
+}
+
+func (g Authorization) EIP712Type() string {
+	return "Authorization"
+}
+
+func (g Authorization) EIP712Fields() []apitypes.Type {
+	return []apitypes.Type{
+		{Name: "account", Type: "string"},
+		{Name: "agent", Type: "string"},
+		{Name: "grantor", Type: "string"},
+		{Name: "subnet", Type: "string"},
+		{Name: "priviledge", Type: "uint8"},
+		{Name: "topicIds", Type: "string"},
+		{Name: "duration", Type: "uint64"},
+		{Name: "timestamp", Type: "uint64"},
+		{Name: "meta", Type: "string"},
+	}
+}
+
+func (g Authorization) EIP712Message() apitypes.TypedDataMessage {
+	return apitypes.TypedDataMessage{
+		"account":    string(g.Account),
+		"agent":      string(g.Agent),
+		"grantor":    string(g.Grantor),
+		"subnet":     g.Subnet,
+		"priviledge": eip712Int(utils.SafePointerValue(g.Priviledge, 0)),
+		"topicIds":   g.TopicIds,
+		"duration":   eip712Int(utils.SafePointerValue(g.Duration, 0)),
+		"timestamp":  eip712Int(utils.SafePointerValue(g.Timestamp, 0)),
+		"meta":       g.Meta,
+	}
+}
+
+func (item Subnet) EIP712Type() string {
+	return "Subnet"
+}
+
+// pendingOwner is only part of the type while a transfer is pending, matching EncodeBytes
+func (item Subnet) EIP712Fields() []apitypes.Type {
+	fields := []apitypes.Type{
+		{Name: "ref", Type: "string"},
+		{Name: "account", Type: "string"},
+	}
+	if item.PendingOwner != "" {
+		fields = append(fields, apitypes.Type{Name: "pendingOwner", Type: "string"})
+	}
+	return append(fields, []apitypes.Type{
+		{Name: "meta", Type: "string"},
+		{Name: "status", Type: "uint8"},
+		{Name: "defaultAuthPrivilege", Type: "uint8"},
+		{Name: "categories", Type: "uint16[]"},
+		{Name: "agentRate", Type: "uint32"},
+		{Name: "agentBurst", Type: "uint32"},
+		{Name: "accountRate", Type: "uint32"},
+		{Name: "accountBurst", Type: "uint32"},
+		{Name: "subnetRate", Type: "uint32"},
+		{Name: "subnetBurst", Type: "uint32"},
+		{Name: "admins", Type: "string[]"},
+		{Name: "adminThreshold", Type: "uint16"},
+		{Name: "timestamp", Type: "uint64"},
+	}...)
+}
+
+func (item Subnet) EIP712Message() apitypes.TypedDataMessage {
+	categories := []interface{}{}
+	for _, cat := range item.Categories {
+		categories = append(categories, big.NewInt(int64(cat)))
+	}
+	admins := []interface{}{}
+	for _, admin := range item.Admins {
+		admins = append(admins, string(admin))
+	}
+	rateLimits := item.RateLimits
+	if rateLimits == nil {
+		rateLimits = &SubnetRateLimits{}
+	}
+	message := apitypes.TypedDataMessage{
+		"ref":                  item.Ref,
+		"account":              string(item.Account),
+		"meta":                 item.Meta,
+		"status":               eip712Int(utils.SafePointerValue(item.Status, 0)),
+		"defaultAuthPrivilege": eip712Int(utils.SafePointerValue(item.DefaultAuthPrivilege, 0)),
+		"categories":           categories,
+		"admins":               admins,
+		"adminThreshold":       eip712Int(item.AdminThreshold),
+		"timestamp":            eip712Int(item.Timestamp),
+	}
+	if item.PendingOwner != "" {
+		message["pendingOwner"] = string(item.PendingOwner)
+	}
+	for name, limit := range map[string]*RateLimit{"agent": rateLimits.Agent, "account": rateLimits.Account, "subnet": rateLimits.Subnet} {
+		limit = utils.IfThenElse(limit == nil, &RateLimit{}, limit)
+		message[name+"Rate"] = eip712Int(limit.Rate)
+		message[name+"Burst"] = eip712Int(limit.Burst)
+	}
+	return message
+}
+
+func (topic Topic) EIP712Type() string {
+	return "Topic"
+}
+
+func (topic Topic) EIP712Fields() []apitypes.Type {
+	fields := []apitypes.Type{
+		{Name: "id", Type: "string"},
+		{Name: "ref", Type: "string"},
+		{Name: "meta", Type: "string"},
+		{Name: "parentTopic", Type: "string"},
+	}
+	if topic.PendingOwner != "" {
+		fields = append(fields, apitypes.Type{Name: "pendingOwner", Type: "string"})
+	}
+	return append(fields, []apitypes.Type{
+		{Name: "public", Type: "bool"},
+		{Name: "readOnly", Type: "bool"},
+		{Name: "defaultSubscriberRole", Type: "uint8"},
+	}...)
+}
+
+func (topic Topic) EIP712Message() apitypes.TypedDataMessage {
+	message := apitypes.TypedDataMessage{
+		"id":                    topic.ID,
+		"ref":                   topic.Ref,
+		"meta":                  topic.Meta,
+		"parentTopic":           topic.ParentTopic,
+		"public":                utils.SafePointerValue(topic.Public, false),
+		"readOnly":              utils.SafePointerValue(topic.ReadOnly, false),
+		"defaultSubscriberRole": eip712Int(utils.SafePointerValue(topic.DefaultSubscriberRole, 0)),
+	}
+	if topic.PendingOwner != "" {
+		message["pendingOwner"] = string(topic.PendingOwner)
+	}
+	return message
+}
+
+func (sub Subscription) EIP712Type() string {
+	return "Subscription"
+}
+
+func (sub Subscription) EIP712Fields() []apitypes.Type {
+	return []apitypes.Type{
+		{Name: "topic", Type: "string"},
+		{Name: "subscriber", Type: "string"},
+		{Name: "ref", Type: "string"},
+		{Name: "meta", Type: "string"},
+		{Name: "role", Type: "uint8"},
+		{Name: "status", Type: "int16"},
+	}
+}
+
+func (sub Subscription) EIP712Message() apitypes.TypedDataMessage {
+	return apitypes.TypedDataMessage{
+		"topic":      sub.Topic,
+		"subscriber": sub.Subscriber.ToString(),
+		"ref":        sub.Ref,
+		"meta":       sub.Meta,
+		"role":       eip712Int(utils.SafePointerValue(sub.Role, 0)),
+		"status":     big.NewInt(int64(utils.SafePointerValue(sub.Status, 0))),
+	}
+}
+
+func (msg Message) EIP712Type() string {
+	return "Message"
+}
+
+func (msg Message) EIP712Fields() []apitypes.Type {
+	return []apitypes.Type{
+		{Name: "topic", Type: "string"},
+		{Name: "sender", Type: "string"},
+		{Name: "receiver", Type: "string"},
+		{Name: "dataType", Type: "string"},
+		{Name: "data", Type: "bytes"},
+		{Name: "actions", Type: "bytes"},
+		{Name: "nonce", Type: "uint64"},
+	}
+}
+
+func (msg Message) EIP712Message() apitypes.TypedDataMessage {
+	var actions []byte
+	for _, ac := range msg.Actions {
+		actions = append(actions, ac.EncodeBytes()...)
+	}
+	data, _ := hex.DecodeString(msg.Data)
+	return apitypes.TypedDataMessage{
+		"topic":    msg.Topic,
+		"sender":   string(msg.Sender),
+		"receiver": string(msg.Receiver),
+		"dataType": msg.DataType,
+		"data":     data,
+		"actions":  actions,
+		"nonce":    eip712Int(msg.Nonce),
+	}
+}
+
+func (e Wallet) EIP712Type() string {
+	return "Wallet"
+}
+
+func (e Wallet) EIP712Fields() []apitypes.Type {
+	return []apitypes.Type{
+		{Name: "name", Type: "string"},
+		{Name: "subnet", Type: "string"},
+		{Name: "account", Type: "string"},
+	}
+}
+
+func (e Wallet) EIP712Message() apitypes.TypedDataMessage {
+	return apitypes.TypedDataMessage{
+		"name":    e.Name,
+		"subnet":  e.Subnet,
+		"account": string(e.Account.ToString()),
+	}
+}
@@ -0,0 +1,146 @@
+package entities
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/lib/pq"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
+)
+
+func eip712TestPayload(data interface{}) ClientPayload {
+	return ClientPayload{
+		Data:          data,
+		Id:            "b2f9b3c1-8a52-4c7e-9f51-1d1a7e0f2a10",
+		EventType:     uint16(constants.CreateSubnetEvent),
+		Subnet:        "6c7ad3b0-1a2b-4c3d-8e9f-0a1b2c3d4e5f",
+		Account:       "did:0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266",
+		Validator:     "02ebec9d95769bb3d71712f0bf1e7e88b199fc945f67f908bbab81e9b7cb1092d8",
+		Nonce:         7,
+		Timestamp:     1705392177894,
+		ChainId:       "84532",
+		SignatureType: EIP712PubKey,
+	}
+}
+
+func eip712TestSubnet() Subnet {
+	status := uint8(1)
+	priv := constants.MemberPriviledge
+	return Subnet{
+		Ref:                  "demo",
+		Account:              "did:0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266",
+		Meta:                 "{}",
+		Status:               &status,
+		DefaultAuthPrivilege: &priv,
+		Categories:           pq.Int32Array{1, 3},
+		Timestamp:            1705392177894,
+	}
+}
+
+func eip712Hash(t *testing.T, payload ClientPayload) string {
+	b, err := payload.EIP712Bytes()
+	if err != nil {
+		t.Fatalf("EIP712Bytes: %v", err)
+	}
+	return hex.EncodeToString(crypto.Keccak256Hash(b))
+}
+
+// the wallet type is small enough to hash by hand following the EIP-712 spec
+func TestEIP712MatchesSpec(t *testing.T) {
+	wallet := Wallet{Name: "main", Subnet: "6c7ad3b0-1a2b-4c3d-8e9f-0a1b2c3d4e5f", Account: "did:0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"}
+	payload := eip712TestPayload(wallet)
+
+	keccak := crypto.Keccak256Hash
+	word := func(n uint64) []byte { return math.U256Bytes(new(big.Int).SetUint64(n)) }
+	concat := func(parts ...[]byte) (b []byte) {
+		for _, p := range parts {
+			b = append(b, p...)
+		}
+		return b
+	}
+	walletType := "Wallet(string name,string subnet,string account)"
+	payloadType := "ClientPayload(string id,uint16 eventType,string subnet,string account,string validator,uint64 nonce,uint64 timestamp,Wallet data)" + walletType
+	domain := concat(keccak([]byte("EIP712Domain(string name,string version,uint256 chainId)")), keccak([]byte(EIP712DomainName)), keccak([]byte(EIP712DomainVersion)), word(84532))
+	walletHash := keccak(concat(keccak([]byte(walletType)), keccak([]byte(wallet.Name)), keccak([]byte(wallet.Subnet)), keccak([]byte(wallet.Account))))
+	payloadHash := keccak(concat(
+		keccak([]byte(payloadType)),
+		keccak([]byte(payload.Id)),
+		word(uint64(payload.EventType)),
+		keccak([]byte(payload.Subnet)),
+		keccak([]byte(payload.Account)),
+		keccak([]byte(payload.Validator)),
+		word(payload.Nonce),
+		word(payload.Timestamp),
+		walletHash,
+	))
+	expected := hex.EncodeToString(keccak(concat([]byte("\x19\x01"), keccak(domain), payloadHash)))
+	if hash := eip712Hash(t, payload); hash != expected {
+		t.Fatalf("typed data hash should match \n%s \n%s", hash, expected)
+	}
+}
+
+func TestEIP712GoldenVectors(t *testing.T) {
+	priv := constants.AdminPriviledge
+	duration := uint64(3600000)
+	ts := uint64(1705392177894)
+	auth := Authorization{
+		Account: "did:0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266", Agent: "did:0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+		Subnet: "6c7ad3b0-1a2b-4c3d-8e9f-0a1b2c3d4e5f", Priviledge: &priv, TopicIds: "*", Duration: &duration, Timestamp: &ts,
+	}
+	subnet := eip712TestSubnet()
+	transfer := eip712TestSubnet()
+	transfer.PendingOwner = "did:0x70997970C51812dc3A010C7d01b50e0d17dc79C8"
+	nonNumericChain := eip712TestPayload(subnet)
+	nonNumericChain.ChainId = "mlayer-devnet"
+
+	for _, tc := range []struct {
+		name     string
+		payload  ClientPayload
+		expected string
+	}{
+		{"authorization", eip712TestPayload(auth), "02b584cd183e00665ccb7d14b227cae8247527f7ad5b57a3b4121e74a81054bb"},
+		{"subnet", eip712TestPayload(subnet), "9474ecabd1730dc203878b58cfc0698792c24f8f8cdbe5fc291fcef6ab816b85"},
+		{"subnet with pending owner", eip712TestPayload(transfer), "736261ffd80c85ca1d9f5b04760acab8a70a92ba22fc39555ada288a28199b59"},
+		{"salted domain", nonNumericChain, "c39190ffaca73f112f5c1ed4c8fc89bd94f9855d6b630ae2607586ae7506a299"},
+	} {
+		if hash := eip712Hash(t, tc.payload); hash != tc.expected {
+			t.Errorf("%s: typed data hash should match \n%s \n%s", tc.name, hash, tc.expected)
+		}
+	}
+}
+
+func TestEIP712PendingOwnerOnlyWhenSet(t *testing.T) {
+	subnet := eip712TestSubnet()
+	for _, f := range subnet.EIP712Fields() {
+		if f.Name == "pendingOwner" {
+			t.Fatal("pendingOwner should not be typed when no transfer is pending")
+		}
+	}
+	if _, ok := subnet.EIP712Message()["pendingOwner"]; ok {
+		t.Fatal("pendingOwner should not be in the message when no transfer is pending")
+	}
+	topic := Topic{ID: "t1", Ref: "general", PendingOwner: "did:0x70997970C51812dc3A010C7d01b50e0d17dc79C8"}
+	if topic.EIP712Message()["pendingOwner"] != string(topic.PendingOwner) {
+		t.Fatal("pendingOwner should be signed while a transfer is pending")
+	}
+}
+
+func TestEIP712SignatureVerifies(t *testing.T) {
+	payload := eip712TestPayload(eip712TestSubnet())
+	b, err := payload.SigningBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, sig := crypto.SignECC(b, "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80")
+	if !crypto.VerifySignatureECC("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266", &b, sig) {
+		t.Fatal("typed data signature should verify")
+	}
+	payload.Nonce++
+	changed, _ := payload.SigningBytes()
+	if crypto.VerifySignatureECC("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266", &changed, sig) {
+		t.Fatal("signature should not cover a different payload")
+	}
+}
@@ -203,6 +203,10 @@ type Event struct {
 	Validator   PublicKeyString `json:"val"`
 	Subnet   	string			`json:"snet"`
 	Index int64 `json:"vec"`
+	Batch       string          `json:"bat,omitempty"`  // hash of the client batch the event was created in. Events of a batch are applied all-or-nothing
+	BatchIndex  uint16          `json:"batI,omitempty"` // position of the event in its batch
+	BatchSize   uint16          `json:"batN,omitempty"`
+	Version     uint16          `json:"v,omitempty"` // envelope schema version. Not signed, so upcasting keeps signatures valid
 
 	Total int `json:"total"`
 }
@@ -213,6 +217,12 @@ func (g *Event) GetKeys() (keys []string)  {
 	// keys = append(keys, fmt.Sprintf("cy/%d/%d/%s/%s", g.Cycle, utils.IfThenElse(g.Synced, 1,0), g.Subnet, g.ID))
 	
 	keys = append(keys, g.DataKey())
+	if g.Payload.Agent != "" {
+		keys = append(keys, g.AgentEventKey())
+	}
+	if g.Batch != "" {
+		keys = append(keys, g.BatchEventKey())
+	}
 	
 	// keys = append(keys, fmt.Sprintf("%s/%s/%s", EntityModel, g.Subnet, g.ID))
 	// keys = append(keys,fmt.Sprintf("hash/%s",  g.GetIdHash()))
@@ -226,6 +236,26 @@ func (e *Event) DataKey() string {
 	return fmt.Sprintf("id/%s",  e.ID)
 }
 
+// AgentEventsKey prefixes the events an agent sent in a subnet, ordered by time
+func AgentEventsKey(subnet string, agent DeviceString) string {
+	return fmt.Sprintf("agt/%s/%s", subnet, AddressFromString(string(agent)).Addr)
+}
+
+// AgentEventKey orders the agents events by their zero padded unix milli timestamp
+func (e *Event) AgentEventKey() string {
+	return fmt.Sprintf("%s/%020d/%s/%s", AgentEventsKey(e.Subnet, e.Payload.Agent), e.Timestamp, GetModelTypeFromEventType(constants.EventType(e.EventType)), e.ID)
+}
+
+// BatchEventsKey prefixes the events of a client batch, ordered by their index in it
+func BatchEventsKey(batch string, index uint16) string {
+	return fmt.Sprintf("bat/%s/%05d", batch, index)
+}
+
+// BatchEventKey lets validators that missed part of a batch request the event at its index
+func (e *Event) BatchEventKey() string {
+	return fmt.Sprintf("%s/%s/%s", BatchEventsKey(e.Batch, e.BatchIndex), GetModelTypeFromEventType(constants.EventType(e.EventType)), e.ID)
+}
+
 func (e *Event) VectorKey(topic string) string {
 	return fmt.Sprintf("vec/%s/%s", e.Validator, topic)
 }
@@ -341,6 +371,8 @@ func GetModel(ent any) EntityModel {
 			model = SubscriptionModel
 		case Message:
 			model = MessageModel
+		case Wallet, TokenTransaction:
+			model = WalletModel
 	}
 	return model
 }
@@ -363,6 +395,9 @@ func UnpackEvent(b []byte, model EntityModel) (*Event, error) {
 	if err := encoder.MsgPackUnpackStruct(b, &e); err != nil {
 		return nil, err
 	}
+	if err := upcastEvent(b, &e); err != nil {
+		return nil, err
+	}
 	c, err := json.Marshal(e.Payload)
 	if err != nil {
 		return nil, err
@@ -376,30 +411,48 @@ func UnpackEvent(b []byte, model EntityModel) (*Event, error) {
 		logger.Errorf("UnmarshalError:: %o", err)
 	}
 	
-	dBytes, err := json.Marshal(pl.Data)
-	
-	switch model {
-	case AuthModel:
-		r := Authorization{}
-		json.Unmarshal(dBytes, &r)
-		pl.Data = r
-	case SubnetModel:
-		r := Subnet{}
-		json.Unmarshal(dBytes, &r)
-		pl.Data = r
-	case TopicModel:
-		r := Topic{}
-		json.Unmarshal(dBytes, &r)
-		logger.Debugf("PAYLOADDDDD %v", r)
-		pl.Data = r
-	case SubscriptionModel:
-		r := Subscription{}
-		json.Unmarshal(dBytes, &r)
-		pl.Data = r
-	case MessageModel:
-		r := Message{}
-		json.Unmarshal(dBytes, &r)
-		pl.Data = r
+	err = upcastPayloadData(&e, model, &pl, func() error {
+		dBytes, err := json.Marshal(pl.Data)
+		if err != nil {
+			return err
+		}
+		switch model {
+		case AuthModel:
+			r := Authorization{}
+			json.Unmarshal(dBytes, &r)
+			pl.Data = r
+		case SubnetModel:
+			r := Subnet{}
+			json.Unmarshal(dBytes, &r)
+			pl.Data = r
+		case TopicModel:
+			r := Topic{}
+			json.Unmarshal(dBytes, &r)
+			logger.Debugf("PAYLOADDDDD %v", r)
+			pl.Data = r
+		case SubscriptionModel:
+			r := Subscription{}
+			json.Unmarshal(dBytes, &r)
+			pl.Data = r
+		case MessageModel:
+			r := Message{}
+			json.Unmarshal(dBytes, &r)
+			pl.Data = r
+		case WalletModel:
+			if IsTokenEvent(e.EventType) {
+				r := TokenTransaction{}
+				json.Unmarshal(dBytes, &r)
+				pl.Data = r
+			} else {
+				r := Wallet{}
+				json.Unmarshal(dBytes, &r)
+				pl.Data = r
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	
 	// json.Unmarshal(dBytes, &pl.Data)
@@ -482,19 +535,28 @@ func (e Event) EncodeBytes() ([]byte, error) {
 	// 	}
 	// }
 	
-	return encoder.EncodeBytes(
-		encoder.EncoderParam{Type: encoder.ByteEncoderDataType, Value: d},
-		encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: strings.Join(e.Associations, "")},
-		encoder.EncoderParam{Type: encoder.ByteEncoderDataType, Value: utils.UuidToBytes(e.AuthEvent.ID)},
-		encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: e.BlockNumber},
-		encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: e.Cycle},
-		encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: e.Epoch},
-		encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: e.EventType},
-		encoder.EncoderParam{Type: encoder.ByteEncoderDataType, Value: utils.UuidToBytes(e.PreviousEvent.ID)},
-		encoder.EncoderParam{Type: encoder.ByteEncoderDataType, Value: utils.UuidToBytes(e.Subnet)},
-		encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: e.Timestamp},
-		encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: e.Index},
-	)
+	params := []encoder.EncoderParam{
+		{Type: encoder.ByteEncoderDataType, Value: d},
+		{Type: encoder.StringEncoderDataType, Value: strings.Join(e.Associations, "")},
+		{Type: encoder.ByteEncoderDataType, Value: utils.UuidToBytes(e.AuthEvent.ID)},
+		{Type: encoder.IntEncoderDataType, Value: e.BlockNumber},
+		{Type: encoder.IntEncoderDataType, Value: e.Cycle},
+		{Type: encoder.IntEncoderDataType, Value: e.Epoch},
+		{Type: encoder.IntEncoderDataType, Value: e.EventType},
+		{Type: encoder.ByteEncoderDataType, Value: utils.UuidToBytes(e.PreviousEvent.ID)},
+		{Type: encoder.ByteEncoderDataType, Value: utils.UuidToBytes(e.Subnet)},
+		{Type: encoder.IntEncoderDataType, Value: e.Timestamp},
+		{Type: encoder.IntEncoderDataType, Value: e.Index},
+	}
+	// signed only for batched events, so the ids of other events are unchanged
+	if e.Batch != "" {
+		params = append(params,
+			encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: e.Batch},
+			encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: e.BatchIndex},
+			encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: e.BatchSize},
+		)
+	}
+	return encoder.EncodeBytes(params...)
 }
 
 func (e Event) GetValidator() PublicKeyString {
@@ -617,3 +679,16 @@ func CycleSubnetKey(cycle uint64, subnet string) string {
 	return fmt.Sprintf("cyc/%015d/%s", cycle, subnet)
 }
 
+// BillingKey marks that the subnet was billed for the event, so that re-committing it does not bill again
+func (e *Event) BillingKey() string {
+	return fmt.Sprintf("bill/%s", e.ID)
+}
+
+// SubnetUsageKey counts the events a subnet is billed for in a cycle
+func SubnetUsageKey(subnet string, cycle *uint64) string {
+	if cycle == nil {
+		return fmt.Sprintf("use/%s", subnet)
+	}
+	return fmt.Sprintf("use/%s/%015d", subnet, *cycle)
+}
+
@@ -0,0 +1,166 @@
+package entities
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/mlayerprotocol/go-mlayer/common/encoder"
+	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
+)
+
+/*
+Schema versions of the event envelope and of each entity payload.
+Version 0 is everything written before versions were recorded.
+Bump a version whenever a field is renamed, moved or reinterpreted, and register an upcaster from the previous version
+*/
+const EventSchemaVersion uint16 = 1
+
+var payloadSchemaVersions = map[EntityModel]uint16{
+	AuthModel:         1,
+	SubnetModel:       1,
+	TopicModel:        1,
+	SubscriptionModel: 1,
+	MessageModel:      1,
+	WalletModel:       1,
+}
+
+func init() {
+	RegisterUpcaster(SubnetModel, 0, upcastSubnetOwner)
+}
+
+/*
+Subnets written before schema versions could name their owner in the deprecated owner field instead of the account.
+The owner is the account the subnet was signed with, so moving it keeps the signed bytes unchanged
+*/
+func upcastSubnetOwner(data map[string]interface{}) error {
+	owner, _ := data["owner"].(string)
+	delete(data, "owner")
+	if account, _ := data["acct"].(string); account != "" || owner == "" {
+		return nil
+	}
+	data["acct"] = owner
+	return nil
+}
+
+// eventSchemaModel keys the upcasters of the event envelope itself
+const eventSchemaModel EntityModel = "event"
+
+/*
+Upcaster rewrites a decoded encoding of one schema version into the next version.
+It must keep every value the signed bytes are built from, so that old signatures still verify after upcasting
+*/
+type Upcaster func(data map[string]interface{}) error
+
+var (
+	upcastersMutex sync.RWMutex
+	upcasters      = map[EntityModel]map[uint16]Upcaster{}
+)
+
+func PayloadSchemaVersion(model EntityModel) uint16 {
+	return payloadSchemaVersions[model]
+}
+
+// RegisterUpcaster registers the conversion of a models payload data from version `from` to `from + 1`
+func RegisterUpcaster(model EntityModel, from uint16, upcaster Upcaster) {
+	upcastersMutex.Lock()
+	defer upcastersMutex.Unlock()
+	if upcasters[model] == nil {
+		upcasters[model] = map[uint16]Upcaster{}
+	}
+	upcasters[model][from] = upcaster
+}
+
+// RegisterEventUpcaster registers the conversion of the event envelope from version `from` to `from + 1`
+func RegisterEventUpcaster(from uint16, upcaster Upcaster) {
+	RegisterUpcaster(eventSchemaModel, from, upcaster)
+}
+
+func hasUpcasters(model EntityModel, version uint16, current uint16) bool {
+	upcastersMutex.RLock()
+	defer upcastersMutex.RUnlock()
+	for v := version; v < current; v++ {
+		if upcasters[model][v] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+upcast runs the upcasters of model from version up to current.
+It reports whether any upcaster changed the data
+*/
+func upcast(model EntityModel, version uint16, current uint16, data map[string]interface{}) (bool, error) {
+	upcastersMutex.RLock()
+	defer upcastersMutex.RUnlock()
+	changed := false
+	for v := version; v < current; v++ {
+		up := upcasters[model][v]
+		if up == nil {
+			continue
+		}
+		if err := up(data); err != nil {
+			return changed, fmt.Errorf("upcasting %s from schema version %d: %v", model, v, err)
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// upcastEvent decodes an event written with an older envelope schema through the registered upcasters
+func upcastEvent(b []byte, e *Event) error {
+	if e.Version >= EventSchemaVersion || !hasUpcasters(eventSchemaModel, e.Version, EventSchemaVersion) {
+		return nil
+	}
+	raw := map[string]interface{}{}
+	if err := encoder.MsgPackUnpackStruct(b, &raw); err != nil {
+		return err
+	}
+	if _, err := upcast(eventSchemaModel, e.Version, EventSchemaVersion, raw); err != nil {
+		return err
+	}
+	upcasted, err := encoder.MsgPackStruct(raw)
+	if err != nil {
+		return err
+	}
+	*e = Event{}
+	if err := encoder.MsgPackUnpackStruct(upcasted, e); err != nil {
+		return err
+	}
+	e.Version = EventSchemaVersion
+	return nil
+}
+
+/*
+upcastPayloadData brings the raw payload data of an event to the current schema of its model.
+Upcasted payloads must still hash to the events payload hash, otherwise the upcaster broke signature verification
+*/
+func upcastPayloadData(e *Event, model EntityModel, pl *ClientPayload, decode func() error) error {
+	current := PayloadSchemaVersion(model)
+	if pl.Version >= current {
+		return decode()
+	}
+	data, ok := pl.Data.(map[string]interface{})
+	if !ok {
+		return decode()
+	}
+	changed, err := upcast(model, pl.Version, current, data)
+	if err != nil {
+		return err
+	}
+	if err := decode(); err != nil {
+		return err
+	}
+	pl.Version = current
+	if changed && e.PayloadHash != "" {
+		b, err := pl.EncodeBytes()
+		if err != nil {
+			return err
+		}
+		if hex.EncodeToString(crypto.Keccak256Hash(b)) != e.PayloadHash {
+			return fmt.Errorf("upcasted %s payload no longer matches the event payload hash", model)
+		}
+	}
+	return nil
+}
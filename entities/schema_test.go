@@ -0,0 +1,75 @@
+package entities
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/common/encoder"
+	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
+)
+
+// a subnet event from before schema versions, with the owner in the deprecated field
+func legacySubnetEvent(t *testing.T) (*Event, ClientPayload) {
+	status := uint8(1)
+	priv := constants.MemberPriviledge
+	signed := ClientPayload{
+		Data:      Subnet{Ref: "legacy", Account: "did:0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266", Meta: "{}", Status: &status, DefaultAuthPrivilege: &priv, Timestamp: 1705392177894},
+		Id:        "b2f9b3c1-8a52-4c7e-9f51-1d1a7e0f2a10",
+		EventType: uint16(constants.CreateSubnetEvent),
+		Account:   "did:0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266",
+		Validator: "02ebec9d95769bb3d71712f0bf1e7e88b199fc945f67f908bbab81e9b7cb1092d8",
+		Nonce:     1,
+		Timestamp: 1705392177894,
+		ChainId:   "84532",
+	}
+	b, err := signed.EncodeBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, signed.Signature = crypto.SignECC(b, "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80")
+
+	legacy := signed
+	legacy.Data = map[string]interface{}{
+		"ref": "legacy", "owner": "did:0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266", "meta": "{}", "st": 1, "dAuthPriv": int(priv), "ts": 1705392177894,
+	}
+	return &Event{Payload: legacy, PayloadHash: hex.EncodeToString(crypto.Keccak256Hash(b))}, signed
+}
+
+func TestUpcastLegacySubnetOwner(t *testing.T) {
+	legacy, signed := legacySubnetEvent(t)
+	b, err := encoder.MsgPackStruct(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := UnpackEvent(b, SubnetModel)
+	if err != nil {
+		t.Fatalf("UnpackEvent: %v", err)
+	}
+	subnet, ok := event.Payload.Data.(Subnet)
+	if !ok || subnet.Account != signed.Account {
+		t.Fatalf("expected the owner to become the account, got %+v", event.Payload.Data)
+	}
+	if event.Payload.Version != PayloadSchemaVersion(SubnetModel) {
+		t.Fatalf("expected schema version %d, got %d", PayloadSchemaVersion(SubnetModel), event.Payload.Version)
+	}
+	upcasted, err := event.Payload.EncodeBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !crypto.VerifySignatureECC("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266", &upcasted, signed.Signature) {
+		t.Fatal("the legacy signature should verify against the upcasted payload")
+	}
+}
+
+func TestUpcastRejectsChangedPayload(t *testing.T) {
+	legacy, _ := legacySubnetEvent(t)
+	legacy.Payload.Data.(map[string]interface{})["owner"] = "did:0x70997970C51812dc3A010C7d01b50e0d17dc79C8"
+	b, err := encoder.MsgPackStruct(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UnpackEvent(b, SubnetModel); err == nil {
+		t.Fatal("an upcast that changes the signed bytes should be rejected")
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
+	"unicode"
 
 	"github.com/lib/pq"
 	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
@@ -21,6 +23,19 @@ import (
 
 
 const DataKey = "data/%s/%s"
+
+// RateLimit is a token bucket refilled at Rate events per minute and holding at most Burst events
+type RateLimit struct {
+	Rate  uint32 `json:"r"`
+	Burst uint32 `json:"b"`
+}
+
+type SubnetRateLimits struct {
+	Agent   *RateLimit `json:"agt,omitempty"`
+	Account *RateLimit `json:"acct,omitempty"`
+	Subnet  *RateLimit `json:"snet,omitempty"`
+}
+
 type Subnet struct {
 	ID            string        `json:"id" gorm:"type:uuid;primaryKey;not null"`
 	Meta          string        `json:"meta,omitempty"`
@@ -33,10 +48,13 @@ type Subnet struct {
 	Balance       uint64        `json:"bal" gorm:"default:0"`
 	// Readonly
 	Account DIDString    `json:"acct,omitempty" binding:"required"  gorm:"not null;type:varchar(100)"`
-	
+	PendingOwner DIDString `json:"pOwn,omitempty" gorm:"type:varchar(100)"` // offered ownership awaiting acceptance
 
 	// CreateTopicPrivilege   *constants.AuthorizationPrivilege `json:"cTopPriv"` //
 	DefaultAuthPrivilege *constants.AuthorizationPrivilege `json:"dAuthPriv"` // privilege for external users who joins the subnet. 0 indicates people cant join
+	RateLimits *SubnetRateLimits `json:"rLim,omitempty" gorm:"serializer:json"` // set by the owner to throttle writes
+	Admins         []DIDString `json:"admins,omitempty" gorm:"serializer:json"` // accounts that approve sensitive subnet events
+	AdminThreshold uint16      `json:"adminTh,omitempty"`                      // number of admin approvals required. 0 disables multisig
 
 	// Derived
 	Event EventPath `json:"e,omitempty" gorm:"index;varchar;"`
@@ -62,7 +80,7 @@ func (d Subnet) GetSignature() (string) {
 		val, _ := base64.StdEncoding.DecodeString(d.SignatureData.Signature)
 		 return hex.EncodeToString(val)
 	}
-	if d.SignatureData.Type == EthereumPubKey {
+	if d.SignatureData.Type == EthereumPubKey || d.SignatureData.Type == EIP712PubKey || d.SignatureData.Type == EIP1271PubKey {
 		return strings.ReplaceAll(d.SignatureData.Signature, "0x", "")
 	}
 	return ""
@@ -77,9 +95,15 @@ func (g *Subnet) GetKeys() (keys []string)  {
 	if g.ID == "" {
 		g.ID, _ = GetId(g, "")
 	}
-	keys = append(keys, fmt.Sprintf("%s/%s/%s", g.AccountSubnetsKey(), utils.IntMilliToTimestampString(int64(g.Timestamp)), g.ID))
+	keys = append(keys, g.AccountSubnetKey())
 	keys = append(keys, g.Key())
 	keys = append(keys, g.RefKey())
+	for _, cat := range g.Categories {
+		keys = append(keys, fmt.Sprintf("%s/%s", g.CategoryKey(cat), g.ID))
+	}
+	for _, word := range g.Keywords() {
+		keys = append(keys, fmt.Sprintf("%s/%s", g.KeywordKey(word), g.ID))
+	}
 	// keys = append(keys, fmt.Sprintf("%s/%d/%s", SubnetModel, g.Cycle, g.ID))
 	// keys = append(keys,fmt.Sprintf("%s/%s/%s", g.Event.ID, SubnetModel, g.Hash ))
 	keys = append(keys, g.DataKey())
@@ -114,10 +138,47 @@ func (item *Subnet) RefKey() string {
 
 
 
+func (g *Subnet) CategoryKey(category int32) string {
+	return fmt.Sprintf("%s/cat/%d", SubnetModel, category)
+}
+
+// KeywordKey indexes subnets by a word of their ref or meta. Prefix queries on it match words starting with word
+func (g *Subnet) KeywordKey(word string) string {
+	return fmt.Sprintf("%s/kw/%s", SubnetModel, word)
+}
+
+// Keywords returns the words of the subnets ref and meta that it can be searched by
+func (g *Subnet) Keywords() []string {
+	return SubnetKeywords(g.Ref + " " + g.Meta)
+}
+
+// SubnetKeywords splits text into distinct lower case words, ignoring single characters
+func SubnetKeywords(text string) []string {
+	words := []string{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) > 1 && !slices.Contains(words, word) {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// IsPublic reports whether external accounts can discover and join the subnet
+func (g *Subnet) IsPublic() bool {
+	return utils.SafePointerValue(g.Status, 0) != 0 && utils.SafePointerValue(g.DefaultAuthPrivilege, constants.UnauthorizedPriviledge) > constants.UnauthorizedPriviledge
+}
+
 func (g *Subnet) AccountSubnetsKey() string {
 	return fmt.Sprintf("%s/acct/%s", SubnetModel, g.Account)
 }
 
+// AccountSubnetKey indexes the subnet under its owner
+func (g *Subnet) AccountSubnetKey() string {
+	return fmt.Sprintf("%s/%s/%s", g.AccountSubnetsKey(), utils.IntMilliToTimestampString(int64(g.Timestamp)), g.ID)
+}
+
 
 
 func (item *Subnet) ToJSON() []byte {
@@ -205,12 +266,33 @@ func (item Subnet) EncodeBytes() ([]byte, error) {
 		}
 		cats = append(cats, b...)
 	}
-	return encoder.EncodeBytes(
-		encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: item.Account},
-		encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: utils.SafePointerValue(item.DefaultAuthPrivilege, 0)},
-		encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: item.Meta},
-		encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: item.Ref},
-		encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: utils.SafePointerValue(item.Status, 0)},
-		encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: item.Timestamp},
-	)
+	params := []encoder.EncoderParam{
+		{Type: encoder.StringEncoderDataType, Value: item.Account},
+		{Type: encoder.IntEncoderDataType, Value: utils.SafePointerValue(item.DefaultAuthPrivilege, 0)},
+		{Type: encoder.StringEncoderDataType, Value: item.Meta},
+		{Type: encoder.StringEncoderDataType, Value: item.Ref},
+		{Type: encoder.IntEncoderDataType, Value: utils.SafePointerValue(item.Status, 0)},
+		{Type: encoder.IntEncoderDataType, Value: item.Timestamp},
+	}
+	// rate limits are only encoded when set so older clients produce the same hash
+	if item.RateLimits != nil {
+		for _, limit := range []*RateLimit{item.RateLimits.Agent, item.RateLimits.Account, item.RateLimits.Subnet} {
+			limit = utils.IfThenElse(limit == nil, &RateLimit{}, limit)
+			params = append(params,
+				encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: limit.Rate},
+				encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: limit.Burst},
+			)
+		}
+	}
+	if item.PendingOwner != "" {
+		params = append(params, encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: item.PendingOwner})
+	}
+	// admins are only encoded when set so older clients produce the same hash
+	if len(item.Admins) > 0 || item.AdminThreshold > 0 {
+		for _, admin := range item.Admins {
+			params = append(params, encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: admin})
+		}
+		params = append(params, encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: item.AdminThreshold})
+	}
+	return encoder.EncodeBytes(params...)
 }
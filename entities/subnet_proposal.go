@@ -0,0 +1,109 @@
+package entities
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/common/encoder"
+	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
+)
+
+type ProposalStatus uint8
+
+const (
+	PendingProposal   ProposalStatus = 0
+	FinalizedProposal ProposalStatus = 1
+)
+
+// how long admins have to approve a proposal before it must be proposed again with a fresh payload
+const ProposalTTL = 72 * time.Hour
+
+// ProposalApproval is a subnet admins signature over a proposed payload
+type ProposalApproval struct {
+	Admin         DIDString     `json:"admin" binding:"required"`
+	SignatureData SignatureData `json:"sigD" binding:"required"`
+	Timestamp     uint64        `json:"ts"`
+}
+
+/*
+SubnetProposal holds a sensitive subnet event until enough of the subnets admins approve it.
+Proposals are node local and only the finalized event is broadcast
+*/
+type SubnetProposal struct {
+	ID        string         `json:"id"`
+	Subnet    string         `json:"snet"`
+	Payload   ClientPayload  `json:"pl"`
+	Threshold uint16         `json:"th"`
+	Status    ProposalStatus `json:"st"`
+	Event     string         `json:"e,omitempty"`
+	Timestamp uint64         `json:"ts"`
+}
+
+func (p *SubnetProposal) Key() string {
+	return fmt.Sprintf("%s/%s", SubnetProposalsKey(p.Subnet), p.ID)
+}
+
+func SubnetProposalsKey(subnet string) string {
+	return fmt.Sprintf("sprop/%s", subnet)
+}
+
+// ProposalId is the hash of the payload without its approvals, which is what admins sign
+func ProposalId(payload ClientPayload) (string, error) {
+	b, err := payload.EncodeBytes()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(crypto.Keccak256Hash(b)), nil
+}
+
+// IsApprovedBy reports whether admin already approved the proposal
+func (p *SubnetProposal) IsApprovedBy(admin DIDString) bool {
+	for _, approval := range p.Payload.Approvals {
+		if strings.EqualFold(AddressFromString(string(approval.Admin)).Addr, AddressFromString(string(admin)).Addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpiresAt returns the unix milli time after which the proposal can no longer be approved
+func (p *SubnetProposal) ExpiresAt() uint64 {
+	return p.Timestamp + uint64(ProposalTTL.Milliseconds())
+}
+
+func (p *SubnetProposal) IsExpired() bool {
+	return uint64(time.Now().UnixMilli()) > p.ExpiresAt()
+}
+
+func (p *SubnetProposal) MsgPack() []byte {
+	b, _ := encoder.MsgPackStruct(p)
+	return b
+}
+
+func UnpackSubnetProposal(b []byte) (SubnetProposal, error) {
+	var p SubnetProposal
+	err := encoder.MsgPackUnpackStruct(b, &p)
+	if err != nil {
+		return p, err
+	}
+	// restore the typed payload data so the proposal can be hashed and submitted
+	d, err := json.Marshal(p.Payload.Data)
+	if err != nil {
+		return p, err
+	}
+	switch GetModelTypeFromEventType(constants.EventType(p.Payload.EventType)) {
+	case SubnetModel:
+		data := Subnet{}
+		err = json.Unmarshal(d, &data)
+		p.Payload.Data = data
+	case AuthModel:
+		data := Authorization{}
+		err = json.Unmarshal(d, &data)
+		p.Payload.Data = data
+	}
+	return p, err
+}
@@ -0,0 +1,196 @@
+package entities
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/common/encoder"
+	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
+)
+
+/*
+TokenTransaction moves the tokens of a subnet wallet.
+Mints have no sender and burns have no recipient. The client payload nonce orders each accounts spends
+*/
+type TokenTransaction struct {
+	Wallet    string       `json:"wal" binding:"required"`
+	From      DIDString    `json:"from,omitempty"`
+	To        DIDString    `json:"to,omitempty"`
+	Amount    string       `json:"amt" binding:"required"` // base units as a decimal string
+	Memo      string       `json:"memo,omitempty"`
+	Timestamp uint64       `json:"ts"`
+	Agent     DeviceString `json:"agt,omitempty"`
+
+	// Derived
+	Account        DIDString `json:"acct,omitempty"`
+	Nonce          uint64    `json:"nonce,omitempty"`
+	Event          EventPath `json:"e,omitempty"`
+	Hash           string    `json:"h,omitempty"`
+	BlockNumber    uint64    `json:"blk"`
+	Cycle          uint64    `json:"cy"`
+	Epoch          uint64    `json:"ep"`
+	EventSignature string    `json:"csig,omitempty"`
+}
+
+func IsTokenEvent(eventType uint16) bool {
+	switch constants.EventType(eventType) {
+	case constants.MintTokenEvent, constants.TransferTokenEvent, constants.BurnTokenEvent:
+		return true
+	}
+	return false
+}
+
+// AmountInt parses the amount, which must be a positive integer
+func (t TokenTransaction) AmountInt() (*big.Int, error) {
+	amount, ok := new(big.Int).SetString(t.Amount, 10)
+	if !ok || amount.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid token amount %q", t.Amount)
+	}
+	return amount, nil
+}
+
+func (t TokenTransaction) GetSignature() string {
+	return t.EventSignature
+}
+
+func (t TokenTransaction) GetEvent() EventPath {
+	return t.Event
+}
+
+func (t TokenTransaction) GetAgent() DeviceString {
+	return t.Agent
+}
+
+func (t TokenTransaction) EncodeBytes() ([]byte, error) {
+	return encoder.EncodeBytes(
+		encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: t.Wallet},
+		encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: t.From},
+		encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: t.To},
+		encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: t.Amount},
+		encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: t.Memo},
+		encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: t.Timestamp},
+	)
+}
+
+func (t TokenTransaction) GetHash() ([]byte, error) {
+	b, err := t.EncodeBytes()
+	if err != nil {
+		return []byte(""), err
+	}
+	return crypto.Sha256(b), nil
+}
+
+func (t TokenTransaction) ToString() (string, error) {
+	values := []string{}
+	values = append(values, t.Wallet)
+	values = append(values, string(t.From))
+	values = append(values, string(t.To))
+	values = append(values, t.Amount)
+	return strings.Join(values, ","), nil
+}
+
+func (t *TokenTransaction) ToJSON() []byte {
+	m, err := json.Marshal(t)
+	if err != nil {
+		logger.Errorf("Unable to parse token transaction to []byte")
+	}
+	return m
+}
+
+func (t *TokenTransaction) MsgPack() []byte {
+	b, _ := encoder.MsgPackStruct(t)
+	return b
+}
+
+func UnpackTokenTransaction(b []byte) (TokenTransaction, error) {
+	var t TokenTransaction
+	err := encoder.MsgPackUnpackStruct(b, &t)
+	return t, err
+}
+
+// NonceKey holds the transaction that spent an accounts nonce in a wallet
+func (t *TokenTransaction) NonceKey() string {
+	return WalletNonceKey(t.Wallet, t.Account, t.Nonce)
+}
+
+func WalletNonceKey(wallet string, account DIDString, nonce uint64) string {
+	return fmt.Sprintf("%s/%020d", WalletNoncesKey(wallet, account), nonce)
+}
+
+func WalletNoncesKey(wallet string, account DIDString) string {
+	return fmt.Sprintf("waln/%s/%s", wallet, strings.ToLower(AddressFromString(string(account)).Addr))
+}
+
+func WalletBalanceKey(wallet string, account DIDString) string {
+	return fmt.Sprintf("walb/%s/%s", wallet, strings.ToLower(AddressFromString(string(account)).Addr))
+}
+
+// WalletSupply totals the tokens minted and burnt in a wallet
+type WalletSupply struct {
+	Wallet string  `json:"wal"`
+	Minted big.Int `json:"mnt"`
+	Burnt  big.Int `json:"brn"`
+	Supply big.Int `json:"sup"`
+}
+
+func (s *WalletSupply) MsgPack() []byte {
+	b, _ := encoder.MsgPackStruct(s)
+	return b
+}
+
+func WalletSupplyKey(wallet string) string {
+	return fmt.Sprintf("wals/%s", wallet)
+}
+
+// WalletTransactionsKey prefixes the transactions an account sent or received
+func WalletTransactionsKey(wallet string, account DIDString) string {
+	return fmt.Sprintf("walt/%s/%s", wallet, strings.ToLower(AddressFromString(string(account)).Addr))
+}
+
+func (t *TokenTransaction) TransactionKey(account DIDString) string {
+	return fmt.Sprintf("%s/%015d/%s", WalletTransactionsKey(t.Wallet, account), t.Timestamp, t.Event.ID)
+}
+
+// WalletLedgerKey prefixes every transaction received for a wallet, valid or not
+func WalletLedgerKey(wallet string) string {
+	return fmt.Sprintf("%s/%s", WalletLedgersKey(), wallet)
+}
+
+func WalletLedgersKey() string {
+	return "wall"
+}
+
+// LedgerKey sorts a wallets transactions in the order every validator applies them, that of CompareEventOrder
+func (t *TokenTransaction) LedgerKey() string {
+	return fmt.Sprintf("%s/%s", WalletLedgerKey(t.Wallet), t.Order().Key())
+}
+
+func (t TokenTransaction) EIP712Type() string {
+	return "TokenTransaction"
+}
+
+func (t TokenTransaction) EIP712Fields() []apitypes.Type {
+	return []apitypes.Type{
+		{Name: "wallet", Type: "string"},
+		{Name: "from", Type: "string"},
+		{Name: "to", Type: "string"},
+		{Name: "amount", Type: "string"},
+		{Name: "memo", Type: "string"},
+		{Name: "timestamp", Type: "uint64"},
+	}
+}
+
+func (t TokenTransaction) EIP712Message() apitypes.TypedDataMessage {
+	return apitypes.TypedDataMessage{
+		"wallet":    t.Wallet,
+		"from":      string(t.From),
+		"to":        string(t.To),
+		"amount":    t.Amount,
+		"memo":      t.Memo,
+		"timestamp": eip712Int(t.Timestamp),
+	}
+}
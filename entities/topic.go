@@ -24,6 +24,7 @@ type Topic struct {
 	ParentTopic string        `json:"pT,omitempty" gorm:"type:char(64)"`
 	SubscriberCount uint64        `json:"sC,omitempty"`
 	Account         DIDString `json:"acct,omitempty" binding:"required"  gorm:"not null;type:varchar(100)"`
+	PendingOwner    DIDString `json:"pOwn,omitempty" gorm:"type:varchar(100)"` // offered ownership awaiting acceptance
 
 	Agent DeviceString `json:"agt,omitempty" binding:"required"  gorm:"not null;type:varchar(100)"`
 	//
@@ -70,7 +71,7 @@ func (item *Topic) Key() string {
 
 
 func (g *Topic) GetKeys() (keys []string)  {
-	keys = append(keys, fmt.Sprintf("%s/%s/%s",  g.GetAccountTopicsKey(), utils.IntMilliToTimestampString(int64(g.Timestamp)), g.ID))
+	keys = append(keys, g.AccountTopicKey())
 	// keys = append(keys, fmt.Sprintf("%s/acct/%s/%s/%s", TopicModel, g.Account, g.Subnet, g.ID))
 	keys = append(keys, g.Key())
 	keys = append(keys, g.DataKey())
@@ -86,6 +87,11 @@ func (item *Topic) RefKey() string {
 	return fmt.Sprintf("%s|ref|%s|%s", TopicModel, item.Subnet, item.Ref)
 }
 
+// AccountTopicKey indexes the topic under its owner
+func (g *Topic) AccountTopicKey() string {
+	return fmt.Sprintf("%s/%s/%s", g.GetAccountTopicsKey(), utils.IntMilliToTimestampString(int64(g.Timestamp)), g.ID)
+}
+
 func (g *Topic) GetAccountTopicsKey() (string) {
 	if (g.Subnet != "") {
 		if g.Agent != ""  {
@@ -157,7 +163,7 @@ func (topic Topic) ToString() (string, error) {
 	// values = append(values, fmt.Sprintf("%d", topic.Timestamp))
 	values = append(values, fmt.Sprintf("%d", topic.SubscriberCount))
 	values = append(values, string(topic.Account))
-	values = append(values, fmt.Sprintf("%t", topic.Public))
+	values = append(values, fmt.Sprintf("%t", utils.SafePointerValue(topic.Public, false)))
 	// values = append(values, fmt.Sprintf("%s", topic.Signature))
 	return strings.Join(values, ","), nil
 }
@@ -170,7 +176,7 @@ func (topic Topic) GetAgent() DeviceString {
 }
 
 func (topic Topic) EncodeBytes() ([]byte, error) {
-	return encoder.EncodeBytes(
+	params := []encoder.EncoderParam{
 		encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: utils.SafePointerValue(topic.DefaultSubscriberRole, 0)},
 		encoder.EncoderParam{Type: encoder.ByteEncoderDataType, Value: utils.UuidToBytes(topic.ID)},
 		encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: topic.Meta},
@@ -180,6 +186,11 @@ func (topic Topic) EncodeBytes() ([]byte, error) {
 		encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: topic.Ref},
 		// encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: *topic.DefaultSubscriptionStatus},
 		// encoder.EncoderParam{Type: encoder.ByteEncoderDataType, Value: utils.UuidToBytes(topic.Subnet)},
-	)
+	}
+	// only encoded when set so older clients produce the same hash
+	if topic.PendingOwner != "" {
+		params = append(params, encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: topic.PendingOwner})
+	}
+	return encoder.EncodeBytes(params...)
 }
 
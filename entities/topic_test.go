@@ -1,6 +1,7 @@
 package entities
 
 import (
+	"crypto/ed25519"
 	"encoding/hex"
 	"testing"
 
@@ -8,32 +9,47 @@ import (
 	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
 )
 
-// TestHelloName calls greetings.Hello with a name, checking
-// for a valid return value.
+func testTopic() Topic {
+	public := true
+	role := constants.SubscriberRole(10)
+	return Topic{
+		ID:                    "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d",
+		Ref:                   "general",
+		Meta:                  "General discussion",
+		Public:                &public,
+		DefaultSubscriberRole: &role,
+		Subnet:                "6f1c9a8e-3b2d-4c5e-9f10-2a3b4c5d6e7f",
+		Timestamp:             1705392177894,
+	}
+}
+
+// TestEncodeTopicBytes guards the encoding agents sign, changing it invalidates every signed topic
 func TestEncodeTopicBytes(t *testing.T) {
-	agent := "0xe652d28F89A28adb89e674a6b51852D0C341Ebe9"
-	topicIds := "*"
-	ts := uint64(1705392177894)
-	privilege := constants.AdminPriviledge
-    auth := Authority{Agent: agent, TopicIds: topicIds, Timestamp: ts,  Priviledge: privilege, }
-	hash := hex.EncodeToString(auth.GetHash())
-	expected := "7da1e026b7a8dc545e2f84cca2eea963807e701aa03ad4b64b1ab447304460e9"
-    if hash != expected {
-        t.Fatalf("Hash should match \n%s \n%s", hash, expected )
-    }
+	hash, err := testTopic().GetHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "45df54843846ac0d45e9e80a4e1771c88ee5a6add7e4af86e5cad7b98cfc2243"
+	if hex.EncodeToString(hash) != expected {
+		t.Fatalf("Hash should match \n%s \n%s", hex.EncodeToString(hash), expected)
+	}
 }
 
 func TestCreateTopic(t *testing.T) {
-	agent := "0xe652d28F89A28adb89e674a6b51852D0C341Ebe9"
-	topicIds := "*"
-	ts := uint64(1705392177894)
-	privilege := constants.AdminPriviledge
-    auth := Authorization{Agent: agent, TopicIds: topicIds, Timestamp: ts,  Priviledge: privilege, }
-	// hash := hex.EncodeToString(auth.GetHash())
-	encoded, err := auth.EncodeBytes();
-	sign := "3044022073761e96c0d29d109ba2290fea1821bf093155d7549956a8144d04f9209f6c1b022048d4635f7e2f3e2864b2c1fe695badad548483ce1988f8bf0c04d5caf4131829"
-	valid, err := crypto.VerifySignatureEDD("02ebec9d95769bb3d71712f0bf1e7e88b199fc945f67f908bbab81e9b7cb1092d8", encoded, sign)
-    if !valid {
-        t.Fatalf("Invalid signature signer: %v", err )
-    }
-}
\ No newline at end of file
+	agent := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	topic := testTopic()
+	encoded, err := topic.EncodeBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := ed25519.Sign(agent, crypto.Sha256(encoded))
+	valid, err := crypto.VerifySignatureEDD(agent.Public().(ed25519.PublicKey), &encoded, sign)
+	if !valid {
+		t.Fatalf("Invalid signature signer: %v", err)
+	}
+	topic.Ref = "other"
+	encoded, _ = topic.EncodeBytes()
+	if valid, _ := crypto.VerifySignatureEDD(agent.Public().(ed25519.PublicKey), &encoded, sign); valid {
+		t.Fatal("signature should not verify a changed ref")
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"strings"
 
 	"github.com/mlayerprotocol/go-mlayer/common/encoder"
@@ -48,9 +49,27 @@ func (d *Wallet) BeforeCreate(tx *gorm.DB) (err error) {
 	return nil
 }
 
-// func (e *Wallet) Key() string {
-// 	return fmt.Sprintf("/%s", e.ID)
-// }
+func (e *Wallet) Key() string {
+	if e.ID == "" {
+		e.ID, _ = GetId(*e, "")
+	}
+	return fmt.Sprintf("%s/id/%s", WalletModel, e.ID)
+}
+
+func (e *Wallet) DataKey() string {
+	return fmt.Sprintf(DataKey, WalletModel, e.Event.ID)
+}
+
+func (e *Wallet) SubnetWalletsKey() string {
+	return fmt.Sprintf("%s/snet/%s", WalletModel, e.Subnet)
+}
+
+func (e *Wallet) GetKeys() (keys []string) {
+	keys = append(keys, e.Key())
+	keys = append(keys, e.DataKey())
+	keys = append(keys, fmt.Sprintf("%s/%s", e.SubnetWalletsKey(), e.ID))
+	return keys
+}
 
 func (e *Wallet) ToJSON() []byte {
 	m, err := json.Marshal(e)
@@ -65,6 +84,12 @@ func (e *Wallet) MsgPack() []byte {
 	return b
 }
 
+func UnpackWallet(b []byte) (Wallet, error) {
+	var e Wallet
+	err := encoder.MsgPackUnpackStruct(b, &e)
+	return e, err
+}
+
 func WalletFromJSON(b []byte) (Event, error) {
 	var e Event
 	// if err := json.Unmarshal(b, &message); err != nil {
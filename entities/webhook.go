@@ -0,0 +1,132 @@
+package entities
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mlayerprotocol/go-mlayer/common/encoder"
+	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
+)
+
+// Webhook is a node local registration that forwards a subnets finalized events to Url
+type Webhook struct {
+	ID            string        `json:"id,omitempty"`
+	Subnet        string        `json:"snet"`
+	Url           string        `json:"url" binding:"required"`
+	Models        []EntityModel `json:"models,omitempty"`
+	EventTypes    []uint16      `json:"ty,omitempty"`
+	Account       DIDString     `json:"acct" binding:"required"`
+	Timestamp     uint64        `json:"ts" binding:"required"`
+	SignatureData SignatureData `json:"sigD"`
+}
+
+type WebhookDelivery struct {
+	Webhook    string `json:"wh"`
+	Event      string `json:"e"`
+	Attempt    int    `json:"n"`
+	StatusCode int    `json:"code"`
+	Error      string `json:"err,omitempty"`
+	Timestamp  uint64 `json:"ts"`
+	DeadLetter bool   `json:"dl"`
+}
+
+func (w *Webhook) Key() string {
+	return fmt.Sprintf("%s/%s", SubnetWebhooksKey(w.Subnet), w.ID)
+}
+
+func SubnetWebhooksKey(subnet string) string {
+	return fmt.Sprintf("wh/%s", subnet)
+}
+
+func (d *WebhookDelivery) Key() string {
+	return fmt.Sprintf("%s/%015d/%s/%d", WebhookDeliveriesKey(d.Webhook), d.Timestamp, d.Event, d.Attempt)
+}
+
+func WebhookDeliveriesKey(webhook string) string {
+	return fmt.Sprintf("wha/%s", webhook)
+}
+
+func (d *WebhookDelivery) DeadLetterKey() string {
+	return fmt.Sprintf("%s/%s", WebhookDeadLettersKey(d.Webhook), d.Event)
+}
+
+func WebhookDeadLettersKey(webhook string) string {
+	return fmt.Sprintf("whdl/%s", webhook)
+}
+
+// Matches reports whether the event passes the webhooks model and event type filters
+func (w *Webhook) Matches(event *Event) bool {
+	if event.Subnet != w.Subnet {
+		return false
+	}
+	if len(w.Models) > 0 && !slices.Contains(w.Models, event.GetDataModelType()) {
+		return false
+	}
+	if len(w.EventTypes) > 0 && !slices.Contains(w.EventTypes, event.EventType) {
+		return false
+	}
+	return true
+}
+
+func (w Webhook) EncodeBytes() ([]byte, error) {
+	models := []string{}
+	for _, m := range w.Models {
+		models = append(models, string(m))
+	}
+	types := []string{}
+	for _, t := range w.EventTypes {
+		types = append(types, fmt.Sprint(t))
+	}
+	return encoder.EncodeBytes(
+		encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: w.Subnet},
+		encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: w.Url},
+		encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: strings.Join(models, ",")},
+		encoder.EncoderParam{Type: encoder.StringEncoderDataType, Value: strings.Join(types, ",")},
+		encoder.EncoderParam{Type: encoder.IntEncoderDataType, Value: w.Timestamp},
+	)
+}
+
+func (w Webhook) GetHash() ([]byte, error) {
+	b, err := w.EncodeBytes()
+	if err != nil {
+		return []byte(""), err
+	}
+	return crypto.Sha256(b), nil
+}
+
+// GetId derives a stable id from the registration hash
+func (w Webhook) GetId() (string, error) {
+	hash, err := w.GetHash()
+	if err != nil {
+		return "", err
+	}
+	u, err := uuid.FromBytes(hash[:16])
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (w *Webhook) MsgPack() []byte {
+	b, _ := encoder.MsgPackStruct(w)
+	return b
+}
+
+func UnpackWebhook(b []byte) (Webhook, error) {
+	var w Webhook
+	err := encoder.MsgPackUnpackStruct(b, &w)
+	return w, err
+}
+
+func (d *WebhookDelivery) MsgPack() []byte {
+	b, _ := encoder.MsgPackStruct(d)
+	return b
+}
+
+func UnpackWebhookDelivery(b []byte) (WebhookDelivery, error) {
+	var d WebhookDelivery
+	err := encoder.MsgPackUnpackStruct(b, &d)
+	return d, err
+}
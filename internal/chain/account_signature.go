@@ -0,0 +1,71 @@
+package chain
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/common/encoder"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
+)
+
+/*
+Verify that signer signed msg for the action on identifier, using the message format of the signature type.
+Typed data (EIP-712) signatures are not covered, their message is the typed data hash itself
+*/
+func VerifyAccountSignature(sigData entities.SignatureData, signer string, action string, identifier string, msg []byte, chainId configs.ChainId) (bool, error) {
+	switch sigData.Type {
+	case entities.EthereumPubKey:
+		authMsg := fmt.Sprintf(constants.SignatureMessageString, action, identifier, chainId, encoder.ToBase64Padded(msg))
+		msgByte := crypto.EthMessage([]byte(authMsg))
+		return crypto.VerifySignatureECC(signer, &msgByte, sigData.Signature), nil
+	case entities.EIP1271PubKey:
+		authMsg := fmt.Sprintf(constants.SignatureMessageString, action, identifier, chainId, encoder.ToBase64Padded(msg))
+		return VerifyContractWalletSignature(chainId, signer, []byte(authMsg), sigData.Signature)
+	case entities.SolanaPubKey, entities.BitcoinPubKey, entities.BitcoinBIP322PubKey:
+		authMsg := fmt.Sprintf(constants.SignatureMessageString, action, identifier, chainId, encoder.ToBase64Padded(msg))
+		return VerifyWalletSignature(sigData, signer, []byte(authMsg))
+	case entities.TendermintsSecp256k1PubKey:
+		decodedSig, err := base64.StdEncoding.DecodeString(sigData.Signature)
+		if err != nil {
+			return false, err
+		}
+		publicKeyBytes, err := base64.RawStdEncoding.DecodeString(sigData.PublicKey)
+		if err != nil {
+			return false, err
+		}
+		authMsg := fmt.Sprintf(constants.SignatureMessageString, action, chainId, identifier, encoder.ToBase64Padded(msg))
+		return crypto.VerifySignatureAmino(encoder.ToBase64Padded([]byte(authMsg)), decodedSig, signer, publicKeyBytes)
+	}
+	return false, nil
+}
+
+/*
+Verify a signMessage signature from a Solana or Bitcoin wallet.
+These wallets sign the readable message itself, so no hashing is done here
+*/
+func VerifyWalletSignature(sigData entities.SignatureData, signer string, authMsg []byte) (bool, error) {
+	switch sigData.Type {
+	case entities.SolanaPubKey:
+		return crypto.VerifySignatureSolana(signer, authMsg, sigData.Signature)
+	case entities.BitcoinPubKey:
+		return crypto.VerifySignatureBitcoin(signer, authMsg, sigData.Signature)
+	case entities.BitcoinBIP322PubKey:
+		return crypto.VerifySignatureBIP322(signer, authMsg, sigData.Signature)
+	}
+	return false, nil
+}
+
+/*
+Verify an EIP-1271 signature of a contract wallet over the same personal message EOAs sign
+*/
+func VerifyContractWalletSignature(chainId configs.ChainId, contract string, authMsg []byte, signature string) (bool, error) {
+	sig, err := hexutil.Decode(signature)
+	if err != nil {
+		return false, err
+	}
+	return VerifyContractSignature(chainId, contract, crypto.Keccak256Hash(crypto.EthMessage(authMsg)), sig)
+}
@@ -80,6 +80,9 @@ type IChainAPI interface {
 	Claimed(validator []byte, cycle *big.Int, index *big.Int) (bool, error) 
 	GetSentryLicenses(operator []byte, cycle *big.Int)  ([]*big.Int, error)
 	GetValidatorLicenses(operator []byte, cycle *big.Int)  ([]*big.Int, error)
+
+	// contract wallets
+	IsValidSignature(contract string, hash [32]byte, signature []byte) (bool, error)
 }
 
 
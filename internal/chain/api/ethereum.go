@@ -1,13 +1,16 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
 	"math/big"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -363,4 +366,26 @@ func (n EthereumAPI) IsValidatorLicenseOwner(address string) (bool, error) {
 func (n EthereumAPI) IsSentryLicenseOwner(address string)  (bool, error) {
 	info, err := n.sentryContract.AccountInfo(nil, common.HexToAddress(address))
 	return len(info.Licenses) > 0, err
-}
\ No newline at end of file
+}
+
+// EIP-1271 magic value returned by isValidSignature
+var erc1271MagicValue = [4]byte{0x16, 0x26, 0xba, 0x7e}
+
+const erc1271ABI = `[{"inputs":[{"name":"hash","type":"bytes32"},{"name":"signature","type":"bytes"}],"name":"isValidSignature","outputs":[{"name":"magicValue","type":"bytes4"}],"stateMutability":"view","type":"function"}]`
+
+func (n EthereumAPI) IsValidSignature(contract string, hash [32]byte, signature []byte) (bool, error) {
+	contractABI, err := abi.JSON(strings.NewReader(erc1271ABI))
+	if err != nil {
+		return false, err
+	}
+	data, err := contractABI.Pack("isValidSignature", hash, signature)
+	if err != nil {
+		return false, err
+	}
+	to := common.HexToAddress(contract)
+	out, err := n.client.CallContract(context.Background(), ethereum.CallMsg{To: &to, Data: data}, nil)
+	if err != nil {
+		return false, err
+	}
+	return len(out) >= 4 && bytes.Equal(out[:4], erc1271MagicValue[:]), nil
+}
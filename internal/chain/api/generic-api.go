@@ -202,4 +202,8 @@ func (n GenericAPI) IsValidatorLicenseOwner(address string) (bool, error) {
 }
 func (n GenericAPI) IsSentryLicenseOwner(address string)  (bool, error) {
 	return true, nil
-}
\ No newline at end of file
+}
+
+func (n GenericAPI) IsValidSignature(contract string, hash [32]byte, signature []byte) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,69 @@
+package chain
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/mlayerprotocol/go-mlayer/configs"
+)
+
+// contract signature results are cached for the cycle they were checked in
+type contractSignatureCache struct {
+	mu      sync.Mutex
+	cycle   uint64
+	results map[string]bool
+}
+
+var contractSignatures = contractSignatureCache{results: map[string]bool{}}
+
+func (c *contractSignatureCache) get(cycle uint64, key string) (valid bool, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cycle != cycle {
+		c.cycle = cycle
+		c.results = map[string]bool{}
+	}
+	valid, found = c.results[key]
+	return valid, found
+}
+
+func (c *contractSignatureCache) set(cycle uint64, key string, valid bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cycle == cycle {
+		c.results[key] = valid
+	}
+}
+
+func currentCycle() uint64 {
+	if NetworkInfo.CurrentCycle == nil {
+		return 0
+	}
+	return NetworkInfo.CurrentCycle.Uint64()
+}
+
+/*
+Verify an EIP-1271 signature by calling isValidSignature on the contract wallet.
+Results are reused until the cycle changes so a wallet's signers can be rotated
+*/
+func VerifyContractSignature(chainId configs.ChainId, contract string, hash []byte, signature []byte) (bool, error) {
+	if len(hash) != 32 {
+		return false, fmt.Errorf("invalid signature hash length")
+	}
+	provider, ok := APIs[chainId]
+	if !ok || provider == nil {
+		return false, fmt.Errorf("no chain provider for %s", chainId)
+	}
+	cycle := currentCycle()
+	key := fmt.Sprintf("%s/%s/%s", contract, hex.EncodeToString(hash), hex.EncodeToString(signature))
+	if valid, found := contractSignatures.get(cycle, key); found {
+		return valid, nil
+	}
+	valid, err := (*provider).IsValidSignature(contract, [32]byte(hash), signature)
+	if err != nil {
+		return false, err
+	}
+	contractSignatures.set(cycle, key, valid)
+	return valid, nil
+}
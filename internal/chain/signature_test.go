@@ -0,0 +1,56 @@
+package chain
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/internal/chain/api"
+)
+
+// stand-in provider for a chain with a single contract wallet
+type contractWalletAPI struct {
+	api.GenericAPI
+	contract  string
+	signature []byte
+	calls     int
+}
+
+func (c *contractWalletAPI) IsValidSignature(contract string, hash [32]byte, signature []byte) (bool, error) {
+	c.calls++
+	return contract == c.contract && bytes.Equal(signature, c.signature), nil
+}
+
+func TestVerifyContractSignature(t *testing.T) {
+	chainId := configs.ChainId("1271")
+	wallet := &contractWalletAPI{contract: "0x5afe", signature: []byte{1, 2, 3}}
+	RegisterProvider(chainId, wallet)
+	NetworkInfo.CurrentCycle = big.NewInt(1)
+	hash := make([]byte, 32)
+
+	valid, err := VerifyContractSignature(chainId, "0x5afe", hash, []byte{1, 2, 3})
+	if err != nil || !valid {
+		t.Fatalf("expected valid signature, got %v %v", valid, err)
+	}
+	valid, _ = VerifyContractSignature(chainId, "0x5afe", hash, []byte{4})
+	if valid {
+		t.Fatal("expected invalid signature")
+	}
+	VerifyContractSignature(chainId, "0x5afe", hash, []byte{1, 2, 3})
+	if wallet.calls != 2 {
+		t.Fatalf("expected cached result within the cycle, got %d calls", wallet.calls)
+	}
+
+	// the wallet rotates its signers in the next cycle
+	wallet.signature = []byte{4}
+	NetworkInfo.CurrentCycle = big.NewInt(2)
+	valid, _ = VerifyContractSignature(chainId, "0x5afe", hash, []byte{1, 2, 3})
+	if valid || wallet.calls != 3 {
+		t.Fatalf("expected cache to expire with the cycle, got %v after %d calls", valid, wallet.calls)
+	}
+
+	if _, err := VerifyContractSignature(configs.ChainId("0"), "0x5afe", hash, []byte{1, 2, 3}); err == nil {
+		t.Fatal("expected error without a provider")
+	}
+}
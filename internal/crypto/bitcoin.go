@@ -0,0 +1,274 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/cosmos/btcutil/base58"
+	"github.com/cosmos/btcutil/bech32"
+	"golang.org/x/crypto/ripemd160"
+)
+
+const bitcoinMessagePrefix = "Bitcoin Signed Message:\n"
+
+// base58 address versions
+const (
+	btcP2PKHMainnet byte = 0x00
+	btcP2SHMainnet  byte = 0x05
+	btcP2PKHTestnet byte = 0x6f
+	btcP2SHTestnet  byte = 0xc4
+)
+
+type bitcoinAddressType int
+
+const (
+	btcP2PKH bitcoinAddressType = iota
+	btcP2SHP2WPKH
+	btcP2WPKH
+)
+
+func doubleSha256(b []byte) []byte {
+	return Sha256(Sha256(b))
+}
+
+func hash160(b []byte) []byte {
+	h := ripemd160.New()
+	h.Write(Sha256(b))
+	return h.Sum(nil)
+}
+
+func writeVarInt(buf *bytes.Buffer, n uint64) {
+	switch {
+	case n < 0xfd:
+		buf.WriteByte(byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(0xfd)
+		binary.Write(buf, binary.LittleEndian, uint16(n))
+	case n <= 0xffffffff:
+		buf.WriteByte(0xfe)
+		binary.Write(buf, binary.LittleEndian, uint32(n))
+	default:
+		buf.WriteByte(0xff)
+		binary.Write(buf, binary.LittleEndian, n)
+	}
+}
+
+func readVarInt(r *bytes.Reader) (uint64, error) {
+	prefix, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch prefix {
+	case 0xfd:
+		var n uint16
+		err = binary.Read(r, binary.LittleEndian, &n)
+		return uint64(n), err
+	case 0xfe:
+		var n uint32
+		err = binary.Read(r, binary.LittleEndian, &n)
+		return uint64(n), err
+	case 0xff:
+		var n uint64
+		err = binary.Read(r, binary.LittleEndian, &n)
+		return n, err
+	}
+	return uint64(prefix), nil
+}
+
+// decodeBitcoinAddress returns the key hash or script hash an address commits to
+func decodeBitcoinAddress(address string) (bitcoinAddressType, []byte, error) {
+	lower := strings.ToLower(address)
+	if strings.HasPrefix(lower, "bc1") || strings.HasPrefix(lower, "tb1") {
+		_, data, err := bech32.Decode(lower, 90)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(data) < 1 || data[0] != 0 {
+			return 0, nil, fmt.Errorf("unsupported segwit version")
+		}
+		program, err := bech32.ConvertBits(data[1:], 5, 8, false)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(program) != 20 {
+			return 0, nil, fmt.Errorf("unsupported segwit program")
+		}
+		return btcP2WPKH, program, nil
+	}
+	payload, version, err := base58.CheckDecode(address)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(payload) != 20 {
+		return 0, nil, fmt.Errorf("invalid bitcoin address %s", address)
+	}
+	switch version {
+	case btcP2PKHMainnet, btcP2PKHTestnet:
+		return btcP2PKH, payload, nil
+	case btcP2SHMainnet, btcP2SHTestnet:
+		return btcP2SHP2WPKH, payload, nil
+	}
+	return 0, nil, fmt.Errorf("unsupported bitcoin address version %d", version)
+}
+
+func IsBitcoinAddress(address string) bool {
+	_, _, err := decodeBitcoinAddress(address)
+	return err == nil
+}
+
+// bitcoinAddressMatches reports whether a public key controls the address
+func bitcoinAddressMatches(address string, publicKey []byte) (bool, error) {
+	addrType, program, err := decodeBitcoinAddress(address)
+	if err != nil {
+		return false, err
+	}
+	keyHash := hash160(publicKey)
+	if addrType == btcP2SHP2WPKH {
+		return bytes.Equal(program, hash160(append([]byte{0x00, 0x14}, keyHash...))), nil
+	}
+	return bytes.Equal(program, keyHash), nil
+}
+
+// BitcoinMessageHash is the digest legacy wallets sign for signmessage
+func BitcoinMessageHash(message []byte) []byte {
+	var buf bytes.Buffer
+	writeVarInt(&buf, uint64(len(bitcoinMessagePrefix)))
+	buf.WriteString(bitcoinMessagePrefix)
+	writeVarInt(&buf, uint64(len(message)))
+	buf.Write(message)
+	return doubleSha256(buf.Bytes())
+}
+
+/*
+Verify a legacy (Bitcoin Core signmessage) signature.
+The signature is the base64 encoded 65 byte compact signature
+*/
+func VerifySignatureBitcoin(address string, message []byte, signature string) (bool, error) {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, err
+	}
+	if len(sig) != 65 {
+		return false, fmt.Errorf("invalid bitcoin signature length")
+	}
+	// bip137 flags segwit keys with headers 35-38 for P2SH-P2WPKH and 39-42 for P2WPKH, both are compressed keys
+	header := sig[0]
+	switch {
+	case header >= 35 && header <= 38:
+		sig[0] = header - 4
+	case header >= 39 && header <= 42:
+		sig[0] = header - 8
+	}
+	publicKey, compressed, err := btcec.RecoverCompact(btcec.S256(), sig, BitcoinMessageHash(message))
+	if err != nil {
+		return false, err
+	}
+	serialized := publicKey.SerializeUncompressed()
+	if compressed {
+		serialized = publicKey.SerializeCompressed()
+	}
+	return bitcoinAddressMatches(address, serialized)
+}
+
+// bip322MessageHash is the BIP-340 tagged hash of the message
+func bip322MessageHash(message []byte) []byte {
+	tag := Sha256([]byte("BIP0322-signed-message"))
+	return Sha256(append(append(append([]byte{}, tag...), tag...), message...))
+}
+
+func bip322ToSpendId(scriptPubKey []byte, message []byte) []byte {
+	var tx bytes.Buffer
+	binary.Write(&tx, binary.LittleEndian, uint32(0))
+	writeVarInt(&tx, 1)
+	tx.Write(make([]byte, 32))
+	binary.Write(&tx, binary.LittleEndian, uint32(0xffffffff))
+	scriptSig := append([]byte{0x00, 0x20}, bip322MessageHash(message)...)
+	writeVarInt(&tx, uint64(len(scriptSig)))
+	tx.Write(scriptSig)
+	binary.Write(&tx, binary.LittleEndian, uint32(0))
+	writeVarInt(&tx, 1)
+	binary.Write(&tx, binary.LittleEndian, uint64(0))
+	writeVarInt(&tx, uint64(len(scriptPubKey)))
+	tx.Write(scriptPubKey)
+	binary.Write(&tx, binary.LittleEndian, uint32(0))
+	return doubleSha256(tx.Bytes())
+}
+
+// bip322SigHash is the BIP-143 digest of the to_sign transaction for a P2WPKH input
+func bip322SigHash(keyHash []byte, toSpendId []byte, hashType uint32) []byte {
+	var prevouts bytes.Buffer
+	prevouts.Write(toSpendId)
+	binary.Write(&prevouts, binary.LittleEndian, uint32(0))
+
+	var outputs bytes.Buffer
+	binary.Write(&outputs, binary.LittleEndian, uint64(0))
+	writeVarInt(&outputs, 1)
+	outputs.WriteByte(0x6a) // OP_RETURN
+
+	scriptCode := append(append([]byte{0x19, 0x76, 0xa9, 0x14}, keyHash...), 0x88, 0xac)
+
+	var preimage bytes.Buffer
+	binary.Write(&preimage, binary.LittleEndian, uint32(0))
+	preimage.Write(doubleSha256(prevouts.Bytes()))
+	preimage.Write(doubleSha256(make([]byte, 4)))
+	preimage.Write(prevouts.Bytes())
+	preimage.Write(scriptCode)
+	binary.Write(&preimage, binary.LittleEndian, uint64(0))
+	binary.Write(&preimage, binary.LittleEndian, uint32(0))
+	preimage.Write(doubleSha256(outputs.Bytes()))
+	binary.Write(&preimage, binary.LittleEndian, uint32(0))
+	binary.Write(&preimage, binary.LittleEndian, hashType)
+	return doubleSha256(preimage.Bytes())
+}
+
+/*
+Verify a BIP-322 simple signature for a native segwit (P2WPKH) address.
+The signature is the base64 encoded witness stack of the to_sign transaction
+*/
+func VerifySignatureBIP322(address string, message []byte, signature string) (bool, error) {
+	addrType, keyHash, err := decodeBitcoinAddress(address)
+	if err != nil {
+		return false, err
+	}
+	if addrType != btcP2WPKH {
+		return false, fmt.Errorf("bip322 signatures are only supported for p2wpkh addresses")
+	}
+	witness, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, err
+	}
+	r := bytes.NewReader(witness)
+	count, err := readVarInt(r)
+	if err != nil || count != 2 {
+		return false, fmt.Errorf("invalid bip322 witness")
+	}
+	items := [][]byte{}
+	for i := 0; i < 2; i++ {
+		size, err := readVarInt(r)
+		if err != nil || size > uint64(r.Len()) {
+			return false, fmt.Errorf("invalid bip322 witness")
+		}
+		item := make([]byte, size)
+		r.Read(item)
+		items = append(items, item)
+	}
+	sigBytes, publicKeyBytes := items[0], items[1]
+	if len(sigBytes) < 2 || !bytes.Equal(hash160(publicKeyBytes), keyHash) {
+		return false, nil
+	}
+	publicKey, err := btcec.ParsePubKey(publicKeyBytes, btcec.S256())
+	if err != nil {
+		return false, err
+	}
+	parsedSig, err := btcec.ParseDERSignature(sigBytes[:len(sigBytes)-1], btcec.S256())
+	if err != nil {
+		return false, err
+	}
+	scriptPubKey := append([]byte{0x00, 0x14}, keyHash...)
+	hash := bip322SigHash(keyHash, bip322ToSpendId(scriptPubKey, message), uint32(sigBytes[len(sigBytes)-1]))
+	return parsedSig.Verify(hash, publicKey), nil
+}
@@ -1,10 +1,15 @@
 package crypto
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
 	"testing"
 
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/cosmos/btcutil/base58"
+	"github.com/cosmos/btcutil/bech32"
 	"github.com/mlayerprotocol/go-mlayer/common/encoder"
 )
 
@@ -235,4 +240,88 @@ func TestVerifySignatureAmino(t *testing.T) {
         t.Fatalf("Invalid signature signer: %v", err )
     }
 }
-//{"account_number":"0","chain_id":"","fee":{"amount":[],"gas":"0"},"memo":"","msgs":[{"type":"sign/MsgSignData","value":{"data":"aGVsbG93b3JsZA==","signer":"cosomos1z7pux6petf6fvngdkap0cpyneztj5wwm2maten"}}],"sequence":"0"}
\ No newline at end of file
+//{"account_number":"0","chain_id":"","fee":{"amount":[],"gas":"0"},"memo":"","msgs":[{"type":"sign/MsgSignData","value":{"data":"aGVsbG93b3JsZA==","signer":"cosomos1z7pux6petf6fvngdkap0cpyneztj5wwm2maten"}}],"sequence":"0"}
+
+func TestVerifySignatureSolana(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	address := base58.Encode(pub)
+	msg := []byte("write_authorization")
+	sig := ed25519.Sign(priv, msg)
+	for _, encoded := range []string{base64.StdEncoding.EncodeToString(sig), base58.Encode(sig)} {
+		valid, err := VerifySignatureSolana(address, msg, encoded)
+		if err != nil || !valid {
+			t.Fatalf("expected valid signature, got %v %v", valid, err)
+		}
+	}
+	valid, _ := VerifySignatureSolana(address, []byte("other"), base58.Encode(sig))
+	if valid {
+		t.Fatal("signature should not verify a different message")
+	}
+}
+
+func TestVerifySignatureBitcoin(t *testing.T) {
+	privKey, _ := btcec.NewPrivateKey(btcec.S256())
+	address := base58.CheckEncode(hash160(privKey.PubKey().SerializeCompressed()), btcP2PKHMainnet)
+	msg := []byte("write_authorization")
+	sig, err := btcec.SignCompact(btcec.S256(), privKey, BitcoinMessageHash(msg), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err := VerifySignatureBitcoin(address, msg, base64.StdEncoding.EncodeToString(sig))
+	if err != nil || !valid {
+		t.Fatalf("expected valid signature, got %v %v", valid, err)
+	}
+	valid, _ = VerifySignatureBitcoin(address, []byte("other"), base64.StdEncoding.EncodeToString(sig))
+	if valid {
+		t.Fatal("signature should not verify a different message")
+	}
+}
+
+func TestVerifySignatureBitcoinSegwitHeaders(t *testing.T) {
+	privKey, _ := btcec.NewPrivateKey(btcec.S256())
+	keyHash := hash160(privKey.PubKey().SerializeCompressed())
+	program, _ := bech32.ConvertBits(keyHash, 8, 5, true)
+	nativeAddress, _ := bech32.Encode("bc", append([]byte{0x00}, program...))
+	nestedAddress := base58.CheckEncode(hash160(append([]byte{0x00, 0x14}, keyHash...)), btcP2SHMainnet)
+	msg := []byte("write_authorization")
+	sig, err := btcec.SignCompact(btcec.S256(), privKey, BitcoinMessageHash(msg), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// SignCompact returns headers 31-34 for compressed keys
+	withHeader := func(offset byte) string {
+		b := append([]byte{}, sig...)
+		b[0] += offset
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	valid, err := VerifySignatureBitcoin(nestedAddress, msg, withHeader(4))
+	if err != nil || !valid {
+		t.Fatalf("expected a valid P2SH-P2WPKH signature, got %v %v", valid, err)
+	}
+	valid, err = VerifySignatureBitcoin(nativeAddress, msg, withHeader(8))
+	if err != nil || !valid {
+		t.Fatalf("expected a valid P2WPKH signature, got %v %v", valid, err)
+	}
+	if valid, _ := VerifySignatureBitcoin(nativeAddress, msg, withHeader(12)); valid {
+		t.Fatal("headers above 42 are not defined by BIP-137")
+	}
+}
+
+func TestVerifySignatureBIP322(t *testing.T) {
+	// test vectors from BIP-322
+	address := "bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l"
+	vectors := map[string]string{
+		"":            "AkcwRAIgM2gBAQqvZX15ZiysmKmQpDrG83avLIT492QBzLnQIxYCIBaTpOaD20qRlEylyxFSeEA2ba9YOixpX8z46TSDtS40ASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=",
+		"Hello World": "AkcwRAIgZRfIY3p7/DoVTty6YZbWS71bc5Vct9p9Fia83eRmw2QCICK/ENGfwLtptFluMGs2KsqoNSk89pO7F29zJLUx9a/sASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=",
+	}
+	for msg, sig := range vectors {
+		valid, err := VerifySignatureBIP322(address, []byte(msg), sig)
+		if err != nil || !valid {
+			t.Fatalf("expected valid signature for %q, got %v %v", msg, valid, err)
+		}
+	}
+	valid, _ := VerifySignatureBIP322(address, []byte("other"), vectors["Hello World"])
+	if valid {
+		t.Fatal("signature should not verify a different message")
+	}
+}
@@ -0,0 +1,50 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/cosmos/btcutil/base58"
+)
+
+// SolanaPublicKey decodes a base58 Solana address into its ed25519 public key
+func SolanaPublicKey(address string) (ed25519.PublicKey, error) {
+	key := base58.Decode(address)
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid solana address %s", address)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+func IsSolanaAddress(address string) bool {
+	_, err := SolanaPublicKey(address)
+	return err == nil
+}
+
+// DecodeSolanaSignature accepts the base64 or base58 encodings wallets return from signMessage
+func DecodeSolanaSignature(signature string) ([]byte, error) {
+	if b, err := base64.StdEncoding.DecodeString(signature); err == nil && len(b) == ed25519.SignatureSize {
+		return b, nil
+	}
+	if b := base58.Decode(signature); len(b) == ed25519.SignatureSize {
+		return b, nil
+	}
+	return nil, fmt.Errorf("invalid solana signature")
+}
+
+/*
+Verify a message signed with a Solana wallets signMessage.
+Unlike VerifySignatureEDD the raw message is signed, not its hash
+*/
+func VerifySignatureSolana(address string, message []byte, signature string) (bool, error) {
+	publicKey, err := SolanaPublicKey(address)
+	if err != nil {
+		return false, err
+	}
+	sig, err := DecodeSolanaSignature(signature)
+	if err != nil {
+		return false, err
+	}
+	return ed25519.Verify(publicKey, message, sig), nil
+}
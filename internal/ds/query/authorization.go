@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/query"
@@ -92,9 +93,9 @@ func CreateAuthorizationState(newState *entities.Authorization, tx *datastore.Tx
 		defer txn.Discard(context.Background())
 	}
 	
-	// Delete old account keys
+	// Delete old account keys, whatever topics the old state was scoped to
 	accountRsl,  err := txn.Query(context.Background(), query.Query{
-		Prefix: newState.AccountAuthorizationsKey(),
+		Prefix: (&entities.Authorization{Account: newState.Account, Subnet: newState.Subnet, Agent: newState.Agent}).AccountAuthorizationsKey(),
 	})
 	if err != nil && !IsErrorNotFound(err) {
 		return nil, err
@@ -135,6 +136,12 @@ func CreateAuthorizationState(newState *entities.Authorization, tx *datastore.Tx
 	entries2, _ := agentRsl.Rest()
 	for _, entry := range entries2 { 
 		txn.Delete(context.Background(), datastore.NewKey(entry.Key))
+		// the id key of a state scoped to other topics is not overwritten by the new state
+		if value, err := txn.Get(context.Background(), datastore.NewKey((&entities.Authorization{Event: entities.EventPath{EntityPath: entities.EntityPath{ID: string(entry.Value)}}}).DataKey())); err == nil {
+			if previous, err := entities.UnpackAuthorization(value); err == nil && previous.Key() != newState.Key() {
+				txn.Delete(context.Background(), datastore.NewKey(previous.Key()))
+			}
+		}
 	}
 
 	id, err :=  entities.GetId(newState, newState.ID)
@@ -152,7 +159,7 @@ func CreateAuthorizationState(newState *entities.Authorization, tx *datastore.Tx
 		Data: stateBytes,
 		EventHash: newState.Event.ID,
 		RestKeyValue: []byte(newState.Event.ID),
-	}, tx)
+	}, &txn)
 	if err != nil {
 		return nil, err
 	}
@@ -272,6 +279,44 @@ func GetAgentAuthorizationStates(subnet string, agent entities.DeviceString, lim
 	return rsl, err
 }
 
+/*
+GetCurrentAuthorization returns the current authorization of auth.Agent by auth.Account in auth.Subnet.
+Unlike the account authorizations index, the agent state index only ever holds the latest state, whatever its topics
+*/
+func GetCurrentAuthorization(auth entities.Authorization, txn *datastore.Txn) (*entities.Authorization, error) {
+	prefix := (&entities.Authorization{Account: auth.Account, Subnet: auth.Subnet, Agent: auth.Agent}).AuthorizedAgentStateKey()
+	var rsl query.Results
+	var err error
+	if txn != nil {
+		rsl, err = (*txn).Query(context.Background(), query.Query{Prefix: prefix})
+	} else {
+		rsl, err = stores.StateStore.Query(context.Background(), query.Query{Prefix: prefix})
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries, err := rsl.Rest()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, datastore.ErrNotFound
+	}
+	eventPath := entities.EventPath{EntityPath: entities.EntityPath{ID: string(entries[len(entries)-1].Value)}}
+	if txn == nil {
+		return GetAuthorizationByEvent(eventPath)
+	}
+	value, err := (*txn).Get(context.Background(), datastore.NewKey((&entities.Authorization{Event: eventPath}).DataKey()))
+	if err != nil {
+		return nil, err
+	}
+	data, err := entities.UnpackAuthorization(value)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
 func GetAuthorizationByEvent( event entities.EventPath) (*entities.Authorization, error) {
 	ds :=  stores.StateStore
 	
@@ -287,4 +332,128 @@ func GetAuthorizationByEvent( event entities.EventPath) (*entities.Authorization
 }
 
 // auth/agt/did:0x99E904417f7e69505c738CB24F66EBeF688AB19d/fb6d5a3d-3d1c-4051-9577-9bd9d13fd20e/did:0x59fD8f94dDd1Fe6066d300F74afD5E3a01970e43/20241111093301000
-// auth/agt/did:0x59fD8f94dDd1Fe6066d300F74afD5E3a01970e43/fb6d5a3d-3d1c-4051-9577-9bd9d13fd20e/did:0x73d67D769f10b860e51B5234D467624930D36Ec1
\ No newline at end of file
+// auth/agt/did:0x59fD8f94dDd1Fe6066d300F74afD5E3a01970e43/fb6d5a3d-3d1c-4051-9577-9bd9d13fd20e/did:0x73d67D769f10b860e51B5234D467624930D36Ec1
+
+// GetAuthorizationHistory returns the grants and revocations matching auth, most recent first
+func GetAuthorizationHistory(auth entities.Authorization, limits *QueryLimit) (data []*entities.Authorization, err error) {
+	if limits == nil {
+		limits = DefaultQueryLimit
+	}
+	rsl, err := stores.StateStore.Query(context.Background(), query.Query{
+		Prefix: auth.HistoryKey(),
+		Orders: []query.Order{query.OrderByKeyDescending{}},
+		Limit:  limits.Limit,
+		Offset: limits.Offset,
+	})
+	if err != nil {
+		return data, err
+	}
+	entries, err := rsl.Rest()
+	if err != nil {
+		return data, err
+	}
+	for _, entry := range entries {
+		value, qerr := GetAuthorizationByEvent(entities.EventPath{EntityPath: entities.EntityPath{ID: string(entry.Value)}})
+		if qerr != nil {
+			continue
+		}
+		data = append(data, value)
+	}
+	return data, nil
+}
+
+// GetExpiringAuthorizations returns queued timed authorizations that lapse at or before the given unix milli time
+func GetExpiringAuthorizations(before uint64) (data []*entities.Authorization, err error) {
+	rsl, err := stores.StateStore.Query(context.Background(), query.Query{
+		Prefix: entities.AuthorizationExpiryQueueKey(),
+		Orders: []query.Order{query.OrderByKey{}},
+	})
+	if err != nil {
+		return data, err
+	}
+	defer rsl.Close()
+	bound := datastore.NewKey(fmt.Sprintf("%s/%020d", entities.AuthorizationExpiryQueueKey(), before)).String()
+	for entry := range rsl.Next() {
+		if entry.Error != nil {
+			return data, entry.Error
+		}
+		if entry.Key > bound && !strings.HasPrefix(entry.Key, bound) {
+			break
+		}
+		value, qerr := GetAuthorizationByEvent(entities.EventPath{EntityPath: entities.EntityPath{ID: string(entry.Value)}})
+		if qerr != nil {
+			continue
+		}
+		data = append(data, value)
+	}
+	return data, nil
+}
+
+const expiryQueueKeyVersionKey = "auth/expqv"
+const expiryQueueKeyVersion = "1"
+
+/*
+RekeyExpiryQueue moves authorizations queued under local time expiry keys to the zero padded unix milli keys, so they sort by expiry again.
+It runs once, later calls return immediately. A run that stops half way resumes from its last committed chunk
+*/
+func RekeyExpiryQueue() error {
+	m, err := startMigration(stores.StateStore, expiryQueueKeyVersionKey, expiryQueueKeyVersion)
+	if err != nil || m == nil {
+		return err
+	}
+	defer m.Discard()
+	rsl, err := stores.StateStore.Query(context.Background(), query.Query{
+		Prefix: entities.AuthorizationExpiryQueueKey(),
+		Orders: []query.Order{query.OrderByKey{}},
+	})
+	if err != nil {
+		return err
+	}
+	defer rsl.Close()
+	for entry := range rsl.Next() {
+		if entry.Error != nil {
+			return entry.Error
+		}
+		if m.Migrated(0, entry.Key) {
+			continue
+		}
+		auth, err := GetAuthorizationByEvent(entities.EventPath{EntityPath: entities.EntityPath{ID: string(entry.Value)}})
+		if err != nil {
+			logger.Errorf("RekeyExpiryQueue: %s: %v", entry.Key, err)
+			continue
+		}
+		key := datastore.NewKey(auth.ExpiryQueueKey())
+		if key.String() == entry.Key {
+			continue
+		}
+		if err := m.Put(key, entry.Value); err != nil {
+			return err
+		}
+		if err := m.Delete(datastore.NewKey(entry.Key)); err != nil {
+			return err
+		}
+		if err := m.Checkpoint(0, entry.Key); err != nil {
+			return err
+		}
+	}
+	return m.Finish()
+}
+
+func RemoveFromExpiryQueue(auth *entities.Authorization) error {
+	return stores.StateStore.Delete(context.Background(), datastore.NewKey(auth.ExpiryQueueKey()))
+}
+
+func SetAuthorizationExpiryStatus(eventId string, status string) error {
+	return stores.StateStore.Put(context.Background(), datastore.NewKey(entities.AuthorizationExpiryStatusKey(eventId)), []byte(status))
+}
+
+func GetAuthorizationExpiryStatus(eventId string) (string, error) {
+	value, err := stores.StateStore.Get(context.Background(), datastore.NewKey(entities.AuthorizationExpiryStatusKey(eventId)))
+	if err != nil {
+		if IsErrorNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return string(value), nil
+}
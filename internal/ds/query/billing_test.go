@@ -0,0 +1,44 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+	"github.com/mlayerprotocol/go-mlayer/pkg/core/ds"
+)
+
+func TestBillSubnetOncePerEvent(t *testing.T) {
+	ctx := context.Background()
+	store, err := ds.NewDatastore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := stores.EventStore
+	stores.EventStore = store
+	t.Cleanup(func() {
+		stores.EventStore = previous
+		store.Close()
+	})
+	// the second event re-commits the first
+	for _, id := range []string{"e1", "e1", "e2"} {
+		txn, err := store.NewTransaction(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := billSubnet(&entities.Event{ID: id, Subnet: "s1", Cycle: 3}, txn); err != nil {
+			t.Fatal(err)
+		}
+		if err := txn.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	usage, err := GetSubnetUsage("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 1 || *usage[0].Cycle != 3 || *usage[0].Count != 2 {
+		t.Fatalf("expected 2 billed events in cycle 3, got %+v", usage)
+	}
+}
@@ -0,0 +1,48 @@
+package query
+
+import (
+	"context"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+)
+
+// SaveEventConflict records the event that lost a conflicting update to an entity
+func SaveEventConflict(conflict *entities.EventConflict, txn datastore.Txn) error {
+	if txn == nil {
+		return stores.StateStore.Put(context.Background(), datastore.NewKey(conflict.Key()), conflict.MsgPack())
+	}
+	return txn.Put(context.Background(), datastore.NewKey(conflict.Key()), conflict.MsgPack())
+}
+
+// GetEntityConflicts returns the conflicts recorded for the entity, most recent first
+func GetEntityConflicts(model entities.EntityModel, id string, limits *QueryLimit) (data []entities.EventConflict, err error) {
+	if limits == nil {
+		limits = DefaultQueryLimit
+	}
+	rsl, err := stores.StateStore.Query(context.Background(), query.Query{
+		Prefix: entities.EntityConflictsKey(model, id),
+		Orders: []query.Order{query.OrderByKeyDescending{}},
+		Limit:  limits.Limit,
+		Offset: limits.Offset,
+	})
+	if err != nil {
+		return data, err
+	}
+	entries, err := rsl.Rest()
+	if err != nil {
+		return data, err
+	}
+	data = []entities.EventConflict{}
+	for _, entry := range entries {
+		conflict, uerr := entities.UnpackEventConflict(entry.Value)
+		if uerr != nil {
+			logger.Errorf("UnpackEventConflict: %v", uerr)
+			continue
+		}
+		data = append(data, conflict)
+	}
+	return data, nil
+}
@@ -15,6 +15,9 @@ type DataStates struct {
 	Events map[string]entities.Event
 	CurrentStates map[entities.EntityPath]interface{}
 	HistoricState map[entities.EntityPath][]byte
+	Conflicts []entities.EventConflict
+	// LostNonces are saved events that lost their nonce to an earlier event, the states they applied are rolled back on commit
+	LostNonces []entities.Event
 	Config *configs.MainConfiguration
 	DataCount uint16
 }
@@ -70,6 +73,18 @@ func (ds *DataStates) AddHistoricState( model entities.EntityModel, id string, s
 	
 }
 
+// AddConflict records an event that lost a conflicting update, it is saved with the states
+func (ds *DataStates) AddConflict(conflict entities.EventConflict) {
+	ds.Conflicts = append(ds.Conflicts, conflict)
+}
+
+// AddLostNonce rejects a saved event whose nonce an earlier event used and rolls back what it applied
+func (ds *DataStates) AddLostNonce(event entities.Event) {
+	event.IsValid = utils.FalsePtr()
+	ds.AddEvent(event)
+	ds.LostNonces = append(ds.LostNonces, event)
+}
+
 func (ds *DataStates) Commit(stateTx *datastore.Txn, eventTx *datastore.Txn, messageTx *datastore.Txn) (err  error ) {
 	_stateTxn, err := InitTx(stores.StateStore, stateTx)
 	if err != nil {
@@ -95,6 +110,13 @@ func (ds *DataStates) Commit(stateTx *datastore.Txn, eventTx *datastore.Txn, mes
 		defer _messageTxn.Discard(context.Background())
 	}
 
+	// rolled back states are restored with the current states
+	for i := range ds.LostNonces {
+		if err = ds.rollBackEvent(&ds.LostNonces[i], _stateTxn, _eventTxn, _messageTxn); err != nil {
+			return err
+		}
+	}
+
 	for k, v := range ds.CurrentStates {
 		switch k.Model {
 		case entities.SubnetModel:
@@ -106,6 +128,9 @@ func (ds *DataStates) Commit(stateTx *datastore.Txn, eventTx *datastore.Txn, mes
 		case entities.TopicModel:
 			state := v.(entities.Topic)
 			_, err = UpdateTopicState(k.ID, &state, &_stateTxn, true)
+		case entities.WalletModel:
+			state := v.(entities.Wallet)
+			_, err = UpdateWalletState(k.ID, &state, &_stateTxn, true)
 		case entities.SubscriptionModel:
 			state := v.(entities.Subscription)
 			_, err = CreateSubscriptionState(&state, &_stateTxn)
@@ -119,22 +144,45 @@ func (ds *DataStates) Commit(stateTx *datastore.Txn, eventTx *datastore.Txn, mes
 	}
 
 	for k, v := range ds.HistoricState {
-		 err = SaveHistoricState(k.Model, k.ID, v)
+		historicTxn := _stateTxn
+		if k.Model == entities.MessageModel {
+			historicTxn = _messageTxn
+		}
+		err = SaveHistoricState(k.Model, k.ID, v, historicTxn)
 		if err != nil {
 			return err
 		}
 
 	}
+	for i := range ds.Conflicts {
+		if err = SaveEventConflict(&ds.Conflicts[i], _stateTxn); err != nil {
+			return err
+		}
+	}
 	if len(ds.Events) == 0 {
 		panic("No events")
 	}
 	for _, v := range ds.Events {
-		logger.Debugf("EVENSTTOSAVE", v.Hash)
+		logger.Debugf("EVENSTTOSAVE %s", v.Hash)
+		if len(v.Subnet) > 0 && v.IsValid != nil && *v.IsValid && v.Synced != nil && *v.Synced {
+			// only bill the subnet the first time the event is accepted
+			if err = billSubnet(&v, _eventTxn); err != nil {
+				return err
+			}
+		}
 		err = UpdateEvent(&v, &_eventTxn, true)
 		
 	   if err != nil {
 		   return err
 	   }
+	   if v.IsValid != nil && *v.IsValid && v.Payload.Agent != "" && v.Payload.Nonce > 0 {
+		   if err = UpdateAgentNonce(v.Payload.Account, v.Payload.Agent, v.Payload.Nonce, _stateTxn); err != nil {
+			   return err
+		   }
+		   if err = UseAgentNonce(v.Payload.Account, v.Payload.Agent, v.Payload.Nonce, v.GetPath(), _stateTxn); err != nil {
+			   return err
+		   }
+	   }
 	  //  err = IncrementCounters(v.Cycle, v.Validator, v.Subnet, &_eventTxn)
 
    }
@@ -162,4 +210,19 @@ func (ds *DataStates) Commit(stateTx *datastore.Txn, eventTx *datastore.Txn, mes
 		logger.Error("DatastateCommitError", err)
 	}
 	return err
-}
\ No newline at end of file
+}
+
+// billSubnet counts the event in its subnets usage, in the events transaction, unless it was already billed
+func billSubnet(event *entities.Event, eventTxn datastore.Txn) error {
+	_, err := eventTxn.Get(context.Background(), datastore.NewKey(event.BillingKey()))
+	if err == nil {
+		return nil
+	}
+	if !IsErrorNotFound(err) {
+		return err
+	}
+	if err := eventTxn.Put(context.Background(), datastore.NewKey(event.BillingKey()), []byte{1}); err != nil {
+		return err
+	}
+	return IncrementCounterByKey(entities.SubnetUsageKey(event.Subnet, &event.Cycle), 1, &eventTxn)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -322,7 +323,7 @@ func GetNetworkCounts(subnet *string, limit *QueryLimit) ([]models.EventCounter,
 		}
 		return counts, nil
 	}
-	rsl, err := stores.EventStore.Query(context.Background(), query.Query{
+	rsl, err := stores.NetworkStatsStore.Query(context.Background(), query.Query{
 		Prefix: entities.NetworkCounterKey(subnet),
 		Limit:  limit.Limit,
 		Offset: limit.Offset,
@@ -339,7 +340,7 @@ func GetNetworkCounts(subnet *string, limit *QueryLimit) ([]models.EventCounter,
 		if !ok {
 			break
 		}
-		parts := strings.Split(entry.Key, "/")
+		parts := strings.Split(strings.TrimPrefix(entry.Key, "/"), "/")
 		var subn string = ""
 		if len(parts) == 2 {
 			subn = parts[1]
@@ -391,7 +392,110 @@ func GetStateFromEventPath(path *entities.EventPath) (any, error) {
 	if err != nil {
 		return nil, err
 	}
-	state := entities.GetStateModelFromEntityType(path.Model)
-	err = encoder.MsgPackUnpackStruct(b, &state)
-	return state, err
+	// decode into a pointer to the models type, msgpack can not decode into a struct held by an interface
+	state := reflect.New(reflect.TypeOf(entities.GetStateModelFromEntityType(path.Model)))
+	ptr := state.Interface()
+	err = encoder.MsgPackUnpackStruct(b, &ptr)
+	return state.Elem().Interface(), err
+}
+
+const agentEventKeyVersionKey = "agtkeyv"
+const agentEventKeyVersion = "1"
+
+/*
+RekeyAgentEvents moves the agent event index from local time keys, which dropped the milliseconds, to zero padded unix milli keys.
+It runs once, later calls return immediately. A run that stops half way resumes from its last committed chunk
+*/
+func RekeyAgentEvents() error {
+	m, err := startMigration(stores.EventStore, agentEventKeyVersionKey, agentEventKeyVersion)
+	if err != nil || m == nil {
+		return err
+	}
+	defer m.Discard()
+	// every AgentEventsKey starts with agt
+	rsl, err := stores.EventStore.Query(context.Background(), query.Query{
+		Prefix: "agt",
+		Orders: []query.Order{query.OrderByKey{}},
+	})
+	if err != nil {
+		return err
+	}
+	defer rsl.Close()
+	for entry := range rsl.Next() {
+		if entry.Error != nil {
+			return entry.Error
+		}
+		// key ends with /<time>/<model>/<event id>
+		parts := strings.Split(entry.Key, "/")
+		if len(parts) < 4 || m.Migrated(0, entry.Key) {
+			continue
+		}
+		event, err := GetEventById(parts[len(parts)-1], entities.EntityModel(parts[len(parts)-2]))
+		if err != nil {
+			logger.Errorf("RekeyAgentEvents: %s: %v", entry.Key, err)
+			continue
+		}
+		key := datastore.NewKey(event.AgentEventKey())
+		if key.String() == entry.Key {
+			continue
+		}
+		if err := m.Put(key, entry.Value); err != nil {
+			return err
+		}
+		if err := m.Delete(datastore.NewKey(entry.Key)); err != nil {
+			return err
+		}
+		if err := m.Checkpoint(0, entry.Key); err != nil {
+			return err
+		}
+	}
+	return m.Finish()
+}
+
+// GetAgentLastEvent returns the most recent event the agent sent in the subnet
+func GetAgentLastEvent(subnet string, agent entities.DeviceString) (*entities.Event, error) {
+	rsl, err := stores.EventStore.Query(context.Background(), query.Query{
+		Prefix:   entities.AgentEventsKey(subnet, agent),
+		Orders:   []query.Order{query.OrderByKeyDescending{}},
+		Limit:    1,
+		KeysOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := rsl.Rest()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, datastore.ErrNotFound
+	}
+	// key ends with /<model>/<event id>
+	parts := strings.Split(entries[0].Key, "/")
+	if len(parts) < 2 {
+		return nil, datastore.ErrNotFound
+	}
+	return GetEventById(parts[len(parts)-1], entities.EntityModel(parts[len(parts)-2]))
+}
+
+// GetBatchEvent returns the event at index of a client batch
+func GetBatchEvent(batch string, index uint16) (*entities.Event, error) {
+	rsl, err := stores.EventStore.Query(context.Background(), query.Query{
+		Prefix:   entities.BatchEventsKey(batch, index),
+		Limit:    1,
+		KeysOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := rsl.Rest()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, datastore.ErrNotFound
+	}
+	// key ends with /<model>/<event id>
+	parts := strings.Split(entries[0].Key, "/")
+	return GetEventById(parts[len(parts)-1], entities.EntityModel(parts[len(parts)-2]))
 }
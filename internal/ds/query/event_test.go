@@ -0,0 +1,51 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+	"github.com/mlayerprotocol/go-mlayer/pkg/core/ds"
+)
+
+func TestGetBatchEvent(t *testing.T) {
+	store, err := ds.NewDatastore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := stores.EventStore
+	stores.EventStore = store
+	t.Cleanup(func() {
+		stores.EventStore = previous
+		store.Close()
+	})
+	ids := []string{}
+	for i, signature := range []string{"0123456789abcdef0123456789abcdef0123456789abcdef", "fedcba9876543210fedcba9876543210fedcba9876543210"} {
+		event := &entities.Event{
+			Subnet: "s1", EventType: uint16(constants.CreateTopicEvent), Signature: signature,
+			Validator: "02ebec9d95769bb3d71712f0bf1e7e88b199fc945f67f908bbab81e9b7cb1092d8",
+			Payload:   entities.ClientPayload{Data: entities.Topic{Ref: "general"}},
+			Batch:     "b1", BatchIndex: uint16(i), BatchSize: 2,
+		}
+		if err := createEvent(event, nil); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, event.ID)
+	}
+	for i, id := range ids {
+		event, err := GetBatchEvent("b1", uint16(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.ID != id || event.BatchIndex != uint16(i) {
+			t.Fatalf("index %d: expected event %s, got %s at %d", i, id, event.ID, event.BatchIndex)
+		}
+		if _, ok := event.Payload.Data.(entities.Topic); !ok {
+			t.Fatalf("index %d: expected the payload to be decoded as a topic, got %T", i, event.Payload.Data)
+		}
+	}
+	if _, err := GetBatchEvent("b1", 2); !IsErrorNotFound(err) {
+		t.Fatalf("expected no event past the batch, got %v", err)
+	}
+}
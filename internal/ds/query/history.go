@@ -0,0 +1,185 @@
+package query
+
+import (
+	"context"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/mlayerprotocol/go-mlayer/common/encoder"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+)
+
+/*
+GetEntityEvents walks the PreviousEvent chain of an entity from its latest event, most recent first.
+Events that lost a conflict to an event of the chain follow that event, and lostTo maps them to the event they lost to.
+id is the entities state id. When no state has that id, it is taken as the id of one of the entities events
+and the chain is walked from there
+*/
+func GetEntityEvents(model entities.EntityModel, id string, limits *QueryLimit) (data []*entities.Event, lostTo map[string]string, err error) {
+	if limits == nil {
+		limits = DefaultQueryLimit
+	}
+	eventId := id
+	value, err := stores.StateStore.Get(context.Background(), datastore.NewKey(EntityKey(model, id)))
+	if err == nil {
+		eventId = string(value)
+	} else if !IsErrorNotFound(err) {
+		return data, lostTo, err
+	}
+	losers, err := entityConflictLosers(model, id)
+	if err != nil {
+		return data, lostTo, err
+	}
+	data = []*entities.Event{}
+	lostTo = map[string]string{}
+	visited := map[string]bool{}
+	skipped := 0
+	add := func(event *entities.Event) {
+		if skipped < limits.Offset {
+			skipped++
+		} else if len(data) < limits.Limit {
+			data = append(data, event)
+		}
+	}
+	for len(eventId) > 0 && !visited[eventId] && len(data) < limits.Limit {
+		visited[eventId] = true
+		event, err := GetEventById(eventId, model)
+		if err != nil {
+			if IsErrorNotFound(err) && len(visited) > 1 {
+				// the rest of the chain has not been synced to this node
+				break
+			}
+			return data, lostTo, err
+		}
+		add(event)
+		for _, loser := range losers[event.ID] {
+			if visited[loser.ID] {
+				continue
+			}
+			visited[loser.ID] = true
+			lost, err := GetEventFromPath(&loser)
+			if err != nil || lost == nil {
+				// the losing event was never synced to this node
+				continue
+			}
+			lostTo[lost.ID] = event.ID
+			add(lost)
+		}
+		if len(event.PreviousEvent.Model) > 0 && event.PreviousEvent.Model != model {
+			break
+		}
+		eventId = event.PreviousEvent.ID
+	}
+	return data, lostTo, nil
+}
+
+// entityConflictLosers maps the events that won a conflict over the entity to the events they won over
+func entityConflictLosers(model entities.EntityModel, id string) (map[string][]entities.EventPath, error) {
+	rsl, err := stores.StateStore.Query(context.Background(), query.Query{
+		Prefix: entities.EntityConflictsKey(model, id),
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := rsl.Rest()
+	if err != nil {
+		return nil, err
+	}
+	losers := map[string][]entities.EventPath{}
+	for _, entry := range entries {
+		conflict, err := entities.UnpackEventConflict(entry.Value)
+		if err != nil {
+			logger.Errorf("UnpackEventConflict: %v", err)
+			continue
+		}
+		losers[conflict.Winner.Event.ID] = append(losers[conflict.Winner.Event.ID], conflict.Loser.Event)
+	}
+	return losers, nil
+}
+
+const historicStateKeyVersionKey = "hist/keyv"
+const historicStateKeyVersion = "1"
+
+/*
+MigrateHistoricStates moves historic states saved before they were keyed by event id to the id of the event that produced them.
+Topics, subnets and wallets kept them under the entity id and authorizations and subscriptions under their data key.
+A run that stops half way resumes from its last committed chunk
+*/
+func MigrateHistoricStates() error {
+	m, err := startMigration(stores.StateStore, historicStateKeyVersionKey, historicStateKeyVersion)
+	if err != nil || m == nil {
+		return err
+	}
+	defer m.Discard()
+	move := func(model entities.EntityModel, key string, state []byte) error {
+		var historic struct {
+			Event entities.EventPath `json:"e"`
+		}
+		if err := encoder.MsgPackUnpackStruct(state, &historic); err != nil || historic.Event.ID == "" {
+			logger.Errorf("MigrateHistoricStates: %s: %v", key, err)
+			return nil
+		}
+		newKey := datastore.NewKey(EntityDataKey(model, historic.Event.ID))
+		if exists, err := m.Has(newKey); err != nil {
+			return err
+		} else if !exists {
+			if err := m.Put(newKey, state); err != nil {
+				return err
+			}
+		}
+		return m.Delete(datastore.NewKey(key))
+	}
+	phase := 0
+	for _, model := range []entities.EntityModel{entities.TopicModel, entities.SubnetModel, entities.WalletModel} {
+		err := ForEachState(model, func(id string, eventId string, data []byte) error {
+			key := EntityDataKey(model, id)
+			if m.Migrated(phase, key) {
+				return nil
+			}
+			state, err := stores.StateStore.Get(context.Background(), datastore.NewKey(key))
+			if err != nil {
+				if IsErrorNotFound(err) {
+					return nil
+				}
+				return err
+			}
+			if err := move(model, key, state); err != nil {
+				return err
+			}
+			return m.Checkpoint(phase, key)
+		})
+		if err != nil {
+			return err
+		}
+		phase++
+	}
+	for _, model := range []entities.EntityModel{entities.AuthModel, entities.SubscriptionModel} {
+		// the data key was used as the id, nesting it in a second data key
+		prefix := EntityDataKey(model, EntityDataKey(model, ""))
+		rsl, err := stores.StateStore.Query(context.Background(), query.Query{Prefix: prefix})
+		if err != nil {
+			return err
+		}
+		for entry := range rsl.Next() {
+			if entry.Error != nil {
+				rsl.Close()
+				return entry.Error
+			}
+			if m.Migrated(phase, entry.Key) {
+				continue
+			}
+			err := move(model, entry.Key, entry.Value)
+			if err == nil {
+				err = m.Checkpoint(phase, entry.Key)
+			}
+			if err != nil {
+				rsl.Close()
+				return err
+			}
+		}
+		rsl.Close()
+		phase++
+	}
+	return m.Finish()
+}
@@ -0,0 +1,75 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+)
+
+// ForEachEvent calls fn with the encoding of every event in the event store
+func ForEachEvent(fn func(b []byte) error) error {
+	rsl, err := stores.EventStore.Query(context.Background(), query.Query{
+		Prefix: (&entities.Event{ID: ""}).DataKey(),
+	})
+	if err != nil {
+		return err
+	}
+	defer rsl.Close()
+	for entry := range rsl.Next() {
+		if entry.Error != nil {
+			return entry.Error
+		}
+		if err := fn(entry.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetEventGeneric returns a stored event without decoding its payload data, for when its model is not known
+func GetEventGeneric(id string) (*entities.Event, error) {
+	value, err := stores.EventStore.Get(context.Background(), datastore.NewKey((&entities.Event{ID: id}).DataKey()))
+	if err != nil {
+		return nil, err
+	}
+	return entities.UnpackEventGeneric(value)
+}
+
+/*
+ForEachState calls fn with the id, latest event id and data of every current state of model.
+data is nil when the state has no data for its latest event
+*/
+func ForEachState(model entities.EntityModel, fn func(id string, eventId string, data []byte) error) error {
+	_store := stores.StateStore
+	if model == entities.MessageModel {
+		_store = stores.MessageStore
+	}
+	prefix := EntityKey(model, "")
+	rsl, err := _store.Query(context.Background(), query.Query{
+		Prefix: prefix,
+	})
+	if err != nil {
+		return err
+	}
+	defer rsl.Close()
+	for entry := range rsl.Next() {
+		if entry.Error != nil {
+			return entry.Error
+		}
+		id := entry.Key[strings.LastIndex(entry.Key, "/")+1:]
+		eventId := string(entry.Value)
+		data, err := _store.Get(context.Background(), datastore.NewKey(EntityDataKey(model, eventId)))
+		if err != nil && !IsErrorNotFound(err) {
+			return fmt.Errorf("reading %s state %s: %v", model, id, err)
+		}
+		if err := fn(id, eventId, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
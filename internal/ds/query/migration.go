@@ -0,0 +1,113 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/mlayerprotocol/go-mlayer/pkg/core/ds"
+)
+
+// MigrationChunkSize is the number of writes a store migration commits at a time
+var MigrationChunkSize = 1000
+
+/*
+migration rewrites a store in chunks of MigrationChunkSize writes.
+Every chunk is committed with the last key it covered, so a migration that stops half way resumes after it
+instead of starting over in one transaction that grows with the store
+*/
+type migration struct {
+	store       *ds.Datastore
+	versionKey  string
+	version     string
+	progressKey string
+	txn         datastore.Txn
+	writes      int
+	phase       int
+	key         string
+}
+
+/*
+startMigration returns nil when the migration of store at versionKey already ran to version.
+Otherwise it returns the migration with the progress saved by an earlier run
+*/
+func startMigration(store *ds.Datastore, versionKey string, version string) (*migration, error) {
+	saved, err := store.Get(context.Background(), datastore.NewKey(versionKey))
+	if err != nil && !IsErrorNotFound(err) {
+		return nil, err
+	}
+	if string(saved) == version {
+		return nil, nil
+	}
+	m := &migration{store: store, versionKey: versionKey, version: version, progressKey: versionKey + "/progress", phase: -1}
+	progress, err := store.Get(context.Background(), datastore.NewKey(m.progressKey))
+	if err != nil && !IsErrorNotFound(err) {
+		return nil, err
+	}
+	if phase, key, found := strings.Cut(string(progress), ":"); found {
+		if m.phase, err = strconv.Atoi(phase); err != nil {
+			return nil, fmt.Errorf("invalid migration progress %q: %v", progress, err)
+		}
+		m.key = key
+	}
+	if m.txn, err = store.NewTransaction(context.Background(), false); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Migrated reports whether an earlier run already committed key of phase
+func (m *migration) Migrated(phase int, key string) bool {
+	return phase < m.phase || (phase == m.phase && key <= m.key)
+}
+
+func (m *migration) Has(key datastore.Key) (bool, error) {
+	return m.txn.Has(context.Background(), key)
+}
+
+func (m *migration) Put(key datastore.Key, value []byte) error {
+	m.writes++
+	return m.txn.Put(context.Background(), key, value)
+}
+
+func (m *migration) Delete(key datastore.Key) error {
+	m.writes++
+	return m.txn.Delete(context.Background(), key)
+}
+
+// Checkpoint commits the writes so far once they fill a chunk, recording key of phase as the progress
+func (m *migration) Checkpoint(phase int, key string) error {
+	if m.writes < MigrationChunkSize {
+		return nil
+	}
+	if err := m.txn.Put(context.Background(), datastore.NewKey(m.progressKey), []byte(fmt.Sprintf("%d:%s", phase, key))); err != nil {
+		return err
+	}
+	if err := m.txn.Commit(context.Background()); err != nil {
+		return err
+	}
+	m.writes = 0
+	txn, err := m.store.NewTransaction(context.Background(), false)
+	if err != nil {
+		return err
+	}
+	m.txn = txn
+	return nil
+}
+
+// Finish commits the last chunk and marks the migration as done
+func (m *migration) Finish() error {
+	if err := m.txn.Delete(context.Background(), datastore.NewKey(m.progressKey)); err != nil {
+		return err
+	}
+	if err := m.txn.Put(context.Background(), datastore.NewKey(m.versionKey), []byte(m.version)); err != nil {
+		return err
+	}
+	return m.txn.Commit(context.Background())
+}
+
+func (m *migration) Discard() {
+	m.txn.Discard(context.Background())
+}
@@ -0,0 +1,244 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/common/utils"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+	"github.com/mlayerprotocol/go-mlayer/pkg/core/ds"
+)
+
+func withStateStore(t *testing.T) *ds.Datastore {
+	store, err := ds.NewDatastore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := stores.StateStore
+	stores.StateStore = store
+	t.Cleanup(func() {
+		stores.StateStore = previous
+		store.Close()
+	})
+	return store
+}
+
+func putSubnetState(t *testing.T, store *ds.Datastore, subnet *entities.Subnet) {
+	ctx := context.Background()
+	if err := store.Put(ctx, datastore.NewKey(EntityKey(entities.SubnetModel, subnet.ID)), []byte("ev-"+subnet.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, datastore.NewKey(EntityDataKey(entities.SubnetModel, "ev-"+subnet.ID)), subnet.MsgPack()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexSubnetsInChunks(t *testing.T) {
+	store := withStateStore(t)
+	previous := MigrationChunkSize
+	MigrationChunkSize = 2
+	t.Cleanup(func() { MigrationChunkSize = previous })
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		putSubnetState(t, store, &entities.Subnet{ID: fmt.Sprintf("s%d", i), Ref: fmt.Sprintf("chat%d", i)})
+	}
+	// a run that stopped after committing the chunks up to s2
+	if err := store.Put(ctx, datastore.NewKey(subnetIndexVersionKey+"/progress"), []byte("0:s2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := IndexSubnets(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		subnet := entities.Subnet{ID: fmt.Sprintf("s%d", i)}
+		indexed, err := store.Has(ctx, datastore.NewKey(fmt.Sprintf("%s/%s", subnet.KeywordKey(fmt.Sprintf("chat%d", i)), subnet.ID)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if indexed != (i > 2) {
+			t.Errorf("%s indexed=%v, only subnets after the saved progress should be indexed on resume", subnet.ID, indexed)
+		}
+	}
+	if _, err := store.Get(ctx, datastore.NewKey(subnetIndexVersionKey+"/progress")); !IsErrorNotFound(err) {
+		t.Errorf("expected the progress to be cleared once the migration finished, got %v", err)
+	}
+	if version, _ := store.Get(ctx, datastore.NewKey(subnetIndexVersionKey)); string(version) != subnetIndexVersion {
+		t.Errorf("expected the index version to be saved, got %q", version)
+	}
+}
+
+func TestMigrateHistoricStatesInChunks(t *testing.T) {
+	store := withStateStore(t)
+	previous := MigrationChunkSize
+	MigrationChunkSize = 1
+	t.Cleanup(func() { MigrationChunkSize = previous })
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		id := fmt.Sprintf("t%d", i)
+		topic := entities.Topic{ID: id, Event: entities.EventPath{EntityPath: entities.EntityPath{Model: entities.TopicModel, ID: "ev-" + id}}}
+		if err := store.Put(ctx, datastore.NewKey(EntityKey(entities.TopicModel, id)), []byte("ev-"+id)); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Put(ctx, datastore.NewKey(EntityDataKey(entities.TopicModel, id)), topic.MsgPack()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := MigrateHistoricStates(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		id := fmt.Sprintf("t%d", i)
+		if moved, _ := store.Has(ctx, datastore.NewKey(EntityDataKey(entities.TopicModel, "ev-"+id))); !moved {
+			t.Errorf("expected the state of %s to be keyed by its event id", id)
+		}
+		if old, _ := store.Has(ctx, datastore.NewKey(EntityDataKey(entities.TopicModel, id))); old {
+			t.Errorf("expected the state of %s to be removed from its entity id key", id)
+		}
+	}
+	if version, _ := store.Get(ctx, datastore.NewKey(historicStateKeyVersionKey)); string(version) != historicStateKeyVersion {
+		t.Errorf("expected the key version to be saved, got %q", version)
+	}
+}
+
+func TestRekeyExpiryQueue(t *testing.T) {
+	store := withStateStore(t)
+	previous := MigrationChunkSize
+	MigrationChunkSize = 1
+	t.Cleanup(func() { MigrationChunkSize = previous })
+	ctx := context.Background()
+	granted := uint64(1705392177000)
+	// both expire within the same second, which the old keys could not tell apart
+	durations := map[string]uint64{"e1": 100, "e2": 600}
+	for eventId, duration := range durations {
+		auth := entities.Authorization{Timestamp: &granted, Duration: &duration, Event: entities.EventPath{EntityPath: entities.EntityPath{ID: eventId}}}
+		if err := store.Put(ctx, datastore.NewKey(auth.DataKey()), auth.MsgPack()); err != nil {
+			t.Fatal(err)
+		}
+		legacy := fmt.Sprintf("%s/%s/%s", entities.AuthorizationExpiryQueueKey(), utils.IntMilliToTimestampString(int64(auth.ExpiresAt())), eventId)
+		if err := store.Put(ctx, datastore.NewKey(legacy), []byte(eventId)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := RekeyExpiryQueue(); err != nil {
+		t.Fatal(err)
+	}
+	auths, err := GetExpiringAuthorizations(granted + 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(auths) != 1 || auths[0].Event.ID != "e1" {
+		t.Fatalf("expected only the authorization expiring before the bound, got %d", len(auths))
+	}
+	auths, _ = GetExpiringAuthorizations(granted + 1000)
+	if len(auths) != 2 {
+		t.Fatalf("expected the legacy keys to be replaced, got %d queued", len(auths))
+	}
+	if version, _ := store.Get(ctx, datastore.NewKey(expiryQueueKeyVersionKey)); string(version) != expiryQueueKeyVersion {
+		t.Errorf("expected the key version to be saved, got %q", version)
+	}
+}
+
+func TestRekeyAgentEvents(t *testing.T) {
+	withStateStore(t)
+	store, err := ds.NewDatastore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := stores.EventStore
+	stores.EventStore = store
+	t.Cleanup(func() {
+		stores.EventStore = previous
+		store.Close()
+	})
+	ctx := context.Background()
+	agent := entities.DeviceString("did:0x1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d")
+	// both were sent within the same second, which the old keys could not order
+	for i, timestamp := range []uint64{1705392177900, 1705392177100} {
+		event := entities.Event{
+			ID: fmt.Sprintf("e%d", i), Subnet: "s1", Timestamp: timestamp, EventType: uint16(constants.CreateTopicEvent),
+			Payload: entities.ClientPayload{Agent: agent, Data: entities.Topic{}},
+		}
+		if err := store.Put(ctx, datastore.NewKey(event.DataKey()), event.MsgPack()); err != nil {
+			t.Fatal(err)
+		}
+		legacy := fmt.Sprintf("%s/%s/%s/%s", entities.AgentEventsKey("s1", agent), utils.IntMilliToTimestampString(int64(timestamp)), entities.TopicModel, event.ID)
+		if err := store.Put(ctx, datastore.NewKey(legacy), []byte(event.ID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := RekeyAgentEvents(); err != nil {
+		t.Fatal(err)
+	}
+	last, err := GetAgentLastEvent("s1", agent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.ID != "e0" {
+		t.Fatalf("expected the latest event by milliseconds, got %s", last.ID)
+	}
+	rsl, err := store.Query(ctx, query.Query{Prefix: entities.AgentEventsKey("s1", agent), KeysOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := rsl.Rest()
+	if len(entries) != 2 {
+		t.Fatalf("expected the legacy keys to be replaced, got %d keys", len(entries))
+	}
+	if version, _ := store.Get(ctx, datastore.NewKey(agentEventKeyVersionKey)); string(version) != agentEventKeyVersion {
+		t.Errorf("expected the key version to be saved, got %q", version)
+	}
+}
+
+func TestRekeyWalletLedgers(t *testing.T) {
+	store := withStateStore(t)
+	previous := entities.BlockOrderActivationBlock
+	t.Cleanup(func() { entities.BlockOrderActivationBlock = previous })
+	ctx := context.Background()
+	transactions := []*entities.TokenTransaction{
+		ledgerTransaction("e1", ledgerOwner, 1, "", ledgerAlice, "100", 2, 10),
+		ledgerTransaction("e2", ledgerOwner, 2, "", ledgerAlice, "100", 1, 20),
+	}
+	// the legacy keys ordered the ledger by block even before the activation
+	for _, tx := range transactions {
+		legacy := fmt.Sprintf("%s/%020d/%020d/%s", entities.WalletLedgerKey(tx.Wallet), tx.BlockNumber, tx.Timestamp, tx.Event.ID)
+		if err := store.Put(ctx, datastore.NewKey(legacy), tx.MsgPack()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ledger := func() []string {
+		t.Helper()
+		rsl, err := store.Query(ctx, query.Query{Prefix: entities.WalletLedgerKey("w1"), Orders: []query.Order{query.OrderByKey{}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, _ := rsl.Rest()
+		ids := []string{}
+		for _, entry := range entries {
+			tx, _ := entities.UnpackTokenTransaction(entry.Value)
+			ids = append(ids, tx.Event.ID)
+		}
+		return ids
+	}
+	for _, c := range []struct {
+		activation uint64
+		expected   string
+	}{
+		{math.MaxUint64, "e1,e2"},
+		{0, "e2,e1"},
+	} {
+		entities.BlockOrderActivationBlock = c.activation
+		if err := RekeyWalletLedgers(); err != nil {
+			t.Fatal(err)
+		}
+		if ids := strings.Join(ledger(), ","); ids != c.expected {
+			t.Errorf("activation %d: expected the ledger %s, got %s", c.activation, c.expected, ids)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package query
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+)
+
+// GetAgentNonce returns the highest nonce accepted from the agent for the account, zero when none was
+func GetAgentNonce(account entities.DIDString, agent entities.DeviceString, txn datastore.Read) (uint64, error) {
+	return getNonce(entities.AgentNonceKey(account, agent), txn)
+}
+
+// GetDeviceNonce returns the highest nonce accepted from the agent for any account, zero when none was
+func GetDeviceNonce(agent entities.DeviceString, txn datastore.Read) (uint64, error) {
+	return getNonce(entities.DeviceNonceKey(agent), txn)
+}
+
+// GetAccountNonce returns the highest nonce accepted from any agent of the account, zero when none was
+func GetAccountNonce(account entities.DIDString, txn datastore.Read) (uint64, error) {
+	return getNonce(entities.AccountNonceKey(account), txn)
+}
+
+func getNonce(key string, txn datastore.Read) (uint64, error) {
+	if txn == nil {
+		txn = stores.StateStore
+	}
+	value, err := txn.Get(context.Background(), datastore.NewKey(key))
+	if err != nil {
+		if IsErrorNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return new(big.Int).SetBytes(value).Uint64(), nil
+}
+
+// UpdateAgentNonce raises the high-water marks of the agent for the account, of the agent and of the account to nonce. Lower nonces leave them unchanged
+func UpdateAgentNonce(account entities.DIDString, agent entities.DeviceString, nonce uint64, txn datastore.Txn) error {
+	for _, key := range []string{entities.AgentNonceKey(account, agent), entities.DeviceNonceKey(agent), entities.AccountNonceKey(account)} {
+		current, err := getNonce(key, txn)
+		if err != nil {
+			return err
+		}
+		if nonce <= current {
+			continue
+		}
+		if err := txn.Put(context.Background(), datastore.NewKey(key), new(big.Int).SetUint64(nonce).Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAgentNonceUse returns the path of the event that used the agents nonce, nil when it is unused
+func GetAgentNonceUse(account entities.DIDString, agent entities.DeviceString, nonce uint64, txn datastore.Read) (*entities.EventPath, error) {
+	if txn == nil {
+		txn = stores.StateStore
+	}
+	value, err := txn.Get(context.Background(), datastore.NewKey(entities.AgentNonceUseKey(account, agent, nonce)))
+	if err != nil {
+		if IsErrorNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return entities.EventPathFromString(string(value)), nil
+}
+
+// UseAgentNonce records event as the one that used the agents nonce
+func UseAgentNonce(account entities.DIDString, agent entities.DeviceString, nonce uint64, event *entities.EventPath, txn datastore.Txn) error {
+	return txn.Put(context.Background(), datastore.NewKey(entities.AgentNonceUseKey(account, agent, nonce)), []byte(event.ToString()))
+}
+
+// maxRollBackDepth bounds how many previous events are walked to find the state a rolled back event replaced
+const maxRollBackDepth = 100
+
+// savedState is a state as DataStates.Commit saves it, with the keys it is indexed by
+type savedState struct {
+	id    string
+	event entities.EventPath
+	value interface{}
+	idKey string
+	keys  []string
+}
+
+// unpackSavedState decodes the saved data of a state of model, nil for models whose states are not rolled back
+func unpackSavedState(model entities.EntityModel, data []byte) (*savedState, error) {
+	switch model {
+	case entities.TopicModel:
+		state, err := entities.UnpackTopic(data)
+		if err != nil {
+			return nil, err
+		}
+		return &savedState{id: state.ID, event: state.Event, value: state, idKey: state.Key(), keys: state.GetKeys()}, nil
+	case entities.SubscriptionModel:
+		state, err := entities.UnpackSubscription(data)
+		if err != nil || state.Status == nil {
+			return nil, err
+		}
+		return &savedState{id: state.ID, event: state.Event, value: state, idKey: state.Key(), keys: state.GetKeys()}, nil
+	case entities.WalletModel:
+		state, err := entities.UnpackWallet(data)
+		if err != nil {
+			return nil, err
+		}
+		return &savedState{id: state.ID, event: state.Event, value: state, idKey: state.Key(), keys: state.GetKeys()}, nil
+	case entities.MessageModel:
+		state, err := entities.UnpackMessage(data)
+		if err != nil {
+			return nil, err
+		}
+		return &savedState{id: state.ID, event: state.Event, value: state, idKey: state.Key(), keys: state.GetKeys()}, nil
+	}
+	return nil, nil
+}
+
+/*
+rollBackEvent undoes what a saved event applied once it lost its nonce to an earlier event.
+A token transaction is taken out of its wallets ledger, replaying the transactions after it.
+A state the event is the latest event of goes back to the most recent state left, either one staged in ds or the one the events
+previous events applied, and is deleted when the event created it. Validators that never applied the event end up with the same state
+*/
+func (ds *DataStates) rollBackEvent(event *entities.Event, stateTxn datastore.Txn, eventTxn datastore.Txn, messageTxn datastore.Txn) error {
+	model := entities.GetModelTypeFromEventType(constants.EventType(event.EventType))
+	if entities.IsTokenEvent(event.EventType) {
+		return ds.rollBackTokenEvent(event, stateTxn, eventTxn)
+	}
+	txn := stateTxn
+	if model == entities.MessageModel {
+		txn = messageTxn
+	}
+	data, err := txn.Get(context.Background(), datastore.NewKey(EntityDataKey(model, event.ID)))
+	if err != nil {
+		if IsErrorNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	lost, err := unpackSavedState(model, data)
+	if err != nil || lost == nil {
+		return err
+	}
+	latest, err := txn.Get(context.Background(), datastore.NewKey(lost.idKey))
+	if err != nil {
+		if IsErrorNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	path := entities.EntityPath{Model: model, ID: lost.id}
+	if string(latest) != event.ID || ds.CurrentStates[path] != nil {
+		// a later event replaced the state, or is about to
+		return nil
+	}
+
+	var restore *savedState
+	var restoreOrder entities.EventOrder
+	consider := func(state *savedState, order entities.EventOrder) {
+		if state == nil || state.id != lost.id {
+			return
+		}
+		if cmp, _ := entities.CompareEventOrder(restoreOrder, order); restore == nil || cmp < 0 {
+			restore, restoreOrder = state, order
+		}
+	}
+	for k, data := range ds.HistoricState {
+		staged, ok := ds.Events[k.ID]
+		if k.Model != model || !ok || staged.IsValid == nil || !*staged.IsValid {
+			continue
+		}
+		state, err := unpackSavedState(model, data)
+		if err != nil {
+			return err
+		}
+		consider(state, staged.Order())
+	}
+	previous := event.PreviousEvent
+	for i := 0; previous.ID != "" && i < maxRollBackDepth; i++ {
+		prevEvent, err := GetEventByIdTxn(previous.ID, model, &eventTxn)
+		if err != nil {
+			if IsErrorNotFound(err) {
+				break
+			}
+			return err
+		}
+		if prevEvent.IsValid != nil && *prevEvent.IsValid {
+			data, err := txn.Get(context.Background(), datastore.NewKey(EntityDataKey(model, previous.ID)))
+			if err == nil {
+				state, err := unpackSavedState(model, data)
+				if err != nil {
+					return err
+				}
+				consider(state, prevEvent.Order())
+				break
+			}
+			if !IsErrorNotFound(err) {
+				return err
+			}
+		}
+		previous = prevEvent.PreviousEvent
+	}
+
+	if restore != nil {
+		ds.AddCurrentState(model, lost.id, restore.value)
+		return nil
+	}
+	for _, key := range lost.keys {
+		if key == "" {
+			continue
+		}
+		if err := txn.Delete(context.Background(), datastore.NewKey(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollBackTokenEvent takes the transaction of a token event out of its wallets ledger and updates the transactions whose validity the replay changed
+func (ds *DataStates) rollBackTokenEvent(event *entities.Event, stateTxn datastore.Txn, eventTxn datastore.Txn) error {
+	t, ok := event.Payload.Data.(entities.TokenTransaction)
+	if !ok {
+		return nil
+	}
+	// the ledger key is built as the token event handler built it
+	t.Event = *event.GetPath()
+	t.BlockNumber = event.BlockNumber
+	outcomes, err := RemoveTokenTransaction(&t, stateTxn)
+	if err != nil {
+		return err
+	}
+	for _, outcome := range outcomes {
+		replayed, err := GetEventByIdTxn(outcome.Transaction.Event.ID, entities.WalletModel, &eventTxn)
+		if err != nil {
+			return err
+		}
+		isValid := outcome.Valid
+		replayed.Error = outcome.Error
+		replayed.IsValid = &isValid
+		ds.AddEvent(*replayed)
+	}
+	return nil
+}
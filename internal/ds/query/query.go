@@ -98,12 +98,25 @@ func GetStateById(did string, modelType entities.EntityModel) ([]byte, error) {
 	})
 	return stateData, err
 }
-func SaveHistoricState(model entities.EntityModel, id string, state []byte) error {
-	ds := stores.StateStore
-	if model == entities.MessageModel {
-		ds = stores.MessageStore
+
+// GetStateByIdTxn reads the state through txn, so that states written in it but not committed yet are found
+func GetStateByIdTxn(did string, modelType entities.EntityModel, txn *datastore.Txn) ([]byte, error) {
+	if txn == nil {
+		return GetStateById(did, modelType)
+	}
+	eventId, err := (*txn).Get(context.Background(), datastore.NewKey(EntityKey(modelType, did)))
+	if err != nil {
+		return nil, err
+	}
+	if len(eventId) == 0 {
+		return nil, datastore.ErrNotFound
 	}
-	return ds.Put(context.Background(), datastore.NewKey(fmt.Sprintf("data/%s/%s", model, id)), state)
+	return (*txn).Get(context.Background(), datastore.NewKey(EntityDataKey(modelType, string(eventId))))
+}
+
+// SaveHistoricState saves the state produced by an event that did not become current, under the events id
+func SaveHistoricState(model entities.EntityModel, id string, state []byte, txn datastore.Txn) error {
+	return txn.Put(context.Background(), datastore.NewKey(fmt.Sprintf("data/%s/%s", model, id)), state)
 }
 func CreateState(newState CreateStateParam, tx *datastore.Txn) (err error) {
 	ds := stores.StateStore
@@ -228,8 +241,11 @@ func UpdateState(id string, newState NewStateParam, tx *datastore.Txn) error {
 	return nil
 }
 
-func RefExists(entityType entities.EntityModel, ref string, subnet string) (bool, error) {
-	ds := stores.StateStore
+func RefExists(entityType entities.EntityModel, ref string, subnet string, txn *datastore.Txn) (bool, error) {
+	var ds datastore.Read = stores.StateStore
+	if txn != nil {
+		ds = *txn
+	}
 	refKey := fmt.Sprintf("%s|ref|%s", entityType, ref)
 	if subnet != "" {
 		refKey = fmt.Sprintf("%s|ref|%s|%s", entityType, subnet, ref)
@@ -4,10 +4,14 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"strconv"
+	"strings"
 
 	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
 	"github.com/mlayerprotocol/go-mlayer/entities"
 	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+	"github.com/mlayerprotocol/go-mlayer/internal/sql/models"
 )
 
 
@@ -103,3 +107,36 @@ func GetStats(query BlockStatsQuery, limit *QueryLimit) (uint64, error) {
 	return new(big.Int).SetBytes(value).Uint64(), nil
 }
 
+
+// GetSubnetUsage returns the number of billed events of a subnet per cycle
+func GetSubnetUsage(subnet string) ([]models.EventCounter, error) {
+	counts := []models.EventCounter{}
+	// usage is counted in the event store, in the same transaction as the billed events
+	rsl, err := stores.EventStore.Query(context.Background(), query.Query{
+		Prefix: entities.SubnetUsageKey(subnet, nil),
+	})
+	if err != nil {
+		if IsErrorNotFound(err) {
+			return counts, nil
+		}
+		return nil, err
+	}
+	for {
+		entry, ok := <-rsl.Next()
+		if !ok {
+			break
+		}
+		parts := strings.Split(entry.Key, "/")
+		cycle, err := strconv.ParseUint(parts[len(parts)-1], 10, 64)
+		if err != nil {
+			continue
+		}
+		count := new(big.Int).SetBytes(entry.Value).Uint64()
+		counts = append(counts, models.EventCounter{
+			Cycle:  &cycle,
+			Subnet: subnet,
+			Count:  &count,
+		})
+	}
+	return counts, nil
+}
@@ -2,10 +2,13 @@ package query
 
 import (
 	"context"
+	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/query"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
 	"github.com/mlayerprotocol/go-mlayer/entities"
 	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
 )
@@ -126,6 +129,42 @@ func UpdateSubnetState(id string, newState *entities.Subnet, tx *datastore.Txn,
 			return nil, err
 		}
 	}
+	for _, cat := range oldState.Categories {
+		if slices.Contains(newState.Categories, cat) {
+			continue
+		}
+		if err := txn.Delete(context.Background(), datastore.NewKey(fmt.Sprintf("%s/%s", oldState.CategoryKey(cat), oldState.ID))); err != nil {
+			return nil, err
+		}
+	}
+	for _, cat := range newState.Categories {
+		if err := txn.Put(context.Background(), datastore.NewKey(fmt.Sprintf("%s/%s", newState.CategoryKey(cat), oldState.ID)), []byte(oldState.ID)); err != nil {
+			logger.Errorf("error updateing subnet category: %v", err)
+			return nil, err
+		}
+	}
+	newWords := newState.Keywords()
+	for _, word := range oldState.Keywords() {
+		if slices.Contains(newWords, word) {
+			continue
+		}
+		if err := txn.Delete(context.Background(), datastore.NewKey(fmt.Sprintf("%s/%s", oldState.KeywordKey(word), oldState.ID))); err != nil {
+			return nil, err
+		}
+	}
+	for _, word := range newWords {
+		if err := txn.Put(context.Background(), datastore.NewKey(fmt.Sprintf("%s/%s", newState.KeywordKey(word), oldState.ID)), []byte(oldState.ID)); err != nil {
+			logger.Errorf("error updateing subnet keyword: %v", err)
+			return nil, err
+		}
+	}
+	if !strings.EqualFold(string(oldState.Account), string(newState.Account)) {
+		newState.ID = oldState.ID
+		if err := MoveIndexKey(txn, oldState.AccountSubnetsKey(), oldState.ID, newState.AccountSubnetKey(), []byte(oldState.ID)); err != nil {
+			logger.Errorf("error moving subnet owner: %v", err)
+			return nil, err
+		}
+	}
 	if tx == nil {
 		if err := txn.Commit(context.Background()); err != nil {
 			return nil, err
@@ -172,3 +211,148 @@ func GetAccountSubnets(account entities.DIDString, limit QueryLimit) (data []*en
 }
 
 
+
+type SubnetQuery struct {
+	Category *constants.SubnetCategory
+	Keyword  string
+	Public   bool
+}
+
+/*
+FindSubnets returns subnets in the category (or all subnets) with a word in their ref or meta
+starting with each word of the keyword. Keyword searches only scan the subnets indexed under the first word
+*/
+func FindSubnets(filter SubnetQuery, limits *QueryLimit) (data []*entities.Subnet, err error) {
+	if limits == nil {
+		limits = DefaultQueryLimit
+	}
+	keywords := entities.SubnetKeywords(filter.Keyword)
+	prefix := fmt.Sprintf("%s/id", entities.SubnetModel)
+	if len(keywords) > 0 {
+		prefix = (&entities.Subnet{}).KeywordKey(keywords[0])
+	} else if filter.Category != nil {
+		prefix = (&entities.Subnet{}).CategoryKey(int32(*filter.Category))
+	}
+	rsl, err := stores.StateStore.Query(context.Background(), query.Query{
+		Prefix:   prefix,
+		KeysOnly: true,
+	})
+	if err != nil {
+		if IsErrorNotFound(err) {
+			return data, nil
+		}
+		return nil, err
+	}
+	entries, err := rsl.Rest()
+	if err != nil {
+		return nil, err
+	}
+	skipped := 0
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		keyString := strings.Split(entry.Key, "/")
+		id := keyString[len(keyString)-1]
+		if seen[id] {
+			// a subnet is indexed once for every word starting with the keyword
+			continue
+		}
+		seen[id] = true
+		subnet, qerr := GetSubnetStateById(id)
+		if qerr != nil {
+			logger.Debugf("FindSubnets: %v", qerr)
+			continue
+		}
+		if filter.Public && !subnet.IsPublic() {
+			continue
+		}
+		if filter.Category != nil && !slices.Contains(subnet.Categories, int32(*filter.Category)) {
+			continue
+		}
+		if !matchesKeywords(subnet.Keywords(), keywords) {
+			continue
+		}
+		if skipped < limits.Offset {
+			skipped++
+			continue
+		}
+		data = append(data, subnet)
+		if limits.Limit > 0 && len(data) >= limits.Limit {
+			break
+		}
+	}
+	return data, nil
+}
+
+// matchesKeywords reports whether every keyword starts one of the words
+func matchesKeywords(words []string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if !slices.ContainsFunc(words, func(word string) bool { return strings.HasPrefix(word, keyword) }) {
+			return false
+		}
+	}
+	return true
+}
+
+const subnetIndexVersionKey = "snet/idxv"
+const subnetIndexVersion = "1"
+
+/*
+IndexSubnets adds the category and keyword index keys of subnets created before they were indexed.
+It runs once, later calls return immediately. A run that stops half way resumes from its last committed chunk
+*/
+func IndexSubnets() error {
+	m, err := startMigration(stores.StateStore, subnetIndexVersionKey, subnetIndexVersion)
+	if err != nil || m == nil {
+		return err
+	}
+	defer m.Discard()
+	err = ForEachState(entities.SubnetModel, func(id string, eventId string, data []byte) error {
+		if data == nil || m.Migrated(0, id) {
+			return nil
+		}
+		subnet, err := entities.UnpackSubnet(data)
+		if err != nil {
+			logger.Errorf("IndexSubnets: %s: %v", id, err)
+			return nil
+		}
+		for _, cat := range subnet.Categories {
+			if err := m.Put(datastore.NewKey(fmt.Sprintf("%s/%s", subnet.CategoryKey(cat), id)), []byte(id)); err != nil {
+				return err
+			}
+		}
+		for _, word := range subnet.Keywords() {
+			if err := m.Put(datastore.NewKey(fmt.Sprintf("%s/%s", subnet.KeywordKey(word), id)), []byte(id)); err != nil {
+				return err
+			}
+		}
+		return m.Checkpoint(0, id)
+	})
+	if err != nil {
+		return err
+	}
+	return m.Finish()
+}
+
+
+// MoveIndexKey replaces the index entry for id under oldPrefix with newKey in the same transaction
+func MoveIndexKey(txn datastore.Txn, oldPrefix string, id string, newKey string, value []byte) error {
+	rsl, err := txn.Query(context.Background(), query.Query{
+		Prefix:   oldPrefix,
+		KeysOnly: true,
+	})
+	if err != nil {
+		return err
+	}
+	entries, err := rsl.Rest()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Key, "/"+id) {
+			if err := txn.Delete(context.Background(), datastore.NewKey(entry.Key)); err != nil {
+				return err
+			}
+		}
+	}
+	return txn.Put(context.Background(), datastore.NewKey(newKey), value)
+}
@@ -0,0 +1,52 @@
+package query
+
+import (
+	"context"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+)
+
+func SaveSubnetProposal(proposal *entities.SubnetProposal) error {
+	return stores.StateStore.Put(context.Background(), datastore.NewKey(proposal.Key()), proposal.MsgPack())
+}
+
+func GetSubnetProposal(subnet string, id string) (*entities.SubnetProposal, error) {
+	proposal := entities.SubnetProposal{Subnet: subnet, ID: id}
+	b, err := stores.StateStore.Get(context.Background(), datastore.NewKey(proposal.Key()))
+	if err != nil {
+		return nil, err
+	}
+	proposal, err = entities.UnpackSubnetProposal(b)
+	if err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+func GetSubnetProposals(subnet string) (data []*entities.SubnetProposal, err error) {
+	rsl, err := stores.StateStore.Query(context.Background(), query.Query{
+		Prefix: entities.SubnetProposalsKey(subnet),
+	})
+	if err != nil {
+		if IsErrorNotFound(err) {
+			return data, nil
+		}
+		return nil, err
+	}
+	entries, err := rsl.Rest()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		proposal, err := entities.UnpackSubnetProposal(entry.Value)
+		if err != nil {
+			logger.Debugf("GetSubnetProposals: %v", err)
+			continue
+		}
+		data = append(data, &proposal)
+	}
+	return data, nil
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/query"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
 	"github.com/mlayerprotocol/go-mlayer/common/utils"
 	"github.com/mlayerprotocol/go-mlayer/entities"
 	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
@@ -74,7 +75,7 @@ func GetSubscriptions( filter entities.Subscription, limits *QueryLimit, txn *da
 	
 	for _, entry := range entries { 
 		
-		value, qerr := GetSubscriptionByEvent(entities.EventPath{EntityPath: entities.EntityPath{ ID: string(entry.Value)}})
+		value, qerr := GetSubscriptionByEventTxn(entities.EventPath{EntityPath: entities.EntityPath{ ID: string(entry.Value)}}, txn)
 		if qerr != nil {
 			continue
 		}
@@ -105,6 +106,15 @@ func GetSubscriptions( filter entities.Subscription, limits *QueryLimit, txn *da
 	return data, err
 }
 
+/*
+GetSubscriberSubscriptions returns the latest subscription of subscriber to each of its topics, whatever its status.
+The subscribed topics index keeps an entry for every status, the status in the filter only selects that index
+*/
+func GetSubscriberSubscriptions(subscriber entities.DIDString, limits *QueryLimit) ([]*entities.Subscription, error) {
+	anyStatus := constants.SubscribedSubscriptionStatus
+	return GetSubscriptions(entities.Subscription{Subscriber: subscriber, Status: &anyStatus}, limits, nil)
+}
+
 func CreateSubscriptionState(newState *entities.Subscription, tx *datastore.Txn) (sub *entities.Subscription, err error) {
 	if newState.Subscriber == "" || newState.Topic == "" || newState.Subnet == "" {
 		return nil, fmt.Errorf("new state must include acc, snet and agent fields")
@@ -266,9 +276,18 @@ func GetSubscriptionStates(subnet string, topic string, subscriber entities.Devi
 }
 
 func GetSubscriptionByEvent( event entities.EventPath) (*entities.Subscription, error) {
-	ds :=  stores.StateStore
-	
-	value, err := ds.Get(context.Background(), datastore.NewKey((&entities.Subscription{Event: event}).DataKey()))
+	return GetSubscriptionByEventTxn(event, nil)
+}
+
+func GetSubscriptionByEventTxn( event entities.EventPath, txn *datastore.Txn) (*entities.Subscription, error) {
+	var value []byte
+	var err error
+	key := datastore.NewKey((&entities.Subscription{Event: event}).DataKey())
+	if txn != nil {
+		value, err = (*txn).Get(context.Background(), key)
+	} else {
+		value, err = stores.StateStore.Get(context.Background(), key)
+	}
 	if err != nil {
 		return nil, err
 	}
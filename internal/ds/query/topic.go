@@ -3,6 +3,7 @@ package query
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/query"
@@ -12,8 +13,12 @@ import (
 
 
 func GetTopicById(did string) (*entities.Topic, error) {
+	return GetTopicByIdTxn(did, nil)
+}
+
+func GetTopicByIdTxn(did string, txn *datastore.Txn) (*entities.Topic, error) {
 	var stateData []byte
-	stateData, err := GetStateById(did, entities.TopicModel)
+	stateData, err := GetStateByIdTxn(did, entities.TopicModel, txn)
 	if err != nil {
 		return nil, err
 	}
@@ -158,6 +163,13 @@ func UpdateTopicState(id string, newState *entities.Topic, tx *datastore.Txn, cr
 		return nil, err
 	}
 	
+	txn, err := InitTx(stores.StateStore, tx)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		defer txn.Discard(context.Background())
+	}
 	err = UpdateState(id, NewStateParam{
 		OldIDKey:  fmt.Sprintf("%s/id/%s", entities.TopicModel, id),
 		DataKey: newState.DataKey(),
@@ -165,10 +177,22 @@ func UpdateTopicState(id string, newState *entities.Topic, tx *datastore.Txn, cr
 		EventHash: newState.Event.ID,
 		RefKey: &newState.Ref,
 		OldRefKey: &oldTopic.Ref,
-	}, tx)
+	}, &txn)
 	if err != nil {
 		return nil, err
 	}
+	// ownership moved so the owner index follows it
+	if !strings.EqualFold(string(oldTopic.Account), string(newState.Account)) {
+		newState.ID = id
+		if err := MoveIndexKey(txn, (&entities.Topic{Subnet: oldTopic.Subnet, Account: oldTopic.Account}).GetAccountTopicsKey(), id, newState.AccountTopicKey(), []byte(id)); err != nil {
+			return nil, err
+		}
+	}
+	if tx == nil {
+		if err := txn.Commit(context.Background()); err != nil {
+			return nil, err
+		}
+	}
 	
 	return newState, nil
 }
@@ -0,0 +1,514 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/mlayerprotocol/go-mlayer/common/encoder"
+	"github.com/mlayerprotocol/go-mlayer/common/utils"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+)
+
+var ErrInsufficientBalance = fmt.Errorf("insufficient wallet balance")
+
+func GetWalletById(id string) (*entities.Wallet, error) {
+	return GetWalletByIdTxn(id, nil)
+}
+
+func GetWalletByIdTxn(id string, txn *datastore.Txn) (*entities.Wallet, error) {
+	stateData, err := GetStateByIdTxn(id, entities.WalletModel, txn)
+	if err != nil {
+		return nil, err
+	}
+	data, err := entities.UnpackWallet(stateData)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func CreateWalletState(newState *entities.Wallet, tx *datastore.Txn) (*entities.Wallet, error) {
+	id, err := entities.GetId(*newState, newState.ID)
+	if err != nil {
+		return nil, err
+	}
+	newState.ID = id
+	err = CreateState(CreateStateParam{
+		ModelType: entities.WalletModel,
+		ID:        id,
+		IDKey:     newState.Key(),
+		DataKey:   newState.DataKey(),
+		Keys:      newState.GetKeys(),
+		Data:      newState.MsgPack(),
+		EventHash: newState.Event.ID,
+	}, tx)
+	if err != nil {
+		return nil, err
+	}
+	return newState, nil
+}
+
+func UpdateWalletState(id string, newState *entities.Wallet, tx *datastore.Txn, create bool) (*entities.Wallet, error) {
+	id, err := entities.GetId(*newState, id)
+	if err != nil {
+		return nil, err
+	}
+	_, err = GetWalletById(id)
+	if err != nil {
+		if IsErrorNotFound(err) && create {
+			return CreateWalletState(newState, tx)
+		}
+		return nil, err
+	}
+	err = UpdateState(id, NewStateParam{
+		OldIDKey:  newState.Key(),
+		DataKey:   newState.DataKey(),
+		Data:      newState.MsgPack(),
+		EventHash: newState.Event.ID,
+	}, tx)
+	if err != nil {
+		return nil, err
+	}
+	return newState, nil
+}
+
+// GetWalletBalance returns a zero balance for accounts that never held the token
+func GetWalletBalance(wallet string, account entities.DIDString, txn datastore.Read) (*entities.WalletBalance, error) {
+	if txn == nil {
+		txn = stores.StateStore
+	}
+	balance := entities.WalletBalance{Wallet: wallet, Account: account}
+	b, err := txn.Get(context.Background(), datastore.NewKey(entities.WalletBalanceKey(wallet, account)))
+	if err != nil {
+		if IsErrorNotFound(err) {
+			return &balance, nil
+		}
+		return nil, err
+	}
+	if err := encoder.MsgPackUnpackStruct(b, &balance); err != nil {
+		return nil, err
+	}
+	return &balance, nil
+}
+
+// GetSpentNonce returns the transaction that used the accounts nonce, if any
+func GetSpentNonce(wallet string, account entities.DIDString, nonce uint64, txn datastore.Read) (*entities.TokenTransaction, error) {
+	if txn == nil {
+		txn = stores.StateStore
+	}
+	b, err := txn.Get(context.Background(), datastore.NewKey(entities.WalletNonceKey(wallet, account, nonce)))
+	if err != nil {
+		if IsErrorNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	t, err := entities.UnpackTokenTransaction(b)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func GetWalletSupply(wallet string, txn datastore.Read) (*entities.WalletSupply, error) {
+	if txn == nil {
+		txn = stores.StateStore
+	}
+	supply := entities.WalletSupply{Wallet: wallet}
+	b, err := txn.Get(context.Background(), datastore.NewKey(entities.WalletSupplyKey(wallet)))
+	if err != nil {
+		if IsErrorNotFound(err) {
+			return &supply, nil
+		}
+		return nil, err
+	}
+	if err := encoder.MsgPackUnpackStruct(b, &supply); err != nil {
+		return nil, err
+	}
+	return &supply, nil
+}
+
+// updateWalletSupply adds mints to and removes burns from the wallets circulating supply
+func updateWalletSupply(t *entities.TokenTransaction, amount *big.Int, txn datastore.Txn, revert bool) error {
+	supply, err := GetWalletSupply(t.Wallet, txn)
+	if err != nil {
+		return err
+	}
+	delta := new(big.Int).Set(amount)
+	if revert {
+		delta.Neg(delta)
+	}
+	if t.From == "" {
+		supply.Minted.Add(&supply.Minted, delta)
+		supply.Supply.Add(&supply.Supply, delta)
+	} else {
+		supply.Burnt.Add(&supply.Burnt, delta)
+		supply.Supply.Sub(&supply.Supply, delta)
+	}
+	return txn.Put(context.Background(), datastore.NewKey(entities.WalletSupplyKey(t.Wallet)), supply.MsgPack())
+}
+
+// GetSubnetWallets returns the wallets created in a subnet
+func GetSubnetWallets(subnet string, limits *QueryLimit) (data []*entities.Wallet, err error) {
+	if limits == nil {
+		limits = DefaultQueryLimit
+	}
+	rsl, err := stores.StateStore.Query(context.Background(), query.Query{
+		Prefix: (&entities.Wallet{Subnet: subnet}).SubnetWalletsKey(),
+		Limit:  limits.Limit,
+		Offset: limits.Offset,
+	})
+	if err != nil {
+		return data, err
+	}
+	entries, err := rsl.Rest()
+	if err != nil {
+		return data, err
+	}
+	for _, entry := range entries {
+		wallet, qerr := GetWalletById(string(entry.Value))
+		if qerr != nil {
+			continue
+		}
+		data = append(data, wallet)
+	}
+	return data, nil
+}
+
+// GetAccountWalletBalances returns the accounts balance in every wallet of a subnet
+func GetAccountWalletBalances(subnet string, account entities.DIDString) (data []*entities.WalletBalance, err error) {
+	wallets, err := GetSubnetWallets(subnet, &QueryLimit{})
+	if err != nil {
+		return data, err
+	}
+	for _, wallet := range wallets {
+		balance, err := GetWalletBalance(wallet.ID, account, nil)
+		if err != nil {
+			return data, err
+		}
+		data = append(data, balance)
+	}
+	return data, nil
+}
+
+// GetWalletTransactions returns the transactions an account sent or received in a wallet, most recent first
+func GetWalletTransactions(wallet string, account entities.DIDString, limits *QueryLimit) (data []*entities.TokenTransaction, err error) {
+	if limits == nil {
+		limits = DefaultQueryLimit
+	}
+	rsl, err := stores.StateStore.Query(context.Background(), query.Query{
+		Prefix: entities.WalletTransactionsKey(wallet, account),
+		Orders: []query.Order{query.OrderByKeyDescending{}},
+		Limit:  limits.Limit,
+		Offset: limits.Offset,
+	})
+	if err != nil {
+		return data, err
+	}
+	entries, err := rsl.Rest()
+	if err != nil {
+		return data, err
+	}
+	for _, entry := range entries {
+		t, qerr := entities.UnpackTokenTransaction(entry.Value)
+		if qerr != nil {
+			continue
+		}
+		data = append(data, &t)
+	}
+	return data, nil
+}
+
+func putWalletBalance(balance *entities.WalletBalance, txn datastore.Txn) error {
+	return txn.Put(context.Background(), datastore.NewKey(entities.WalletBalanceKey(balance.Wallet, balance.Account)), balance.MsgPack())
+}
+
+/*
+ApplyTokenTransaction debits the sender, credits the recipient and spends the nonce within txn.
+With revert set it undoes a previously applied transaction
+*/
+func ApplyTokenTransaction(t *entities.TokenTransaction, txn datastore.Txn, revert bool) error {
+	amount, err := t.AmountInt()
+	if err != nil {
+		return err
+	}
+	debit, credit := t.From, t.To
+	if revert {
+		debit, credit = t.To, t.From
+	}
+	if debit != "" {
+		balance, err := GetWalletBalance(t.Wallet, debit, txn)
+		if err != nil {
+			return err
+		}
+		if balance.Balance.Cmp(amount) < 0 {
+			return ErrInsufficientBalance
+		}
+		balance.Balance.Sub(&balance.Balance, amount)
+		balance.Event = t.Event
+		balance.Timestamp = t.Timestamp
+		if err := putWalletBalance(balance, txn); err != nil {
+			return err
+		}
+	}
+	if credit != "" {
+		balance, err := GetWalletBalance(t.Wallet, credit, txn)
+		if err != nil {
+			return err
+		}
+		balance.Balance.Add(&balance.Balance, amount)
+		balance.Event = t.Event
+		balance.Timestamp = t.Timestamp
+		if err := putWalletBalance(balance, txn); err != nil {
+			return err
+		}
+	}
+	if t.From == "" || t.To == "" {
+		if err := updateWalletSupply(t, amount, txn, revert); err != nil {
+			return err
+		}
+	}
+	keys := []string{}
+	for _, account := range []entities.DIDString{t.From, t.To} {
+		if account != "" {
+			keys = append(keys, t.TransactionKey(account))
+		}
+	}
+	keys = append(keys, t.NonceKey())
+	for _, key := range keys {
+		if revert {
+			err = txn.Delete(context.Background(), datastore.NewKey(key))
+		} else {
+			err = txn.Put(context.Background(), datastore.NewKey(key), t.MsgPack())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LedgerOutcome is whether a transaction holds once its wallets ledger is replayed
+type LedgerOutcome struct {
+	Transaction *entities.TokenTransaction
+	Valid       bool
+	Error       string
+	// SpentBy is the earlier transaction that used the nonce, when that is why the transaction failed
+	SpentBy *entities.TokenTransaction
+}
+
+// applyLedgerTransaction applies t unless its nonce is spent or its sender cannot cover it
+func applyLedgerTransaction(t *entities.TokenTransaction, txn datastore.Txn) (LedgerOutcome, error) {
+	outcome := LedgerOutcome{Transaction: t}
+	spent, err := GetSpentNonce(t.Wallet, t.Account, t.Nonce, txn)
+	if err != nil {
+		return outcome, err
+	}
+	if spent != nil {
+		outcome.Error = "Nonce already used"
+		outcome.SpentBy = spent
+		return outcome, nil
+	}
+	if err := ApplyTokenTransaction(t, txn, false); err != nil {
+		if err != ErrInsufficientBalance {
+			return outcome, err
+		}
+		outcome.Error = err.Error()
+		return outcome, nil
+	}
+	outcome.Valid = true
+	return outcome, nil
+}
+
+func isAppliedTransaction(t *entities.TokenTransaction, txn datastore.Txn) (bool, error) {
+	spent, err := GetSpentNonce(t.Wallet, t.Account, t.Nonce, txn)
+	if err != nil {
+		return false, err
+	}
+	return spent != nil && spent.Event.ID == t.Event.ID, nil
+}
+
+/*
+InsertTokenTransaction adds t to its wallets ledger and applies it in ledger order.
+Transactions ordered after t are rolled back and replayed on top of it, so balances and nonces
+end up the same whatever order a validator receives the transactions in.
+The first outcome is that of t, followed by every replayed transaction whose validity changed
+*/
+func InsertTokenTransaction(t *entities.TokenTransaction, txn datastore.Txn) ([]LedgerOutcome, error) {
+	key := t.LedgerKey()
+	_, err := txn.Get(context.Background(), datastore.NewKey(key))
+	if err == nil {
+		applied, err := isAppliedTransaction(t, txn)
+		if err != nil {
+			return nil, err
+		}
+		return []LedgerOutcome{{Transaction: t, Valid: applied, Error: utils.IfThenElse(applied, "", "Transaction rejected")}}, nil
+	}
+	if !IsErrorNotFound(err) {
+		return nil, err
+	}
+
+	later, applied, err := rollBackLedgerAfter(t.Wallet, key, txn)
+	if err != nil {
+		return nil, err
+	}
+	if err := txn.Put(context.Background(), datastore.NewKey(key), t.MsgPack()); err != nil {
+		return nil, err
+	}
+	outcome, err := applyLedgerTransaction(t, txn)
+	if err != nil {
+		return nil, err
+	}
+	replayed, err := replayLedger(later, applied, txn)
+	if err != nil {
+		return nil, err
+	}
+	return append([]LedgerOutcome{outcome}, replayed...), nil
+}
+
+/*
+RemoveTokenTransaction takes the transaction t was saved as out of its wallets ledger, undoing it if it was applied.
+Transactions ordered after it are rolled back and replayed without it.
+The outcomes are those of the replayed transactions whose validity changed
+*/
+func RemoveTokenTransaction(t *entities.TokenTransaction, txn datastore.Txn) ([]LedgerOutcome, error) {
+	key := t.LedgerKey()
+	value, err := txn.Get(context.Background(), datastore.NewKey(key))
+	if err != nil {
+		if IsErrorNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	saved, err := entities.UnpackTokenTransaction(value)
+	if err != nil {
+		return nil, err
+	}
+	later, applied, err := rollBackLedgerAfter(t.Wallet, key, txn)
+	if err != nil {
+		return nil, err
+	}
+	isApplied, err := isAppliedTransaction(&saved, txn)
+	if err != nil {
+		return nil, err
+	}
+	if isApplied {
+		if err := ApplyTokenTransaction(&saved, txn, true); err != nil {
+			return nil, err
+		}
+	}
+	if err := txn.Delete(context.Background(), datastore.NewKey(key)); err != nil {
+		return nil, err
+	}
+	return replayLedger(later, applied, txn)
+}
+
+// rollBackLedgerAfter undoes the applied transactions of the wallets ledger ordered after key and returns them, most recent first, with whether each was applied
+func rollBackLedgerAfter(wallet string, key string, txn datastore.Txn) ([]*entities.TokenTransaction, []bool, error) {
+	rsl, err := txn.Query(context.Background(), query.Query{
+		Prefix: entities.WalletLedgerKey(wallet),
+		Orders: []query.Order{query.OrderByKeyDescending{}},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	later := []*entities.TokenTransaction{}
+	for result := range rsl.Next() {
+		if result.Error != nil {
+			rsl.Close()
+			return nil, nil, result.Error
+		}
+		if result.Key <= datastore.NewKey(key).String() {
+			break
+		}
+		tx, err := entities.UnpackTokenTransaction(result.Value)
+		if err != nil {
+			rsl.Close()
+			return nil, nil, err
+		}
+		later = append(later, &tx)
+	}
+	rsl.Close()
+
+	applied := make([]bool, len(later))
+	for i, tx := range later {
+		if applied[i], err = isAppliedTransaction(tx, txn); err != nil {
+			return nil, nil, err
+		}
+		if applied[i] {
+			if err := ApplyTokenTransaction(tx, txn, true); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return later, applied, nil
+}
+
+// replayLedger applies the transactions rollBackLedgerAfter returned again in ledger order, returning the outcomes whose validity changed
+func replayLedger(later []*entities.TokenTransaction, applied []bool, txn datastore.Txn) ([]LedgerOutcome, error) {
+	outcomes := []LedgerOutcome{}
+	for i := len(later) - 1; i >= 0; i-- {
+		outcome, err := applyLedgerTransaction(later[i], txn)
+		if err != nil {
+			return nil, err
+		}
+		if outcome.Valid != applied[i] {
+			outcomes = append(outcomes, outcome)
+		}
+	}
+	return outcomes, nil
+}
+
+const walletLedgerKeyVersionKey = "walkeyv"
+
+/*
+RekeyWalletLedgers moves the ledger entries whose key no longer sorts them in ledger order.
+The key depends on entities.BlockOrderActivationBlock, so the migration runs again whenever it changes.
+Only keys move, conflict_order_block must be set to a block no wallet has reached for balances to stay as applied
+*/
+func RekeyWalletLedgers() error {
+	m, err := startMigration(stores.StateStore, walletLedgerKeyVersionKey, fmt.Sprintf("%d", entities.BlockOrderActivationBlock))
+	if err != nil || m == nil {
+		return err
+	}
+	defer m.Discard()
+	rsl, err := stores.StateStore.Query(context.Background(), query.Query{
+		Prefix: entities.WalletLedgersKey(),
+		Orders: []query.Order{query.OrderByKey{}},
+	})
+	if err != nil {
+		return err
+	}
+	defer rsl.Close()
+	for entry := range rsl.Next() {
+		if entry.Error != nil {
+			return entry.Error
+		}
+		if m.Migrated(0, entry.Key) {
+			continue
+		}
+		t, err := entities.UnpackTokenTransaction(entry.Value)
+		if err != nil {
+			logger.Errorf("RekeyWalletLedgers: %s: %v", entry.Key, err)
+			continue
+		}
+		key := datastore.NewKey(t.LedgerKey())
+		if key.String() == entry.Key {
+			continue
+		}
+		if err := m.Put(key, entry.Value); err != nil {
+			return err
+		}
+		if err := m.Delete(datastore.NewKey(entry.Key)); err != nil {
+			return err
+		}
+		if err := m.Checkpoint(0, entry.Key); err != nil {
+			return err
+		}
+	}
+	return m.Finish()
+}
@@ -0,0 +1,171 @@
+package query
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/pkg/core/ds"
+)
+
+const (
+	ledgerOwner entities.DIDString = "did:0x0000000000000000000000000000000000000001"
+	ledgerAlice entities.DIDString = "did:0x00000000000000000000000000000000000000a1"
+	ledgerBob   entities.DIDString = "did:0x00000000000000000000000000000000000000b0"
+	ledgerCarol entities.DIDString = "did:0x00000000000000000000000000000000000000c0"
+	ledgerDave  entities.DIDString = "did:0x00000000000000000000000000000000000000d0"
+)
+
+func ledgerTransaction(id string, account entities.DIDString, nonce uint64, from entities.DIDString, to entities.DIDString, amount string, block uint64, ts uint64) *entities.TokenTransaction {
+	return &entities.TokenTransaction{
+		Wallet: "w1", From: from, To: to, Amount: amount, Timestamp: ts,
+		Account: account, Nonce: nonce, BlockNumber: block,
+		Event: entities.EventPath{EntityPath: entities.EntityPath{Model: entities.WalletModel, ID: id}},
+	}
+}
+
+/*
+alice's transfer to bob loses its nonce to an earlier transfer to dave,
+which also invalidates bob spending the credit and frees enough balance for alice's transfer to carol
+*/
+func ledgerTransactions() []*entities.TokenTransaction {
+	return []*entities.TokenTransaction{
+		ledgerTransaction("e1", ledgerOwner, 1, "", ledgerAlice, "100", 1, 10),
+		ledgerTransaction("e2", ledgerAlice, 1, ledgerAlice, ledgerBob, "80", 2, 20),
+		ledgerTransaction("e3", ledgerAlice, 2, ledgerAlice, ledgerCarol, "80", 2, 30),
+		ledgerTransaction("e4", ledgerBob, 1, ledgerBob, ledgerCarol, "50", 3, 40),
+		ledgerTransaction("e5", ledgerAlice, 1, ledgerAlice, ledgerDave, "10", 2, 15),
+	}
+}
+
+type ledgerState struct {
+	balances map[entities.DIDString]string
+	valid    map[string]bool
+	supply   string
+}
+
+// runValidator applies the transactions in the order one validator received them, each in its own transaction, then takes the removed ones out of the ledger
+func runValidator(t *testing.T, order []int, removed ...int) ledgerState {
+	ctx := context.Background()
+	store, err := ds.NewDatastore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	transactions := ledgerTransactions()
+	for _, i := range order {
+		txn, err := store.NewTransaction(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := InsertTokenTransaction(transactions[i], txn); err != nil {
+			t.Fatalf("InsertTokenTransaction %s: %v", transactions[i].Event.ID, err)
+		}
+		if err := txn.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, i := range removed {
+		txn, err := store.NewTransaction(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := RemoveTokenTransaction(transactions[i], txn); err != nil {
+			t.Fatalf("RemoveTokenTransaction %s: %v", transactions[i].Event.ID, err)
+		}
+		if err := txn.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	txn, err := store.NewTransaction(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Discard(ctx)
+	state := ledgerState{balances: map[entities.DIDString]string{}, valid: map[string]bool{}}
+	for _, account := range []entities.DIDString{ledgerAlice, ledgerBob, ledgerCarol, ledgerDave} {
+		balance, err := GetWalletBalance("w1", account, txn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state.balances[account] = balance.Balance.String()
+	}
+	for _, tx := range transactions {
+		if state.valid[tx.Event.ID], err = isAppliedTransaction(tx, txn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	supply, err := GetWalletSupply("w1", txn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.supply = supply.Supply.String()
+	return state
+}
+
+func TestLedgerIsIndependentOfArrivalOrder(t *testing.T) {
+	expected := ledgerState{
+		balances: map[entities.DIDString]string{ledgerAlice: "10", ledgerBob: "0", ledgerCarol: "80", ledgerDave: "10"},
+		valid:    map[string]bool{"e1": true, "e2": false, "e3": true, "e4": false, "e5": true},
+		supply:   "100",
+	}
+	for _, order := range [][]int{
+		{0, 1, 2, 3, 4},
+		{3, 2, 4, 1, 0},
+		{0, 3, 1, 4, 2},
+	} {
+		state := runValidator(t, order)
+		for account, balance := range expected.balances {
+			if state.balances[account] != balance {
+				t.Errorf("order %v: balance of %s is %s, want %s", order, account, state.balances[account], balance)
+			}
+		}
+		for id, valid := range expected.valid {
+			if state.valid[id] != valid {
+				t.Errorf("order %v: %s valid=%v, want %v", order, id, state.valid[id], valid)
+			}
+		}
+		if state.supply != expected.supply {
+			t.Errorf("order %v: supply %s, want %s", order, state.supply, expected.supply)
+		}
+	}
+}
+
+func TestLedgerRedeliveryIsIgnored(t *testing.T) {
+	ctx := context.Background()
+	store, err := ds.NewDatastore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	mint := ledgerTransactions()[0]
+	for i := 0; i < 2; i++ {
+		txn, _ := store.NewTransaction(ctx, false)
+		outcomes, err := InsertTokenTransaction(mint, txn)
+		if err != nil || len(outcomes) != 1 || !outcomes[0].Valid {
+			t.Fatalf("delivery %d: unexpected outcome %+v %v", i, outcomes, err)
+		}
+		txn.Commit(ctx)
+	}
+	txn, _ := store.NewTransaction(ctx, true)
+	defer txn.Discard(ctx)
+	balance, _ := GetWalletBalance("w1", ledgerAlice, txn)
+	if balance.Balance.String() != "100" {
+		t.Fatalf("redelivered mint credited twice, balance %s", balance.Balance.String())
+	}
+}
+
+// removing a transaction leaves the ledger as if it was never received
+func TestLedgerRemoveTransaction(t *testing.T) {
+	for _, order := range [][]int{
+		{0, 1, 2, 3, 4},
+		{4, 3, 2, 1, 0},
+	} {
+		removed := runValidator(t, order, 4)
+		expected := runValidator(t, []int{0, 1, 2, 3})
+		if !reflect.DeepEqual(removed, expected) {
+			t.Errorf("order %v: got %+v after removing e5, want %+v", order, removed, expected)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package query
+
+import (
+	"context"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+)
+
+func SaveWebhook(webhook *entities.Webhook) error {
+	return stores.WebhookStore.Put(context.Background(), datastore.NewKey(webhook.Key()), webhook.MsgPack())
+}
+
+func GetSubnetWebhooks(subnet string) (data []*entities.Webhook, err error) {
+	rsl, err := stores.WebhookStore.Query(context.Background(), query.Query{
+		Prefix: entities.SubnetWebhooksKey(subnet),
+	})
+	if err != nil {
+		if IsErrorNotFound(err) {
+			return data, nil
+		}
+		return nil, err
+	}
+	entries, err := rsl.Rest()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		webhook, err := entities.UnpackWebhook(entry.Value)
+		if err != nil {
+			logger.Debugf("GetSubnetWebhooks: %v", err)
+			continue
+		}
+		data = append(data, &webhook)
+	}
+	return data, nil
+}
+
+// SaveWebhookDelivery records an attempt and, for failed final attempts, a dead letter
+func SaveWebhookDelivery(delivery *entities.WebhookDelivery) error {
+	txn, err := InitTx(stores.WebhookStore, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Discard(context.Background())
+	if err := txn.Put(context.Background(), datastore.NewKey(delivery.Key()), delivery.MsgPack()); err != nil {
+		return err
+	}
+	if delivery.DeadLetter {
+		if err := txn.Put(context.Background(), datastore.NewKey(delivery.DeadLetterKey()), delivery.MsgPack()); err != nil {
+			return err
+		}
+	}
+	return txn.Commit(context.Background())
+}
+
+func getWebhookDeliveries(prefix string, limits *QueryLimit) (data []*entities.WebhookDelivery, err error) {
+	if limits == nil {
+		limits = DefaultQueryLimit
+	}
+	rsl, err := stores.WebhookStore.Query(context.Background(), query.Query{
+		Prefix: prefix,
+		Orders: []query.Order{query.OrderByKeyDescending{}},
+		Limit:  limits.Limit,
+		Offset: limits.Offset,
+	})
+	if err != nil {
+		if IsErrorNotFound(err) {
+			return data, nil
+		}
+		return nil, err
+	}
+	entries, err := rsl.Rest()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		delivery, err := entities.UnpackWebhookDelivery(entry.Value)
+		if err != nil {
+			continue
+		}
+		data = append(data, &delivery)
+	}
+	return data, nil
+}
+
+func GetWebhookDeliveries(webhook string, limits *QueryLimit) ([]*entities.WebhookDelivery, error) {
+	return getWebhookDeliveries(entities.WebhookDeliveriesKey(webhook), limits)
+}
+
+func GetWebhookDeadLetters(webhook string, limits *QueryLimit) ([]*entities.WebhookDelivery, error) {
+	return getWebhookDeliveries(entities.WebhookDeadLettersKey(webhook), limits)
+}
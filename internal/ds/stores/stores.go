@@ -15,6 +15,7 @@ var (
 	EventStore  *ds.Datastore
 	ClaimedRewardStore *ds.Datastore
 	NetworkStatsStore *ds.Datastore // to be removed later
+	WebhookStore *ds.Datastore
 )
 
 
@@ -54,5 +55,9 @@ func InitStores(mainContext *context.Context) (_ctx context.Context,  _stores []
 	ctx = context.WithValue(ctx, constants.NetworkStatsStore, NetworkStatsStore)
 	_stores = append(_stores, NetworkStatsStore)
 
+	WebhookStore = ds.New(&ctx,   string(constants.WebhookStore))
+	ctx = context.WithValue(ctx, constants.WebhookStore, WebhookStore)
+	_stores = append(_stores, WebhookStore)
+
 	return ctx, _stores
 }
\ No newline at end of file
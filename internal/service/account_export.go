@@ -0,0 +1,31 @@
+package service
+
+import (
+	"time"
+
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/chain"
+)
+
+/*
+Validate that an export request was recently signed by the account
+*/
+func ValidateAccountExportRequest(req *entities.AccountExportRequest, cfg *configs.MainConfiguration) error {
+	if req.Timestamp == 0 || req.Timestamp > uint64(time.Now().UnixMilli())+15000 || req.Timestamp < uint64(time.Now().UnixMilli())-15000 {
+		return apperror.BadRequest("Invalid export request timestamp")
+	}
+	hash, err := req.GetHash()
+	if err != nil {
+		return err
+	}
+	valid, err := chain.VerifyAccountSignature(req.SignatureData, entities.AddressFromString(string(req.Account)).Addr, "export_account", string(req.Account), hash, cfg.ChainId)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return apperror.Unauthorized("Invalid export request signature")
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"strings"
 
 	"github.com/ipfs/go-datastore"
 	"github.com/mlayerprotocol/go-mlayer/common/apperror"
@@ -13,6 +14,7 @@ import (
 	"github.com/mlayerprotocol/go-mlayer/common/utils"
 	"github.com/mlayerprotocol/go-mlayer/configs"
 	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/chain"
 
 	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
 	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
@@ -36,6 +38,9 @@ func ValidateAuthPayloadData(clientPayload *entities.ClientPayload, cfg *configs
 	if auth.Subnet == "" {
 		return nil, nil, nil, apperror.BadRequest("Subnet is required")
 	}
+	if clientPayload.EventType == uint16(constants.UnauthorizationEvent) && (auth.Priviledge == nil || *auth.Priviledge != constants.UnauthorizedPriviledge) {
+		return nil, nil, nil, apperror.BadRequest("Revocation priviledge must be 0")
+	}
 
 	// TODO find subnets state prior to the current state
 	// err = query.GetOne(models.SubnetState{Subnet: entities.Subnet{ID: auth.Subnet}}, &subnet)
@@ -65,6 +70,20 @@ func ValidateAuthPayloadData(clientPayload *entities.ClientPayload, cfg *configs
 	if auth.Account != subnet.Account && *auth.Priviledge > *subnet.DefaultAuthPrivilege {
 		return nil, nil, subnet, apperror.Internal("invalid auth priviledge. Cannot be higher than subnets default")
 	}
+	topicIds, err := entities.ParseTopicIds(auth.TopicIds)
+	if err != nil {
+		return nil, nil, subnet, apperror.BadRequest(err.Error())
+	}
+	for _, id := range topicIds {
+		// topics this node has not synced yet are checked when the agent uses them
+		topic, err := dsquery.GetTopicById(id)
+		if err != nil && !dsquery.IsErrorNotFound(err) {
+			return nil, nil, subnet, err
+		}
+		if topic != nil && topic.Subnet != auth.Subnet {
+			return nil, nil, subnet, apperror.BadRequest(fmt.Sprintf("Topic %s does not belong to subnet %s", id, auth.Subnet))
+		}
+	}
 	account := entities.AddressFromString(string(auth.Account))
 	grantor := entities.AddressFromString(string(auth.Grantor))
 	agent := entities.AddressFromString(string(auth.Agent))
@@ -74,8 +93,11 @@ func ValidateAuthPayloadData(clientPayload *entities.ClientPayload, cfg *configs
 	if account.Addr == agent.Addr {
 		return nil, nil, subnet, apperror.Internal("cannot reassign subnet owner role")
 	}
+	if strings.EqualFold(grantor.Addr, agent.Addr) {
+		return nil, nil, subnet, apperror.BadRequest("agent cannot grant itself")
+	}
 
-	msg, err := clientPayload.GetHash()
+	msg, err := clientPayload.SignatureDataMessage(auth.SignatureData.Type)
 	if err != nil {
 		return nil, nil, subnet, err
 	}
@@ -84,57 +106,13 @@ func ValidateAuthPayloadData(clientPayload *entities.ClientPayload, cfg *configs
 	if err = VerifyAuthDataSignature(auth, msg, cfg.ChainId); err != nil {
 		return nil, nil, subnet, apperror.Unauthorized("Invalid authorization data signature")
 	}
-	if auth.Grantor != auth.Account  {
-		// grantorAuthState, err = query.GetOneAuthorizationState(entities.Authorization{Account: entities.DIDString(string(account.ToDeviceString())), Subnet: auth.Subnet, Agent: grantor.ToDeviceString()})
-		_grantorAuthState, err := dsquery.GetAccountAuthorizations(entities.Authorization{Account: entities.DIDString(string(account.ToDeviceString())), Subnet: auth.Subnet, Agent: grantor.ToDeviceString()}, dsquery.DefaultQueryLimit, nil)
-
-		// if err == gorm.ErrRecordNotFound || dsquery.IsErrorNotFound(err) {
-		// 	accAuth := entities.Authorization{Account: entities.DIDString(string(account.ToDeviceString())), Subnet: auth.Subnet, Agent: grantor.ToDeviceString()}
-		// 	auth:= entities.Authorization{}
-		// 	pp, err := p2p.GetState(cfg, entities.EntityPath{Model: entities.AuthModel, Hash:  accAuth.ToAccountAuthKey(), }, nil, &auth)
-		// 	if err != nil {
-		// 		return nil, nil, subnet, apperror.Unauthorized("Grantor not authorized agent")
-		// 	}
-		// 	if len(pp.Event) < 2 {
-		// 		return nil, nil, subnet, fmt.Errorf("invalid event data")
-		// 	}
-		// 	authEvent, err := entities.UnpackEvent(pp.Event, entities.AuthModel)
-		// 	if err != nil {
-		// 		logger.Error(err)
-		// 		return  nil, nil, subnet, err
-		// 	}
-			
-		// 	if authEvent != nil  && *authEvent.Synced && len(pp.States) > 0 {
-		// 		// return HandleNewPubSubTopicEvent(topicEvent, ctx)
-		// 		// dataStates.Events[topicEvent.ID] = *topicEvent
-		// 		dataStates.AddEvent(*authEvent)
-		// 		authState, err := entities.UnpackAuthorization(pp.States[0])
-		// 		if err != nil {
-		// 			return nil, nil, subnet, err
-		// 		}
-		// 		// err = dsquery.CreateEvent(topicEvent, &txn)
-				
-		// 		// dataStates.CurrentStates[*entities.NewEntityPath("", entities.TopicModel, topic.ID)] = topic
-		// 		dataStates.AddCurrentState(entities.AuthModel, authState.ID, &authState)
-		// 		// if err == nil {
-		// 		// 	_, err = dsquery.CreateTopicState(&topic, nil)
-		// 		// }
-		// 		if err != nil {
-		// 			return nil, nil, subnet, err
-		// 		}
-				
-		// 	}
-			
-		// }
-		if err != nil ||! dsquery.IsErrorNotFound(err) {
-			return nil, grantorAuthState, subnet, apperror.Forbidden(" Grantor does not have enough permission")
-		}
-		if err == nil && len(_grantorAuthState) > 0 {
-			grantorAuthState = &models.AuthorizationState{Authorization: *_grantorAuthState[0]}
-			if *grantorAuthState.Authorization.Priviledge != constants.AdminPriviledge {
-				return nil, grantorAuthState, subnet, apperror.Forbidden(" Grantor does not have enough permission")
-			}
-		}
+	// the chain is checked again whenever the agent acts, see ValidateDelegation
+	delegation, err := GetAuthorizationChain(&auth, &_subnet)
+	if err != nil {
+		return nil, grantorAuthState, subnet, err
+	}
+	if len(delegation) > 1 {
+		grantorAuthState = &models.AuthorizationState{Authorization: *delegation[1]}
 	}
 	// prevAuthState, err = query.GetOneAuthorizationState(entities.Authorization{Agent:  agent.ToDeviceString(), Subnet: auth.Subnet})
 	_prevAuthState, err := dsquery.GetAccountAuthorizations(entities.Authorization{Account: entities.DIDString(string(account.ToDeviceString())), Subnet: auth.Subnet, Agent: agent.ToDeviceString()}, dsquery.DefaultQueryLimit, nil)
@@ -144,14 +122,91 @@ func ValidateAuthPayloadData(clientPayload *entities.ClientPayload, cfg *configs
 	if len(_prevAuthState) > 0 {
 		prevAuthState = &models.AuthorizationState{Authorization: *_prevAuthState[0]}
 	}
+	if clientPayload.EventType == uint16(constants.UnauthorizationEvent) || clientPayload.EventType == uint16(constants.RenewAuthorizationEvent) {
+		if prevAuthState == nil {
+			return nil, grantorAuthState, subnet, apperror.NotFound("Authorization not found")
+		}
+		if prevAuthState.IsRevoked() {
+			return nil, grantorAuthState, subnet, apperror.BadRequest("Authorization already revoked")
+		}
+		// only the account or the agent that granted the authorization can revoke or renew it
+		if auth.Grantor != auth.Account && !strings.EqualFold(string(prevAuthState.Grantor), string(auth.Grantor)) {
+			return nil, grantorAuthState, subnet, apperror.Forbidden("Grantor cannot change this authorization")
+		}
+	}
+	if clientPayload.EventType == uint16(constants.RenewAuthorizationEvent) {
+		if err := ValidateRenewal(&auth, &prevAuthState.Authorization); err != nil {
+			return nil, grantorAuthState, subnet, err
+		}
+	}
 	// if !valid {
 	// 	return prevAuthState, grantorAuthState, subnet, errors.New("4000: Invalid authorization data signature")
 	// }
+	if err := ValidateProposalApprovals(clientPayload, cfg.ChainId); err != nil {
+		return nil, grantorAuthState, subnet, err
+	}
 
 	return prevAuthState, grantorAuthState, subnet, nil
 
 }
 
+/*
+Ensure a renewal only moves the expiry of the current authorization, and moves it later.
+A renewal without a duration makes the authorization permanent
+*/
+func ValidateRenewal(renewal *entities.Authorization, current *entities.Authorization) error {
+	if *renewal.Priviledge != *current.Priviledge || renewal.TopicIds != current.TopicIds || renewal.Meta != current.Meta {
+		return apperror.BadRequest("Renewal cannot change the authorization scope")
+	}
+	if *renewal.Timestamp <= *current.Timestamp {
+		return apperror.BadRequest("Renewal must be more recent than the authorization")
+	}
+	if current.ExpiresAt() == 0 {
+		return apperror.BadRequest("Authorization does not expire")
+	}
+	if renewal.ExpiresAt() != 0 && renewal.ExpiresAt() <= current.ExpiresAt() {
+		return apperror.BadRequest("Renewal must extend the authorization expiry")
+	}
+	return nil
+}
+
+/*
+Ensure the agent's current authorization in the payload subnet covers the topic.
+An agent without an authorization in the subnet is denied
+*/
+func ValidateAgentTopicScope(payload *entities.ClientPayload, topic string, txn *datastore.Txn) error {
+	if payload.Agent == "" {
+		return nil
+	}
+	auth, err := dsquery.GetCurrentAuthorization(entities.Authorization{
+		Agent: payload.Agent, Account: entities.DIDString(entities.AddressFromString(string(payload.Account)).ToString()), Subnet: payload.Subnet,
+	}, txn)
+	if err != nil && !dsquery.IsErrorNotFound(err) {
+		return err
+	}
+	if auth == nil || !auth.CanActOnTopic(topic) {
+		return apperror.Forbidden("Agent not authorized to act on this topic")
+	}
+	return nil
+}
+
+/*
+Report whether auth is a revocation recorded before the event was created.
+Events are ordered against the revocation by block, and by payload timestamp within the same block.
+An event signed before the revocation is accepted even when it reaches this validator after the revocation,
+so that every validator reaches the same verdict whatever order it receives the two in.
+Only the events ordered after the revocation are rejected
+*/
+func IsRevokedBefore(auth *entities.Authorization, event *entities.Event) bool {
+	if !auth.IsRevoked() {
+		return false
+	}
+	if auth.BlockNumber > 0 && event.BlockNumber > 0 && auth.BlockNumber != event.BlockNumber {
+		return auth.BlockNumber < event.BlockNumber
+	}
+	return auth.Timestamp == nil || *auth.Timestamp <= event.Payload.Timestamp
+}
+
 func VerifyAuthDataSignature(auth entities.Authorization, msg []byte, chainId configs.ChainId) error {
 
 	account := entities.AddressFromString(string(auth.Account))
@@ -189,6 +244,32 @@ func VerifyAuthDataSignature(auth entities.Authorization, msg []byte, chainId co
 			// }
 		}
 
+	case entities.EIP1271PubKey:
+		authMsg := fmt.Sprintf(constants.SignatureMessageString, action, agent.Addr, chainId, encoder.ToBase64Padded(msg))
+		signer := utils.IfThenElse(len(string(auth.Grantor)) == 0, account.Addr, grantor.Addr)
+		var err error
+		valid, err = chain.VerifyContractWalletSignature(chainId, signer, []byte(authMsg), auth.SignatureData.Signature)
+		if err != nil {
+			return apperror.Unauthorized("invalid auth signature")
+		}
+
+	case entities.EIP712PubKey:
+		// msg is the typed data of the payload, so the wallet showed the authorization fields
+		signer := utils.IfThenElse(len(string(auth.Grantor)) == 0, account.Addr, grantor.Addr)
+		valid = crypto.VerifySignatureECC(signer, &msg, auth.SignatureData.Signature)
+
+	case entities.SolanaPubKey, entities.BitcoinPubKey, entities.BitcoinBIP322PubKey:
+		authMsg := fmt.Sprintf(constants.SignatureMessageString, action, agent.Addr, chainId, encoder.ToBase64Padded(msg))
+		signer := utils.IfThenElse(len(string(auth.Grantor)) == 0, account, grantor)
+		if signer.Platform() != utils.IfThenElse(auth.SignatureData.Type == entities.SolanaPubKey, entities.PlatformSolana, entities.PlatformBitcoin) {
+			return apperror.Unauthorized("Signature type does not match the signer address")
+		}
+		var err error
+		valid, err = chain.VerifyWalletSignature(auth.SignatureData, signer.Addr, []byte(authMsg))
+		if err != nil {
+			return apperror.Unauthorized("invalid auth signature")
+		}
+
 	case entities.TendermintsSecp256k1PubKey:
 
 		decodedSig, err := base64.StdEncoding.DecodeString(auth.SignatureData.Signature)
@@ -318,9 +399,11 @@ func HandleNewPubSubAuthEvent(event *entities.Event, ctx *context.Context) error
 	var localDataStateEvent *LocalDataStateEvent
 	if stateEvent != nil {
 		localDataStateEvent = &LocalDataStateEvent{
-			ID:        stateEvent.ID,
-			Hash:      stateEvent.Hash,
-			Timestamp: stateEvent.Timestamp,
+			ID:            stateEvent.ID,
+			Hash:          stateEvent.Hash,
+			Timestamp:     stateEvent.Timestamp,
+			Order:         stateEvent.Order(),
+			PreviousEvent: stateEvent.PreviousEvent,
 		}
 	}
 
@@ -355,7 +438,7 @@ func HandleNewPubSubAuthEvent(event *entities.Event, ctx *context.Context) error
 			if eventIsMoreRecent && err == nil {
 				dataStates.AddCurrentState(entities.AuthModel, data.DataKey(), data)
 			} else {
-				dataStates.AddHistoricState(entities.AuthModel, data.DataKey(), data.MsgPack())
+				dataStates.AddHistoricState(entities.AuthModel, event.ID, data.MsgPack())
 			}
 			go dsquery.UpdateAccountCounter(event.Payload.Account.ToString())
 			// if eventIsMoreRecent && err == nil {
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+)
+
+// how long before expiry the account is warned
+const AuthorizationExpiryWarning = 10 * time.Minute
+
+const (
+	AuthorizationExpiring = "expiring"
+	AuthorizationExpired  = "expired"
+)
+
+/*
+Mark lapsed timed authorizations as expired and notify the
+subnets authorization subscribers before and at expiry
+*/
+func SweepExpiredAuthorizations(ctx *context.Context) {
+	now := uint64(time.Now().UnixMilli())
+	auths, err := dsquery.GetExpiringAuthorizations(now + uint64(AuthorizationExpiryWarning.Milliseconds()))
+	if err != nil {
+		logger.Errorf("SweepExpiredAuthorizations: %v", err)
+		return
+	}
+	for _, auth := range auths {
+		current, err := dsquery.GetCurrentAuthorization(entities.Authorization{Account: auth.Account, Subnet: auth.Subnet, Agent: auth.Agent}, nil)
+		if err != nil && !dsquery.IsErrorNotFound(err) {
+			logger.Errorf("SweepExpiredAuthorizations: %v", err)
+			continue
+		}
+		// renewed, revoked or re-granted since it was queued
+		if current == nil || current.Event.ID != auth.Event.ID {
+			dsquery.RemoveFromExpiryQueue(auth)
+			continue
+		}
+		status, err := dsquery.GetAuthorizationExpiryStatus(auth.Event.ID)
+		if err != nil {
+			logger.Errorf("SweepExpiredAuthorizations: %v", err)
+			continue
+		}
+		if now >= auth.ExpiresAt() {
+			if err := dsquery.SetAuthorizationExpiryStatus(auth.Event.ID, AuthorizationExpired); err != nil {
+				logger.Errorf("SweepExpiredAuthorizations: %v", err)
+				continue
+			}
+			dsquery.RemoveFromExpiryQueue(auth)
+			notifyAuthorizationExpiry(ctx, auth, AuthorizationExpired)
+		} else if status == "" {
+			if err := dsquery.SetAuthorizationExpiryStatus(auth.Event.ID, AuthorizationExpiring); err != nil {
+				logger.Errorf("SweepExpiredAuthorizations: %v", err)
+				continue
+			}
+			notifyAuthorizationExpiry(ctx, auth, AuthorizationExpiring)
+		}
+	}
+}
+
+// IsAuthorizationExpired reports whether the sweeper marked the authorization or it has lapsed since the last sweep
+func IsAuthorizationExpired(auth *entities.Authorization) bool {
+	if auth.ExpiresAt() == 0 {
+		return false
+	}
+	if uint64(time.Now().UnixMilli()) >= auth.ExpiresAt() {
+		return true
+	}
+	status, _ := dsquery.GetAuthorizationExpiryStatus(auth.Event.ID)
+	return status == AuthorizationExpired
+}
+
+func notifyAuthorizationExpiry(ctx *context.Context, auth *entities.Authorization, status string) {
+	wsClientList, ok := (*ctx).Value(constants.WSClientLogId).(*entities.WsClientLog)
+	if !ok {
+		return
+	}
+	payload := entities.SocketSubscriptoinResponseData{
+		Event: map[string]interface{}{
+			"id":        auth.ID,
+			"snet":      auth.Subnet,
+			"acct":      auth.Account,
+			"agt":       auth.Agent,
+			"authE":     auth.Event,
+			"modelType": entities.AuthModel,
+			"status":    status,
+			"expAt":     auth.ExpiresAt(),
+		},
+	}
+	for _, subs := range wsClientList.GetClients(auth.Subnet, string(entities.AuthModel)) {
+		if subs != nil {
+			payload.SubscriptionId = subs.Id
+			subs.Conn.WriteJSON(payload)
+		}
+	}
+}
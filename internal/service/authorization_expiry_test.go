@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+)
+
+func TestValidateRenewal(t *testing.T) {
+	privilege := constants.MemberPriviledge
+	auth := func(timestamp uint64, duration uint64) *entities.Authorization {
+		return &entities.Authorization{Priviledge: &privilege, Timestamp: &timestamp, Duration: &duration}
+	}
+	tests := []struct {
+		name    string
+		current *entities.Authorization
+		renewal *entities.Authorization
+		valid   bool
+	}{
+		{"extends the expiry", auth(1000, 1000), auth(1500, 1000), true},
+		{"makes the authorization permanent", auth(1000, 1000), auth(1500, 0), true},
+		{"shortens the expiry", auth(1000, 1000), auth(1500, 300), false},
+		{"keeps the expiry", auth(1000, 1000), auth(1500, 500), false},
+		{"older than the authorization", auth(1000, 1000), auth(900, 5000), false},
+		{"authorization does not expire", auth(1000, 0), auth(1500, 1000), false},
+	}
+	for _, tt := range tests {
+		if err := ValidateRenewal(tt.renewal, tt.current); (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid %v, got %v", tt.name, tt.valid, err)
+		}
+	}
+	topics := *auth(1500, 5000)
+	topics.TopicIds = "6f1c9a8e-3b2d-4c5e-9f10-2a3b4c5d6e7f"
+	if err := ValidateRenewal(&topics, auth(1000, 1000)); err == nil {
+		t.Error("expected a renewal that changes the topics to be rejected")
+	}
+}
+
+func TestSweepExpiredAuthorizations(t *testing.T) {
+	withTestStores(t)
+	ctx := context.Background()
+	privilege := constants.MemberPriviledge
+	account := entities.DIDString("did:0x8f6b2a1e3c4d5e6f708192a3b4c5d6e7f8091a2b")
+	granted := uint64(time.Now().Add(-2 * time.Hour).UnixMilli())
+	authorize := func(agent entities.DeviceString, eventId string, timestamp uint64, duration time.Duration) *entities.Authorization {
+		t.Helper()
+		ms := uint64(duration.Milliseconds())
+		auth, err := dsquery.CreateAuthorizationState(&entities.Authorization{
+			ID: "auth-" + eventId, Account: account, Agent: agent, Subnet: "s1", Grantor: account, Priviledge: &privilege,
+			Timestamp: &timestamp, Duration: &ms, Event: entities.EventPath{EntityPath: entities.EntityPath{ID: eventId}},
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return auth
+	}
+	renewedAgent := entities.DeviceString("0x1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d")
+	expiredAgent := entities.DeviceString("0x2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e")
+	authorize(renewedAgent, "e1", granted, time.Hour)
+	authorize(renewedAgent, "e2", granted+1, 4*time.Hour)
+	authorize(expiredAgent, "e3", granted, time.Hour)
+
+	SweepExpiredAuthorizations(&ctx)
+
+	if status, _ := dsquery.GetAuthorizationExpiryStatus("e1"); status != "" {
+		t.Fatalf("expected the renewed authorization not to be marked, got %q", status)
+	}
+	if status, _ := dsquery.GetAuthorizationExpiryStatus("e3"); status != AuthorizationExpired {
+		t.Fatalf("expected the lapsed authorization to be marked expired, got %q", status)
+	}
+	queued, err := dsquery.GetExpiringAuthorizations(uint64(time.Now().Add(24 * time.Hour).UnixMilli()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 1 || queued[0].Event.ID != "e2" {
+		t.Fatalf("expected only the renewal to stay queued, got %d", len(queued))
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+)
+
+func TestNarrowedAgentScopeDeniesOtherTopics(t *testing.T) {
+	withTestStores(t)
+	account := entities.DIDString(entities.AddressFromString("0x8f6b2a1e3c4d5e6f708192a3b4c5d6e7f8091a2b").ToString())
+	agent := entities.DeviceString("0x1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d")
+	privilege := constants.AdminPriviledge
+	topic, otherTopic := "6f1c9a8e-3b2d-4c5e-9f10-2a3b4c5d6e7f", "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"
+	authorize := func(eventId string, timestamp uint64, topics string) {
+		t.Helper()
+		_, err := dsquery.CreateAuthorizationState(&entities.Authorization{
+			ID: "auth-" + eventId, Account: account, Agent: agent, Subnet: "s1", Grantor: account, Priviledge: &privilege,
+			TopicIds: topics, Timestamp: &timestamp, Event: entities.EventPath{EntityPath: entities.EntityPath{ID: eventId}},
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	authorize("e1", 1000, "")
+	authorize("e2", 2000, topic)
+
+	auths, err := dsquery.GetAccountAuthorizations(entities.Authorization{Account: account, Subnet: "s1", Agent: agent}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(auths) != 1 || auths[0].TopicIds != topic {
+		t.Fatalf("expected only the scoped authorization to be indexed, got %d", len(auths))
+	}
+
+	payload := &entities.ClientPayload{Account: account, Agent: agent, Subnet: "s1"}
+	if err := ValidateAgentTopicScope(payload, topic, nil); err != nil {
+		t.Fatalf("expected the agent to act on its topic: %v", err)
+	}
+	if err := ValidateAgentTopicScope(payload, otherTopic, nil); err == nil {
+		t.Fatal("expected the agent to be denied other topics once its scope was narrowed")
+	}
+}
+
+func TestIsRevokedBefore(t *testing.T) {
+	revoked := constants.UnauthorizedPriviledge
+	member := constants.MemberPriviledge
+	auth := func(privilege constants.AuthorizationPrivilege, block uint64, timestamp uint64) *entities.Authorization {
+		return &entities.Authorization{Priviledge: &privilege, BlockNumber: block, Timestamp: &timestamp}
+	}
+	event := func(block uint64, timestamp uint64) *entities.Event {
+		return &entities.Event{BlockNumber: block, Payload: entities.ClientPayload{Timestamp: timestamp}}
+	}
+	tests := []struct {
+		name    string
+		auth    *entities.Authorization
+		event   *entities.Event
+		revoked bool
+	}{
+		{"not a revocation", auth(member, 10, 1000), event(11, 2000), false},
+		{"event in a later block", auth(revoked, 10, 2000), event(11, 1000), true},
+		{"event in an earlier block arriving after the revocation", auth(revoked, 10, 1000), event(9, 2000), false},
+		{"same block, signed after", auth(revoked, 10, 1000), event(10, 2000), true},
+		{"same block, signed before", auth(revoked, 10, 2000), event(10, 1000), false},
+		{"event without a block, signed after", auth(revoked, 10, 1000), event(0, 2000), true},
+		{"event without a block, signed before", auth(revoked, 10, 2000), event(0, 1000), false},
+		{"revocation without a timestamp", &entities.Authorization{Priviledge: &revoked}, event(0, 1000), true},
+	}
+	for _, tt := range tests {
+		if got := IsRevokedBefore(tt.auth, tt.event); got != tt.revoked {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.revoked, got)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/common/utils"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/channelpool"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+	"github.com/mlayerprotocol/go-mlayer/pkg/core/p2p"
+)
+
+const (
+	// MaxBatchSize is the most payloads a client batch can have
+	MaxBatchSize = 50
+	// BatchWaitTimeout is how long the events of a batch from another validator are held before the missing ones are requested from it
+	BatchWaitTimeout = 30 * time.Second
+	// MaxBatchFetchAttempts is how many times the missing events of a batch are requested before it is dropped
+	MaxBatchFetchAttempts = 10
+)
+
+// batchLocks holds a mutex per subnet, batches of a subnet are applied one at a time
+var batchLocks sync.Map
+
+func batchLock(subnet string) *sync.Mutex {
+	lock, _ := batchLocks.LoadOrStore(subnet, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+/*
+EventBatch stages the events of a client batch in one set of transactions.
+Each event is written to the transactions once handled, so the next event of the batch is validated against it.
+Nothing is saved unless the batch is committed
+*/
+type EventBatch struct {
+	Hash       string
+	Size       int
+	lock       *sync.Mutex
+	cfg        *configs.MainConfiguration
+	ctx        *context.Context
+	dataStates *dsquery.DataStates
+	stateTxn   datastore.Txn
+	eventTxn   datastore.Txn
+	messageTxn datastore.Txn
+	events     []*entities.Event
+	finishers  []func()
+}
+
+// eventDataStates returns the DataStates a handler stages into, which is the batches when the event belongs to one
+func eventDataStates(ctx *context.Context, cfg *configs.MainConfiguration) (*dsquery.DataStates, *EventBatch) {
+	if batch := ContextBatch(ctx); batch != nil {
+		return batch.dataStates, batch
+	}
+	return dsquery.NewDataStates(cfg), nil
+}
+
+// ContextBatch returns the batch the context belongs to, if any
+func ContextBatch(ctx *context.Context) *EventBatch {
+	if batch, ok := (*ctx).Value(constants.EventBatchKey).(*EventBatch); ok {
+		return batch
+	}
+	return nil
+}
+
+// BatchTxn returns the transaction the batch of the context stages states of model in, or nil outside a batch
+func BatchTxn(ctx *context.Context, model entities.EntityModel) *datastore.Txn {
+	return ContextBatch(ctx).Txn(model)
+}
+
+// Txn returns the transaction states of model are staged in
+func (b *EventBatch) Txn(model entities.EntityModel) *datastore.Txn {
+	if b == nil {
+		return nil
+	}
+	if model == entities.MessageModel {
+		return &b.messageTxn
+	}
+	return &b.stateTxn
+}
+
+// Len is the number of events handled in the batch so far
+func (b *EventBatch) Len() int {
+	return len(b.events)
+}
+
+// Context returns the context events of the batch are prepared and handled in
+func (b *EventBatch) Context() *context.Context {
+	return b.ctx
+}
+
+// onCommit runs fn once the batch has been committed
+func (b *EventBatch) onCommit(fn func()) {
+	b.finishers = append(b.finishers, fn)
+}
+
+/*
+NewEventBatch opens the transactions of a batch. Batches of a subnet are applied one at a time,
+so the batch must be discarded once it is committed or rejected
+*/
+func NewEventBatch(ctx *context.Context, subnet string, hash string, size int) (*EventBatch, error) {
+	cfg, ok := (*ctx).Value(constants.ConfigKey).(*configs.MainConfiguration)
+	if !ok {
+		panic("Unable to get config from context")
+	}
+	batch := &EventBatch{Hash: hash, Size: size, lock: batchLock(subnet), cfg: cfg, dataStates: dsquery.NewDataStates(cfg)}
+	batch.lock.Lock()
+	var err error
+	if batch.stateTxn, err = stores.StateStore.NewTransaction(context.Background(), false); err == nil {
+		if batch.eventTxn, err = stores.EventStore.NewTransaction(context.Background(), false); err == nil {
+			batch.messageTxn, err = stores.MessageStore.NewTransaction(context.Background(), false)
+		}
+	}
+	if err != nil {
+		batch.Discard()
+		return nil, err
+	}
+	batchCtx := context.WithValue(*ctx, constants.EventBatchKey, batch)
+	batch.ctx = &batchCtx
+	return batch, nil
+}
+
+// Handle handles the next event of the batch and writes what it staged to the batches transactions
+func (b *EventBatch) Handle(event *entities.Event) error {
+	if err := handleEvent(event, b.ctx); err != nil {
+		return err
+	}
+	if staged := b.dataStates.Events[event.ID]; staged.IsValid != nil && !*staged.IsValid {
+		return fmt.Errorf("event rejected: %s", staged.Error)
+	}
+	if err := b.dataStates.Commit(&b.stateTxn, &b.eventTxn, &b.messageTxn); err != nil {
+		return err
+	}
+	b.dataStates = dsquery.NewDataStates(b.cfg)
+	b.events = append(b.events, event)
+	return nil
+}
+
+// Commit saves every event of the batch together, then broadcasts the ones created by this node
+func (b *EventBatch) Commit() error {
+	for _, txn := range []datastore.Txn{b.stateTxn, b.messageTxn, b.eventTxn} {
+		if err := txn.Commit(context.Background()); err != nil {
+			return err
+		}
+	}
+	for _, fn := range b.finishers {
+		go fn()
+	}
+	for _, event := range b.events {
+		event := event
+		if event.IsLocal(b.cfg) {
+			ReleasePayloadNonce(&event.Payload)
+		}
+		go func() {
+			channelpool.EventCounterChannel <- event
+		}()
+		broadcastEvent(event, b.ctx, nil)
+	}
+	return nil
+}
+
+// Discard drops everything the batch staged and lets the next batch start
+func (b *EventBatch) Discard() {
+	for _, txn := range []datastore.Txn{b.stateTxn, b.eventTxn, b.messageTxn} {
+		if txn != nil {
+			txn.Discard(context.Background())
+		}
+	}
+	b.lock.Unlock()
+}
+
+/*
+HandleEventBatch handles the events of a batch created by another validator in order and saves them all-or-nothing.
+When one is rejected, every event of the batch is saved as rejected
+*/
+func HandleEventBatch(events []*entities.Event, ctx *context.Context) error {
+	cfg, ok := (*ctx).Value(constants.ConfigKey).(*configs.MainConfiguration)
+	if !ok {
+		panic("Unable to get config from context")
+	}
+	batchErr := func() error {
+		batch, err := NewEventBatch(ctx, events[0].Subnet, events[0].Batch, len(events))
+		if err != nil {
+			return err
+		}
+		defer batch.Discard()
+		for _, event := range events {
+			if err := batch.Handle(event); err != nil {
+				return err
+			}
+		}
+		return batch.Commit()
+	}()
+	if batchErr == nil {
+		return nil
+	}
+	logger.Errorf("HandleEventBatch: batch %s rejected: %v", events[0].Batch, batchErr)
+	dataStates := dsquery.NewDataStates(cfg)
+	for _, event := range events {
+		dataStates.AddEvent(*event)
+		dataStates.AddEvent(entities.Event{ID: event.ID, Error: fmt.Sprintf("batch rejected: %v", batchErr), IsValid: utils.FalsePtr(), Synced: utils.TruePtr()})
+	}
+	return dataStates.Commit(nil, nil, nil)
+}
+
+type pendingBatch struct {
+	validator entities.PublicKeyString
+	size      uint16
+	events    []*entities.Event
+	received  time.Time
+	attempts  int
+}
+
+// missing returns the indexes of the batch not received yet
+func (p *pendingBatch) missing() []uint16 {
+	indexes := []uint16{}
+	for i := uint16(0); i < p.size; i++ {
+		if !slices.ContainsFunc(p.events, func(e *entities.Event) bool { return e.BatchIndex == i }) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+var (
+	pendingBatchMutex sync.Mutex
+	pendingBatches    = map[string]*pendingBatch{}
+)
+
+/*
+collectBatchEvent holds an event of another validators batch until every event of the batch has been received.
+It returns the events of the batch in order once it is complete
+*/
+func collectBatchEvent(event *entities.Event) []*entities.Event {
+	pendingBatchMutex.Lock()
+	defer pendingBatchMutex.Unlock()
+	if event.BatchSize == 0 || event.BatchSize > MaxBatchSize || event.BatchIndex >= event.BatchSize {
+		logger.Errorf("collectBatchEvent: event %s has index %d of a batch of %d", event.ID, event.BatchIndex, event.BatchSize)
+		return nil
+	}
+	pending := pendingBatches[event.Batch]
+	if pending == nil {
+		pending = &pendingBatch{validator: event.Validator, size: event.BatchSize, received: time.Now()}
+		pendingBatches[event.Batch] = pending
+	}
+	if event.Validator != pending.validator || event.BatchSize != pending.size {
+		return nil
+	}
+	for _, e := range pending.events {
+		if e.BatchIndex == event.BatchIndex {
+			return nil
+		}
+	}
+	pending.events = append(pending.events, event)
+	if len(pending.events) < int(pending.size) {
+		return nil
+	}
+	delete(pendingBatches, event.Batch)
+	sort.Slice(pending.events, func(i, j int) bool {
+		return pending.events[i].BatchIndex < pending.events[j].BatchIndex
+	})
+	return pending.events
+}
+
+type batchFetch struct {
+	batch     string
+	validator entities.PublicKeyString
+	missing   []uint16
+}
+
+/*
+staleBatches returns the batches that waited BatchWaitTimeout since they were last received or requested, with their missing indexes.
+Batches still incomplete after MaxBatchFetchAttempts requests are dropped
+*/
+func staleBatches(now time.Time) []batchFetch {
+	pendingBatchMutex.Lock()
+	defer pendingBatchMutex.Unlock()
+	fetches := []batchFetch{}
+	for hash, pending := range pendingBatches {
+		if now.Sub(pending.received) < BatchWaitTimeout {
+			continue
+		}
+		if pending.attempts >= MaxBatchFetchAttempts {
+			logger.Errorf("collectBatchEvent: dropping batch %s, events %v were never received from %s", hash, pending.missing(), pending.validator)
+			delete(pendingBatches, hash)
+			continue
+		}
+		pending.attempts++
+		pending.received = now
+		fetches = append(fetches, batchFetch{batch: hash, validator: pending.validator, missing: pending.missing()})
+	}
+	return fetches
+}
+
+// fetchBatchEvent requests the event at index of a batch from the validator that created it
+var fetchBatchEvent = func(cfg *configs.MainConfiguration, validator entities.PublicKeyString, batch string, index uint16) (*entities.Event, error) {
+	return p2p.GetBatchEvent(cfg, validator, batch, index)
+}
+
+// refetchBatch requests the missing events of a batch and returns the batch once they complete it
+func refetchBatch(cfg *configs.MainConfiguration, fetch batchFetch) []*entities.Event {
+	for _, index := range fetch.missing {
+		event, err := fetchBatchEvent(cfg, fetch.validator, fetch.batch, index)
+		if err != nil {
+			logger.Errorf("refetchBatch: batch %s event %d: %v", fetch.batch, index, err)
+			return nil
+		}
+		if event.Batch != fetch.batch || event.BatchIndex != index || event.Validator != fetch.validator {
+			logger.Errorf("refetchBatch: %s returned event %s for batch %s event %d", fetch.validator, event.ID, fetch.batch, index)
+			return nil
+		}
+		if events := collectBatchEvent(event); events != nil {
+			return events
+		}
+	}
+	return nil
+}
+
+/*
+RefetchPendingBatches requests the events missing from batches of other validators that waited BatchWaitTimeout,
+so a batch is applied even when some of its events were lost or one of them arrived on its own through a sync.
+Requests are made without holding any lock
+*/
+func RefetchPendingBatches(ctx *context.Context) {
+	cfg, ok := (*ctx).Value(constants.ConfigKey).(*configs.MainConfiguration)
+	if !ok {
+		panic("Unable to get config from context")
+	}
+	for _, fetch := range staleBatches(time.Now()) {
+		if events := refetchBatch(cfg, fetch); events != nil {
+			if err := HandleEventBatch(events, ctx); err != nil {
+				logger.Errorf("RefetchPendingBatches: %v", err)
+			}
+		}
+	}
+}
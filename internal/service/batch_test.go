@@ -0,0 +1,161 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+)
+
+// withPendingBatches starts the test with no batches of other validators pending
+func withPendingBatches(t *testing.T) {
+	pendingBatchMutex.Lock()
+	previous := pendingBatches
+	pendingBatches = map[string]*pendingBatch{}
+	pendingBatchMutex.Unlock()
+	t.Cleanup(func() {
+		pendingBatchMutex.Lock()
+		pendingBatches = previous
+		pendingBatchMutex.Unlock()
+	})
+}
+
+func batchEvent(batch string, index uint16, size uint16) *entities.Event {
+	return &entities.Event{ID: fmt.Sprintf("%s-%d", batch, index), Validator: "v1", Batch: batch, BatchIndex: index, BatchSize: size}
+}
+
+func TestCollectBatchEvent(t *testing.T) {
+	withPendingBatches(t)
+	for _, index := range []uint16{2, 0} {
+		if events := collectBatchEvent(batchEvent("b1", index, 3)); events != nil {
+			t.Fatalf("expected the batch to wait for its last event, got %d events", len(events))
+		}
+	}
+	if events := collectBatchEvent(batchEvent("b1", 0, 3)); events != nil {
+		t.Fatal("expected a repeated index to be ignored")
+	}
+	other := batchEvent("b1", 1, 3)
+	other.Validator = "v2"
+	if events := collectBatchEvent(other); events != nil {
+		t.Fatal("expected an event from another validator to be ignored")
+	}
+	events := collectBatchEvent(batchEvent("b1", 1, 3))
+	if len(events) != 3 {
+		t.Fatalf("expected the complete batch, got %d events", len(events))
+	}
+	for i, event := range events {
+		if event.BatchIndex != uint16(i) {
+			t.Fatalf("expected the events in batch order, got index %d at %d", event.BatchIndex, i)
+		}
+	}
+	if len(pendingBatches) != 0 {
+		t.Fatal("expected the complete batch to stop pending")
+	}
+}
+
+func TestCollectBatchEventBounds(t *testing.T) {
+	withPendingBatches(t)
+	for _, event := range []*entities.Event{
+		batchEvent("b1", 0, 0),
+		batchEvent("b1", 0, MaxBatchSize+1),
+		batchEvent("b1", 3, 3),
+	} {
+		if events := collectBatchEvent(event); events != nil {
+			t.Fatalf("expected index %d of a batch of %d to be rejected", event.BatchIndex, event.BatchSize)
+		}
+	}
+	if len(pendingBatches) != 0 {
+		t.Fatal("expected rejected events not to start a pending batch")
+	}
+}
+
+func TestStaleBatches(t *testing.T) {
+	withPendingBatches(t)
+	collectBatchEvent(batchEvent("b1", 1, 3))
+	now := time.Now()
+	if fetches := staleBatches(now); len(fetches) != 0 {
+		t.Fatalf("expected a fresh batch to keep waiting, got %+v", fetches)
+	}
+	now = now.Add(BatchWaitTimeout)
+	fetches := staleBatches(now)
+	if len(fetches) != 1 || fetches[0].batch != "b1" || fetches[0].validator != "v1" || fmt.Sprint(fetches[0].missing) != "[0 2]" {
+		t.Fatalf("expected indexes 0 and 2 of b1 to be requested from v1, got %+v", fetches)
+	}
+	if fetches := staleBatches(now); len(fetches) != 0 {
+		t.Fatal("expected a requested batch to wait again before the next request")
+	}
+	for i := 1; i < MaxBatchFetchAttempts; i++ {
+		now = now.Add(BatchWaitTimeout)
+		if fetches := staleBatches(now); len(fetches) != 1 {
+			t.Fatalf("attempt %d: expected the batch to be requested again", i+1)
+		}
+	}
+	now = now.Add(BatchWaitTimeout)
+	if fetches := staleBatches(now); len(fetches) != 0 || len(pendingBatches) != 0 {
+		t.Fatal("expected the batch to be dropped after the last attempt")
+	}
+}
+
+// withFetchBatchEvent answers batch event requests from events instead of the network
+func withFetchBatchEvent(t *testing.T, events map[uint16]*entities.Event) {
+	previous := fetchBatchEvent
+	fetchBatchEvent = func(cfg *configs.MainConfiguration, validator entities.PublicKeyString, batch string, index uint16) (*entities.Event, error) {
+		if event, ok := events[index]; ok {
+			return event, nil
+		}
+		return nil, fmt.Errorf("not found")
+	}
+	t.Cleanup(func() { fetchBatchEvent = previous })
+}
+
+// a batched event that arrives on its own, through a sync, completes its batch once the rest is requested
+func TestRefetchBatchCompletesLoneEvent(t *testing.T) {
+	withPendingBatches(t)
+	withFetchBatchEvent(t, map[uint16]*entities.Event{0: batchEvent("b1", 0, 3), 2: batchEvent("b1", 2, 3)})
+	collectBatchEvent(batchEvent("b1", 1, 3))
+	fetches := staleBatches(time.Now().Add(BatchWaitTimeout))
+	if len(fetches) != 1 {
+		t.Fatalf("expected one batch to request, got %d", len(fetches))
+	}
+	events := refetchBatch(&configs.MainConfiguration{}, fetches[0])
+	if len(events) != 3 {
+		t.Fatalf("expected the batch to complete, got %d events", len(events))
+	}
+}
+
+func TestRefetchBatchRejectsOtherEvents(t *testing.T) {
+	wrongBatch := batchEvent("b2", 0, 2)
+	wrongIndex := batchEvent("b1", 1, 2)
+	wrongValidator := batchEvent("b1", 0, 2)
+	wrongValidator.Validator = "v2"
+	for name, fetched := range map[string]*entities.Event{"batch": wrongBatch, "index": wrongIndex, "validator": wrongValidator} {
+		t.Run(name, func(t *testing.T) {
+			withPendingBatches(t)
+			withFetchBatchEvent(t, map[uint16]*entities.Event{0: fetched})
+			collectBatchEvent(batchEvent("b1", 1, 2))
+			fetches := staleBatches(time.Now().Add(BatchWaitTimeout))
+			if events := refetchBatch(&configs.MainConfiguration{}, fetches[0]); events != nil {
+				t.Fatal("expected the fetched event to be rejected")
+			}
+			if missing := pendingBatches["b1"].missing(); fmt.Sprint(missing) != "[0]" {
+				t.Fatalf("expected index 0 to still be missing, got %v", missing)
+			}
+		})
+	}
+}
+
+func TestBatchLockIsPerSubnet(t *testing.T) {
+	lock := batchLock("s1")
+	lock.Lock()
+	defer lock.Unlock()
+	if batchLock("s1") != lock {
+		t.Fatal("expected one lock per subnet")
+	}
+	other := batchLock("s2")
+	if !other.TryLock() {
+		t.Fatal("expected a batch of another subnet not to wait")
+	}
+	other.Unlock()
+}
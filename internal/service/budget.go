@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/common/utils"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/internal/chain"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+	"github.com/mlayerprotocol/go-mlayer/pkg/core/p2p"
+)
+
+// number of recent cycles used to project when a subnet will run out of funds
+const budgetProjectionCycles = 10
+
+/*
+on-chain balances are only refreshed once per cycle.
+The cache only holds the balances of the current cycle, it is emptied when the cycle changes
+*/
+var subnetBalances = struct {
+	sync.Mutex
+	cycle    uint64
+	balances map[string]*big.Int
+}{balances: map[string]*big.Int{}}
+
+// failed budget lookups, writes to a subnet are rejected while its budget can not be computed
+var budgetLookupFailures atomic.Uint64
+
+type SubnetBudget struct {
+	Subnet          string  `json:"snet"`
+	Cycle           uint64  `json:"cy"`
+	Balance         string  `json:"bal"`
+	Spent           string  `json:"spent"`
+	Remaining       string  `json:"rem"`
+	MessageCost     string  `json:"cost"`
+	RemainingEvents uint64  `json:"remEv"`
+	SpendPerCycle   string  `json:"spendCy"`
+	DepletionCycle  *uint64 `json:"depCy,omitempty"`
+}
+
+func getSubnetBalance(cfg *configs.MainConfiguration, subnet string, cycle uint64) (*big.Int, error) {
+	subnetBalances.Lock()
+	if subnetBalances.cycle != cycle {
+		subnetBalances.cycle = cycle
+		subnetBalances.balances = map[string]*big.Int{}
+	}
+	bal, ok := subnetBalances.balances[subnet]
+	subnetBalances.Unlock()
+	if ok {
+		return bal, nil
+	}
+	id := utils.UuidToBytes(subnet)
+	if len(id) != 16 {
+		return nil, apperror.BadRequest("Invalid subnet id")
+	}
+	bal, err := chain.DefaultProvider(cfg).GetSubnetBalance([16]byte(id))
+	if err != nil {
+		return nil, err
+	}
+	subnetBalances.Lock()
+	if subnetBalances.cycle == cycle {
+		subnetBalances.balances[subnet] = bal
+	}
+	subnetBalances.Unlock()
+	return bal, nil
+}
+
+/*
+Compute how much of the subnets on-chain balance has been used by accepted events
+*/
+func GetSubnetBudget(ctx *context.Context, subnet string) (*SubnetBudget, error) {
+	cfg, ok := (*ctx).Value(constants.ConfigKey).(*configs.MainConfiguration)
+	if !ok {
+		return nil, apperror.Internal("Unable to load config from context")
+	}
+	if chain.NetworkInfo.CurrentCycle == nil {
+		return nil, apperror.Internal("Network not synced")
+	}
+	currentCycle := chain.NetworkInfo.CurrentCycle.Uint64()
+	balance, err := getSubnetBalance(cfg, subnet, currentCycle)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := dsquery.GetSubnetUsage(subnet)
+	if err != nil {
+		return nil, err
+	}
+	spent := big.NewInt(0)
+	recentSpent := big.NewInt(0)
+	for _, u := range usage {
+		cost, err := p2p.GetCycleMessageCost(*ctx, *u.Cycle)
+		if err != nil {
+			return nil, err
+		}
+		amount := new(big.Int).Mul(cost, new(big.Int).SetUint64(*u.Count))
+		spent.Add(spent, amount)
+		if *u.Cycle+budgetProjectionCycles > currentCycle {
+			recentSpent.Add(recentSpent, amount)
+		}
+	}
+	cost, err := p2p.GetCycleMessageCost(*ctx, currentCycle)
+	if err != nil {
+		return nil, err
+	}
+	remaining := new(big.Int).Sub(balance, spent)
+	if remaining.Sign() < 0 {
+		remaining = big.NewInt(0)
+	}
+	budget := SubnetBudget{
+		Subnet:        subnet,
+		Cycle:         currentCycle,
+		Balance:       balance.String(),
+		Spent:         spent.String(),
+		Remaining:     remaining.String(),
+		MessageCost:   cost.String(),
+		SpendPerCycle: "0",
+	}
+	if cost.Sign() > 0 {
+		budget.RemainingEvents = new(big.Int).Div(remaining, cost).Uint64()
+	}
+	if recentSpent.Sign() > 0 {
+		perCycle := new(big.Int).Div(recentSpent, big.NewInt(budgetProjectionCycles))
+		budget.SpendPerCycle = perCycle.String()
+		if perCycle.Sign() > 0 {
+			depletion := currentCycle + new(big.Int).Div(remaining, perCycle).Uint64()
+			budget.DepletionCycle = &depletion
+		}
+	}
+	return &budget, nil
+}
+
+/*
+Reject writes to subnets that can no longer pay for another event.
+A budget that can not be computed (e.g. the chain is unreachable) also rejects the write,
+so that a subnet can not write past its balance while it is unknown
+*/
+func ValidateSubnetBudget(ctx *context.Context, subnet string) error {
+	budget, err := GetSubnetBudget(ctx, subnet)
+	if err != nil {
+		logger.Errorf("ValidateSubnetBudget: unable to compute budget of %s (%d failed lookups): %v", subnet, budgetLookupFailures.Add(1), err)
+		return apperror.Internal("Unable to compute the subnet budget")
+	}
+	if budget.MessageCost != "0" && budget.RemainingEvents == 0 {
+		return apperror.Forbidden("Subnet balance exhausted. Fund the subnet to continue writing")
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestValidateSubnetBudgetFailsClosed(t *testing.T) {
+	ctx := context.Background()
+	failures := budgetLookupFailures.Load()
+	if err := ValidateSubnetBudget(&ctx, "s1"); err == nil {
+		t.Fatal("expected writes to be rejected while the budget can not be computed")
+	}
+	if budgetLookupFailures.Load() != failures+1 {
+		t.Fatal("expected the failed lookup to be counted")
+	}
+}
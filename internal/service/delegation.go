@@ -0,0 +1,141 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/common/utils"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+)
+
+// maximum number of grantor hops between an agent and the account
+const MaxDelegationDepth = 8
+
+// CanGrantPriviledge reports whether a grantor holding one priviledge may issue another
+func CanGrantPriviledge(grantor constants.AuthorizationPrivilege, granted constants.AuthorizationPrivilege) bool {
+	switch grantor {
+	case constants.AdminPriviledge:
+		return true
+	case constants.ManagerPriviledge:
+		return granted <= constants.MemberPriviledge
+	}
+	return false
+}
+
+func isAccountIssued(auth *entities.Authorization) bool {
+	return auth.Grantor == "" || strings.EqualFold(entities.AddressFromString(string(auth.Grantor)).Addr, entities.AddressFromString(string(auth.Account)).Addr)
+}
+
+// coversTopics reports whether every topic the child is scoped to is also in the parent's scope
+func coversTopics(parent *entities.Authorization, child *entities.Authorization) bool {
+	parentTopics := parent.TopicIdList()
+	if parentTopics == nil {
+		return true
+	}
+	childTopics := child.TopicIdList()
+	if childTopics == nil {
+		return false
+	}
+	for _, topic := range childTopics {
+		if !slices.Contains(parentTopics, topic) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Walk the grantors of auth back to the grant issued by the account itself,
+verifying that every link is still active and allowed to grant the priviledge and topics below it.
+The account's own grant is then checked against the subnet, only the subnet owner is not bound by its default priviledge.
+The returned chain starts with auth and ends with the root grant
+*/
+func GetAuthorizationChain(auth *entities.Authorization, subnet *entities.Subnet) (chain []*entities.Authorization, err error) {
+	return getAuthorizationChain(auth, subnet, nil)
+}
+
+/*
+Check the delegation chain of a grantor-issued authorization when its agent acts, so that revoking any link invalidates the grants below it.
+With an event, links are checked as they stood when the event was created, as IsRevokedBefore does for the agent's own authorization
+*/
+func ValidateDelegation(auth *entities.Authorization, event *entities.Event) error {
+	if isAccountIssued(auth) {
+		return nil
+	}
+	subnet, err := dsquery.GetSubnetStateById(auth.Subnet)
+	if err != nil {
+		if dsquery.IsErrorNotFound(err) {
+			return apperror.NotFound("Subnet not found")
+		}
+		return err
+	}
+	_, err = getAuthorizationChain(auth, subnet, event)
+	return err
+}
+
+func getAuthorizationChain(auth *entities.Authorization, subnet *entities.Subnet, event *entities.Event) (chain []*entities.Authorization, err error) {
+	chain = []*entities.Authorization{auth}
+	current := auth
+	for !isAccountIssued(current) {
+		if len(chain) > MaxDelegationDepth {
+			return chain, apperror.Forbidden("Delegation chain too long")
+		}
+		parent, err := dsquery.GetCurrentAuthorization(entities.Authorization{
+			Account: entities.AddressFromString(string(current.Account)).ToString(),
+			Subnet:  current.Subnet,
+			Agent:   entities.AddressFromString(string(current.Grantor)).ToDeviceString(),
+		}, nil)
+		if err != nil && !dsquery.IsErrorNotFound(err) {
+			return chain, err
+		}
+		if parent == nil {
+			return chain, apperror.Forbidden(fmt.Sprintf("Grantor %s is not authorized", current.Grantor))
+		}
+		for _, link := range chain {
+			if link.Event.ID != "" && link.Event.ID == parent.Event.ID {
+				return chain, apperror.Forbidden("Delegation chain has a cycle")
+			}
+		}
+		chain = append(chain, parent)
+		if !isLinkActive(parent, event) {
+			return chain, apperror.Forbidden(fmt.Sprintf("Grantor %s authorization is no longer active", current.Grantor))
+		}
+		// a link revoked after the event no longer holds the priviledge it granted with, the grant was checked when it was made
+		if !parent.IsRevoked() {
+			if parent.Priviledge == nil || current.Priviledge == nil || !CanGrantPriviledge(*parent.Priviledge, *current.Priviledge) {
+				return chain, apperror.Forbidden(fmt.Sprintf("Grantor %s cannot grant this priviledge", current.Grantor))
+			}
+			if !coversTopics(parent, current) {
+				return chain, apperror.Forbidden(fmt.Sprintf("Grantor %s cannot grant topics outside its own scope", current.Grantor))
+			}
+		}
+		current = parent
+	}
+	// revocations grant nothing
+	if current.IsRevoked() || strings.EqualFold(entities.AddressFromString(string(current.Account)).Addr, entities.AddressFromString(string(subnet.Account)).Addr) {
+		return chain, nil
+	}
+	defaultPriviledge := utils.SafePointerValue(subnet.DefaultAuthPrivilege, constants.UnauthorizedPriviledge)
+	if defaultPriviledge == constants.UnauthorizedPriviledge {
+		return chain, apperror.Forbidden("Subnet does not accept external accounts")
+	}
+	if current.Priviledge == nil || *current.Priviledge > defaultPriviledge {
+		return chain, apperror.Forbidden(fmt.Sprintf("Account %s cannot hold a priviledge above the subnets default", current.Account))
+	}
+	return chain, nil
+}
+
+// isLinkActive reports whether a grantor's authorization was neither revoked nor expired, now or when the event was created
+func isLinkActive(link *entities.Authorization, event *entities.Event) bool {
+	if event == nil {
+		return !link.IsRevoked() && !IsAuthorizationExpired(link)
+	}
+	if IsRevokedBefore(link, event) {
+		return false
+	}
+	return link.ExpiresAt() == 0 || event.Payload.Timestamp < link.ExpiresAt()
+}
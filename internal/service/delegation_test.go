@@ -0,0 +1,108 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+)
+
+const (
+	delegationAccount = entities.DIDString("did:0x8f6b2a1e3c4d5e6f708192a3b4c5d6e7f8091a2b")
+	delegationManager = entities.DeviceString("did:0x1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d")
+	delegationMember  = entities.DeviceString("did:0x2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e")
+	delegationTopic   = "6f1c9a8e-3b2d-4c5e-9f10-2a3b4c5d6e7f"
+	delegationOther   = "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"
+)
+
+func delegationGrant(agent entities.DeviceString, grantor entities.DIDString, privilege constants.AuthorizationPrivilege, topics string, eventId string, block uint64, timestamp uint64) *entities.Authorization {
+	return &entities.Authorization{
+		ID: "auth-" + eventId, Account: delegationAccount, Agent: agent, Subnet: "s1", Grantor: grantor, Priviledge: &privilege,
+		TopicIds: topics, BlockNumber: block, Timestamp: &timestamp, Event: entities.EventPath{EntityPath: entities.EntityPath{ID: eventId}},
+	}
+}
+
+// withDelegation saves the subnet and a manager agent granted by the account
+func withDelegation(t *testing.T, managerTopics string) *entities.Subnet {
+	withTestStores(t)
+	subnet := &entities.Subnet{ID: "s1", Account: delegationAccount}
+	putTestState(t, entities.SubnetModel, subnet.ID, "ev-s1", subnet.MsgPack())
+	if _, err := dsquery.CreateAuthorizationState(delegationGrant(delegationManager, delegationAccount, constants.ManagerPriviledge, managerTopics, "e1", 5, 1000), nil); err != nil {
+		t.Fatal(err)
+	}
+	return subnet
+}
+
+func TestCanGrantPriviledge(t *testing.T) {
+	if !CanGrantPriviledge(constants.AdminPriviledge, constants.ManagerPriviledge) {
+		t.Error("expected an admin to grant manager")
+	}
+	if !CanGrantPriviledge(constants.ManagerPriviledge, constants.MemberPriviledge) {
+		t.Error("expected a manager to grant member")
+	}
+	if CanGrantPriviledge(constants.ManagerPriviledge, constants.ManagerPriviledge) {
+		t.Error("expected a manager not to grant its own priviledge")
+	}
+	if CanGrantPriviledge(constants.MemberPriviledge, constants.MemberPriviledge) {
+		t.Error("expected a member not to grant")
+	}
+}
+
+func TestDelegatedTopicsMustBeWithinTheGrantorsScope(t *testing.T) {
+	subnet := withDelegation(t, delegationTopic)
+	grantor := entities.DIDString(delegationManager)
+	if _, err := GetAuthorizationChain(delegationGrant(delegationMember, grantor, constants.MemberPriviledge, delegationTopic, "e2", 6, 2000), subnet); err != nil {
+		t.Fatalf("expected a grant within the grantors topics: %v", err)
+	}
+	if _, err := GetAuthorizationChain(delegationGrant(delegationMember, grantor, constants.MemberPriviledge, delegationOther, "e2", 6, 2000), subnet); err == nil {
+		t.Fatal("expected a grant of another topic to be rejected")
+	}
+	if _, err := GetAuthorizationChain(delegationGrant(delegationMember, grantor, constants.MemberPriviledge, "", "e2", 6, 2000), subnet); err == nil {
+		t.Fatal("expected an unscoped grant from a scoped grantor to be rejected")
+	}
+}
+
+func TestDelegationUsesTheGrantorsCurrentAuthorization(t *testing.T) {
+	subnet := withDelegation(t, "")
+	member := delegationGrant(delegationMember, entities.DIDString(delegationManager), constants.MemberPriviledge, "", "e2", 6, 2000)
+	if _, err := GetAuthorizationChain(member, subnet); err != nil {
+		t.Fatalf("expected the manager to grant member: %v", err)
+	}
+	// the manager is demoted, its earlier grant no longer counts
+	if _, err := dsquery.CreateAuthorizationState(delegationGrant(delegationManager, delegationAccount, constants.MemberPriviledge, "", "e3", 7, 3000), nil); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := GetAuthorizationChain(member, subnet)
+	if err == nil {
+		t.Fatal("expected a demoted grantor not to grant member")
+	}
+	if len(chain) != 2 || chain[1].Event.ID != "e3" {
+		t.Fatal("expected the chain to end at the grantors current authorization")
+	}
+}
+
+func TestRevokingAGrantorInvalidatesLaterEvents(t *testing.T) {
+	withDelegation(t, "")
+	member := delegationGrant(delegationMember, entities.DIDString(delegationManager), constants.MemberPriviledge, "", "e2", 6, 2000)
+	if _, err := dsquery.CreateAuthorizationState(member, nil); err != nil {
+		t.Fatal(err)
+	}
+	before := &entities.Event{BlockNumber: 9, Payload: entities.ClientPayload{Timestamp: 4000}}
+	after := &entities.Event{BlockNumber: 11, Payload: entities.ClientPayload{Timestamp: 6000}}
+	if err := ValidateDelegation(member, after); err != nil {
+		t.Fatalf("expected the member to act while the manager is authorized: %v", err)
+	}
+	if _, err := dsquery.CreateAuthorizationState(delegationGrant(delegationManager, delegationAccount, constants.UnauthorizedPriviledge, "", "e3", 10, 5000), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateDelegation(member, after); err == nil {
+		t.Fatal("expected events after the managers revocation to be rejected")
+	}
+	if err := ValidateDelegation(member, before); err != nil {
+		t.Fatalf("expected events before the managers revocation to be accepted: %v", err)
+	}
+	if err := ValidateDelegation(member, nil); err == nil {
+		t.Fatal("expected new payloads of the member to be rejected")
+	}
+}
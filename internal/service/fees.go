@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/internal/chain"
+	"github.com/mlayerprotocol/go-mlayer/pkg/core/p2p"
+)
+
+const (
+	DefaultFeeHistoryCycles = 10
+	MaxFeeHistoryCycles     = 100
+	// MaxFeeHistoryLookups caps the past cycle prices a quote reads from the chain, the rest come from the cache only
+	MaxFeeHistoryLookups   = 10
+	NextPriceCacheDuration = 30 * time.Second
+)
+
+type CyclePrice struct {
+	Cycle uint64 `json:"cy"`
+	Price string `json:"pr"`
+}
+
+type FeeQuote struct {
+	Cycle    uint64       `json:"cy"`
+	Current  CyclePrice   `json:"current"`
+	Next     CyclePrice   `json:"next"`
+	Events   uint64       `json:"events"`
+	Cost     string       `json:"cost"`
+	NextCost string       `json:"nextCost"`
+	History  []CyclePrice `json:"history"`
+}
+
+type cachedPrice struct {
+	cycle   uint64
+	price   *big.Int
+	fetched time.Time
+}
+
+var (
+	nextPriceMutex sync.Mutex
+	nextPrice      cachedPrice
+)
+
+// nextCyclePrice reads the next cycles price from the chain at most once every NextPriceCacheDuration
+func nextCyclePrice(cfg *configs.MainConfiguration, cycle uint64) (*big.Int, error) {
+	nextPriceMutex.Lock()
+	defer nextPriceMutex.Unlock()
+	if nextPrice.price != nil && nextPrice.cycle == cycle && time.Since(nextPrice.fetched) < NextPriceCacheDuration {
+		return nextPrice.price, nil
+	}
+	price, err := chain.DefaultProvider(cfg).GetMessagePrice(new(big.Int).SetUint64(cycle))
+	if err != nil {
+		return nil, err
+	}
+	nextPrice = cachedPrice{cycle: cycle, price: price, fetched: time.Now()}
+	return price, nil
+}
+
+/*
+QuoteEventFees prices a batch of events for the current and the next cycle and lists the price of past cycles.
+Prices are the ones subnets are billed with. The next cycles price may still change before the cycle starts,
+so it is only cached briefly. Past cycles that are not cached yet are read from the chain up to MaxFeeHistoryLookups per quote
+and left out of the history beyond that
+*/
+func QuoteEventFees(ctx *context.Context, events uint64, historyCycles uint64) (*FeeQuote, error) {
+	cfg, ok := (*ctx).Value(constants.ConfigKey).(*configs.MainConfiguration)
+	if !ok {
+		panic("Unable to get config from context")
+	}
+	if chain.NetworkInfo.CurrentCycle == nil {
+		return nil, apperror.Internal("Network not synced")
+	}
+	currentCycle := chain.NetworkInfo.CurrentCycle.Uint64()
+	current, err := p2p.GetCycleMessageCost(*ctx, currentCycle)
+	if err != nil {
+		return nil, err
+	}
+	next, err := nextCyclePrice(cfg, currentCycle+1)
+	if err != nil {
+		return nil, err
+	}
+	return buildFeeQuote(currentCycle, current, next, events, historyCycles,
+		func(cycle uint64) (*big.Int, error) { return p2p.GetCachedCycleMessageCost(*ctx, cycle) },
+		func(cycle uint64) (*big.Int, error) { return p2p.GetAndSaveMessageCostFromChain(ctx, cycle) },
+	)
+}
+
+/*
+buildFeeQuote prices events at the current and next cycles price and lists up to historyCycles past prices.
+cached returns nil for the cycles whose price is not cached, only MaxFeeHistoryLookups of those are fetched
+*/
+func buildFeeQuote(currentCycle uint64, current *big.Int, next *big.Int, events uint64, historyCycles uint64, cached func(uint64) (*big.Int, error), fetch func(uint64) (*big.Int, error)) (*FeeQuote, error) {
+	if historyCycles > MaxFeeHistoryCycles {
+		historyCycles = MaxFeeHistoryCycles
+	}
+	count := new(big.Int).SetUint64(events)
+	quote := FeeQuote{
+		Cycle:    currentCycle,
+		Current:  CyclePrice{Cycle: currentCycle, Price: current.String()},
+		Next:     CyclePrice{Cycle: currentCycle + 1, Price: next.String()},
+		Events:   events,
+		Cost:     new(big.Int).Mul(current, count).String(),
+		NextCost: new(big.Int).Mul(next, count).String(),
+		History:  []CyclePrice{},
+	}
+	lookups := 0
+	for i := uint64(1); i <= historyCycles && i <= currentCycle; i++ {
+		cycle := currentCycle - i
+		price, err := cached(cycle)
+		if err != nil {
+			return nil, err
+		}
+		if price == nil {
+			if lookups >= MaxFeeHistoryLookups {
+				continue
+			}
+			lookups++
+			if price, err = fetch(cycle); err != nil {
+				return nil, err
+			}
+		}
+		quote.History = append(quote.History, CyclePrice{Cycle: cycle, Price: price.String()})
+	}
+	return &quote, nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestFeeQuoteArithmetic(t *testing.T) {
+	current, _ := new(big.Int).SetString("250000000000000000", 10)
+	next, _ := new(big.Int).SetString("300000000000000000", 10)
+	none := func(uint64) (*big.Int, error) { return nil, nil }
+	quote, err := buildFeeQuote(7, current, next, 1000, 0, none, none)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Cost != "250000000000000000000" || quote.NextCost != "300000000000000000000" {
+		t.Fatalf("expected 1000 events at each price, got %s and %s", quote.Cost, quote.NextCost)
+	}
+	if quote.Current.Cycle != 7 || quote.Next.Cycle != 8 || quote.Next.Price != next.String() {
+		t.Fatalf("unexpected cycles %+v %+v", quote.Current, quote.Next)
+	}
+	if len(quote.History) != 0 {
+		t.Fatalf("expected no history, got %d cycles", len(quote.History))
+	}
+}
+
+func TestFeeQuoteHistoryCap(t *testing.T) {
+	price := big.NewInt(5)
+	// every other past cycle is cached
+	cached := func(cycle uint64) (*big.Int, error) {
+		if cycle%2 == 0 {
+			return price, nil
+		}
+		return nil, nil
+	}
+	fetched := 0
+	fetch := func(uint64) (*big.Int, error) {
+		fetched++
+		return price, nil
+	}
+	quote, err := buildFeeQuote(1000, price, price, 1, MaxFeeHistoryCycles+50, cached, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched != MaxFeeHistoryLookups {
+		t.Fatalf("expected %d chain lookups, got %d", MaxFeeHistoryLookups, fetched)
+	}
+	if expected := MaxFeeHistoryCycles/2 + MaxFeeHistoryLookups; len(quote.History) != expected {
+		t.Fatalf("expected %d cached and fetched cycles, got %d", expected, len(quote.History))
+	}
+	if last := quote.History[len(quote.History)-1].Cycle; last != 1000-MaxFeeHistoryCycles {
+		t.Fatalf("expected the history to stop %d cycles back, ended at %d", MaxFeeHistoryCycles, last)
+	}
+
+	quote, err = buildFeeQuote(3, price, price, 1, DefaultFeeHistoryCycles, func(uint64) (*big.Int, error) { return price, nil }, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quote.History) != 3 || quote.History[2].Cycle != 0 {
+		t.Fatalf("expected the history to stop at cycle 0, got %+v", quote.History)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ipfs/go-datastore"
 	"github.com/mlayerprotocol/go-mlayer/common/apperror"
 	"github.com/mlayerprotocol/go-mlayer/common/constants"
 	"github.com/mlayerprotocol/go-mlayer/common/encoder"
@@ -74,9 +75,14 @@ func SyncEventByPath(path *entities.EventPath, cfg *configs.MainConfiguration, v
 
 
 func SyncTypedStateById[M any](did string, model *M, cfg *configs.MainConfiguration, validator string) (event *entities.Event, err error) {
+	return SyncTypedStateByIdTxn(did, model, cfg, validator, nil)
+}
+
+// SyncTypedStateByIdTxn looks the state up through txn before syncing it from a peer
+func SyncTypedStateByIdTxn[M any](did string, model *M, cfg *configs.MainConfiguration, validator string, txn *datastore.Txn) (event *entities.Event, err error) {
 	var stateData []byte
 	modelType :=  entities.GetModel(model)
-	stateData, err = dsquery.GetStateById(did, modelType)
+	stateData, err = dsquery.GetStateByIdTxn(did, modelType, txn)
 	if err != nil {
 		if !dsquery.IsErrorNotFound(err) {
 			return nil, err
@@ -260,21 +266,46 @@ func ValidateMessageClient(
 }
 
 func HandleNewPubSubEvent(event entities.Event, ctx *context.Context) error {
+	cfg, _ := (*ctx).Value(constants.ConfigKey).(*configs.MainConfiguration)
+	if event.Batch != "" && !event.IsLocal(cfg) {
+		// the events of another validators batch are only applied together
+		if _, err := dsquery.GetEventGeneric(event.ID); err == nil {
+			// the batch was applied, or rejected, before this copy of its event arrived
+			return nil
+		}
+		if events := collectBatchEvent(&event); events != nil {
+			return HandleEventBatch(events, ctx)
+		}
+		return nil
+	}
 	go func () {
 		channelpool.EventCounterChannel <- &event
 	}()
-	
+	err := handleEvent(&event, ctx)
+	if event.IsLocal(cfg) {
+		// the event has been committed or rejected, so the reservation of its nonce is no longer needed
+		ReleasePayloadNonce(&event.Payload)
+	}
+	return broadcastEvent(&event, ctx, err)
+}
+
+// handleEvent passes the event to the handler of its payload type
+func handleEvent(event *entities.Event, ctx *context.Context) error {
 	switch  event.Payload.Data.(type) {
 	case entities.Subnet:
-		return broadcastEvent(&event, ctx, HandleNewPubSubSubnetEvent(&event, ctx))
+		return HandleNewPubSubSubnetEvent(event, ctx)
 	case entities.Authorization:
-		return broadcastEvent(&event, ctx, HandleNewPubSubAuthEvent(&event, ctx))
+		return HandleNewPubSubAuthEvent(event, ctx)
 	case entities.Topic:
-		return broadcastEvent(&event, ctx, HandleNewPubSubTopicEvent(&event, ctx))
+		return HandleNewPubSubTopicEvent(event, ctx)
 	case entities.Subscription:
-		return broadcastEvent(&event, ctx, HandleNewPubSubSubscriptionEvent(&event, ctx))
+		return HandleNewPubSubSubscriptionEvent(event, ctx)
 	case entities.Message:
-		return broadcastEvent(&event, ctx, HandleNewPubSubMessageEvent(&event, ctx)) 
+		return HandleNewPubSubMessageEvent(event, ctx)
+	case entities.Wallet:
+		return HandleNewPubSubWalletEvent(event, ctx)
+	case entities.TokenTransaction:
+		return HandleNewPubSubTokenEvent(event, ctx)
 	}
 	return nil
 }
@@ -331,6 +362,7 @@ func OnFinishProcessingEvent(ctx *context.Context, event  *entities.Event, state
 			subs.Conn.WriteJSON(payload)
 		}
 	}
+	go DispatchWebhooks(ctx, event)
 	if string(event.Validator) != config.PublicKeyEDDHex {
 		go func () {
 			dependent, err := dsquery.GetDependentEvents(event)
@@ -436,32 +468,13 @@ func OnFinishProcessingEvent(ctx *context.Context, event  *entities.Event, state
 
 }
 
-func IsMoreRecentEvent(
-	oldEventHash string,
-	oldEventTimestamp int,
-	recentEventHash string,
-	recentEventTimestamp int,
-) bool {
-
-	if oldEventTimestamp < recentEventTimestamp {
-		return true
-	}
-	if oldEventTimestamp > recentEventTimestamp {
-		return false
-	}
-	// // if the authorization was created at exactly the same time but their hash is different
-	// // use the last 4 digits of their event hash
-	// csN := new(big.Int)
-	// csN.SetString(oldEventHash[50:], 16)
-	// nsN := new(big.Int)
-	// nsN.SetString(recentEventHash[50:], 16)
-	if oldEventHash < recentEventHash {
-		return true
-	}
-	if oldEventHash > recentEventHash {
-		return false
-	}
-	return false
+/*
+IsMoreRecentEvent decides which of two conflicting events updates an entity.
+The event in the later block wins, then the later timestamp, then the greater hash. See entities.CompareEventOrder
+*/
+func IsMoreRecentEvent(old entities.EventOrder, recent entities.EventOrder) bool {
+	cmp, _ := entities.CompareEventOrder(old, recent)
+	return cmp < 0
 }
 
 func IsMoreRecent(
@@ -999,7 +1012,7 @@ func HandleNewPubSubEvent(event *entities.Event, ctx *context.Context, validator
 		}
 	}
 
-	d, err := event.Payload.EncodeBytes()
+	d, err := event.Payload.SigningBytes()
 	if err != nil {
 		logger.Errorf("Invalid event payload")
 	}
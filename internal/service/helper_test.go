@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+	"github.com/mlayerprotocol/go-mlayer/pkg/core/ds"
+)
+
+// withTestStores points the state, event and message stores at empty stores for the test
+func withTestStores(t *testing.T) {
+	state, err := ds.NewDatastore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := ds.NewDatastore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := ds.NewDatastore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousState, previousEvents, previousMessages := stores.StateStore, stores.EventStore, stores.MessageStore
+	stores.StateStore, stores.EventStore, stores.MessageStore = state, events, messages
+	t.Cleanup(func() {
+		stores.StateStore, stores.EventStore, stores.MessageStore = previousState, previousEvents, previousMessages
+		state.Close()
+		events.Close()
+		messages.Close()
+	})
+}
+
+// putTestState saves data as the current state of id, produced by the event eventId
+func putTestState(t *testing.T, model entities.EntityModel, id string, eventId string, data []byte) {
+	ctx := context.Background()
+	if err := stores.StateStore.Put(ctx, datastore.NewKey(dsquery.EntityKey(model, id)), []byte(eventId)); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.StateStore.Put(ctx, datastore.NewKey(dsquery.EntityDataKey(model, eventId)), data); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/common/encoder"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/channelpool"
+	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+)
+
+type IntegrityCheck string
+
+const (
+	SignatureCheck IntegrityCheck = "signature"
+	HashCheck      IntegrityCheck = "hash"
+	ModelCheck     IntegrityCheck = "model"
+	DanglingCheck  IntegrityCheck = "dangling"
+	StateCheck     IntegrityCheck = "state"
+)
+
+type IntegrityIssue struct {
+	Check     IntegrityCheck       `json:"chk"`
+	Event     string               `json:"e,omitempty"`
+	Model     entities.EntityModel `json:"mod,omitempty"`
+	State     string               `json:"st,omitempty"`
+	Ref       *entities.EventPath  `json:"ref,omitempty"`
+	Detail    string               `json:"det"`
+	Refetched bool                 `json:"refetched,omitempty"`
+}
+
+type IntegrityReport struct {
+	Events uint64           `json:"events"`
+	States uint64           `json:"states"`
+	Issues []IntegrityIssue `json:"issues"`
+}
+
+func (r *IntegrityReport) add(issue IntegrityIssue) {
+	r.Issues = append(r.Issues, issue)
+}
+
+var stateModels = []entities.EntityModel{entities.SubnetModel, entities.AuthModel, entities.TopicModel, entities.SubscriptionModel, entities.MessageModel, entities.WalletModel}
+
+// eventModel is the model an event type belongs to, as stored in event paths
+func eventModel(e *entities.Event) entities.EntityModel {
+	return entities.GetModelTypeFromEventType(constants.EventType(e.EventType))
+}
+
+/*
+VerifyEventStore walks the event store and checks each events signature, id and payload hash,
+that its type maps to a model and that the events it links to exist with that model.
+It then checks that every current state points to a stored, valid event of its model.
+With refetch set, dangling references are requested from peers and queued for processing
+*/
+func VerifyEventStore(cfg *configs.MainConfiguration, refetch bool) (*IntegrityReport, error) {
+	report := &IntegrityReport{Issues: []IntegrityIssue{}}
+	err := dsquery.ForEachEvent(func(b []byte) error {
+		report.Events++
+		generic, err := entities.UnpackEventGeneric(b)
+		if err != nil {
+			report.add(IntegrityIssue{Check: HashCheck, Detail: fmt.Sprintf("undecodable event: %v", err)})
+			return nil
+		}
+		model := eventModel(generic)
+		if model == "" {
+			report.add(IntegrityIssue{Check: ModelCheck, Event: generic.ID, Detail: fmt.Sprintf("event type %d has no model", generic.EventType)})
+			return nil
+		}
+		event, err := entities.UnpackEvent(b, model)
+		if err != nil {
+			report.add(IntegrityIssue{Check: ModelCheck, Event: generic.ID, Model: model, Detail: err.Error()})
+			return nil
+		}
+		verifyEvent(event, model, report)
+		for _, ref := range []entities.EventPath{event.PreviousEvent, event.AuthEvent} {
+			verifyEventRef(event, ref, cfg, refetch, report)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	for _, model := range stateModels {
+		err = dsquery.ForEachState(model, func(id string, eventId string, data []byte) error {
+			report.States++
+			verifyState(model, id, eventId, data, report)
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func verifyEvent(event *entities.Event, model entities.EntityModel, report *IntegrityReport) {
+	b, err := event.EncodeBytes()
+	if err != nil {
+		report.add(IntegrityIssue{Check: HashCheck, Event: event.ID, Model: model, Detail: err.Error()})
+		return
+	}
+	sign, _ := hex.DecodeString(event.Signature)
+	if validator := event.Validator.Bytes(); len(validator) != ed25519.PublicKeySize {
+		report.add(IntegrityIssue{Check: SignatureCheck, Event: event.ID, Model: model, Detail: "invalid validator public key"})
+	} else if valid, err := crypto.VerifySignatureEDD(validator, &b, sign); err != nil || !valid {
+		report.add(IntegrityIssue{Check: SignatureCheck, Event: event.ID, Model: model, Detail: "invalid validator signature"})
+	}
+	if id, err := event.GetId(); err != nil || id != event.ID {
+		report.add(IntegrityIssue{Check: HashCheck, Event: event.ID, Model: model, Detail: "event id does not match its signature"})
+	}
+	payloadBytes, err := event.Payload.EncodeBytes()
+	if err != nil || hex.EncodeToString(crypto.Keccak256Hash(payloadBytes)) != event.PayloadHash {
+		report.add(IntegrityIssue{Check: HashCheck, Event: event.ID, Model: model, Detail: "payload does not match the payload hash"})
+	}
+	verifyPayloadSignature(event, model, report)
+}
+
+// verifyPayloadSignature checks that the client payload was signed by the agent the event was accepted from
+func verifyPayloadSignature(event *entities.Event, model entities.EntityModel, report *IntegrityReport) {
+	payload := event.Payload
+	if payload.Signature == "" {
+		// account signed events like subnets and authorizations carry their signature in the data
+		if payload.Agent != "" {
+			report.add(IntegrityIssue{Check: SignatureCheck, Event: event.ID, Model: model, Detail: "client payload is not signed"})
+		}
+		return
+	}
+	agent, err := payload.GetSigner()
+	if err != nil || agent == "" {
+		report.add(IntegrityIssue{Check: SignatureCheck, Event: event.ID, Model: model, Detail: "invalid client payload signature"})
+		return
+	}
+	if event.Payload.Agent != "" && !strings.EqualFold(entities.AddressFromString(string(event.Payload.Agent)).Addr, entities.AddressFromString(string(agent)).Addr) {
+		report.add(IntegrityIssue{Check: SignatureCheck, Event: event.ID, Model: model, Detail: fmt.Sprintf("client payload was signed by %s, not the agent %s", agent, event.Payload.Agent)})
+	}
+}
+
+// verifyEventRef checks that a linked event is stored and belongs to the model its path names
+func verifyEventRef(event *entities.Event, ref entities.EventPath, cfg *configs.MainConfiguration, refetch bool, report *IntegrityReport) {
+	if ref.ID == "" {
+		return
+	}
+	// only the event type is checked, and paths without a model can not decode the payload
+	linked, err := dsquery.GetEventGeneric(ref.ID)
+	if err != nil {
+		issue := IntegrityIssue{Check: DanglingCheck, Event: event.ID, Model: ref.Model, Ref: &ref, Detail: "linked event not found"}
+		if !dsquery.IsErrorNotFound(err) {
+			issue.Detail = err.Error()
+		} else if refetch {
+			fetched, _, err := SyncEventByPath(&ref, cfg, string(ref.Validator))
+			if err == nil && fetched != nil {
+				// never wait on a busy processor, the event is refetched on a later run
+				select {
+				case channelpool.EventProcessorChannel <- fetched:
+					issue.Refetched = true
+				default:
+					issue.Detail = "linked event not found and the event processor is busy"
+				}
+			} else if err != nil {
+				issue.Detail = fmt.Sprintf("linked event not found and refetch failed: %v", err)
+			}
+		}
+		report.add(issue)
+		return
+	}
+	if ref.Model != "" && eventModel(linked) != ref.Model {
+		report.add(IntegrityIssue{Check: ModelCheck, Event: event.ID, Model: ref.Model, Ref: &ref, Detail: fmt.Sprintf("linked event is a %s event", eventModel(linked))})
+	}
+}
+
+// verifyState checks that a current state is stored for its latest event and that the event is a valid one of its model
+func verifyState(model entities.EntityModel, id string, eventId string, data []byte, report *IntegrityReport) {
+	if len(data) == 0 {
+		report.add(IntegrityIssue{Check: StateCheck, State: id, Model: model, Event: eventId, Detail: "state has no data for its latest event"})
+		return
+	}
+	var state struct {
+		Event entities.EventPath `json:"e"`
+		Hash  string             `json:"h"`
+	}
+	if err := encoder.MsgPackUnpackStruct(data, &state); err != nil {
+		report.add(IntegrityIssue{Check: StateCheck, State: id, Model: model, Event: eventId, Detail: fmt.Sprintf("undecodable state: %v", err)})
+		return
+	}
+	if state.Event.ID != eventId {
+		report.add(IntegrityIssue{Check: StateCheck, State: id, Model: model, Event: eventId, Detail: fmt.Sprintf("state data was written by event %s", state.Event.ID)})
+	}
+	event, err := dsquery.GetEventById(eventId, model)
+	if err != nil {
+		report.add(IntegrityIssue{Check: DanglingCheck, State: id, Model: model, Event: eventId, Detail: "latest event of state not found"})
+		return
+	}
+	if eventModel(event) != model {
+		report.add(IntegrityIssue{Check: ModelCheck, State: id, Model: model, Event: eventId, Detail: fmt.Sprintf("latest event of state is a %s event", eventModel(event))})
+	}
+	if hash, err := stateContentHash(model, data); err != nil || (hash != "" && hash != state.Hash) {
+		report.add(IntegrityIssue{Check: StateCheck, State: id, Model: model, Event: eventId, Detail: "state contents do not match its hash"})
+	}
+	// states are written with the hash of their latest event's data, so the contents must match it
+	if data, ok := event.Payload.Data.(entities.Payload); ok && state.Hash != "" {
+		if hash, err := data.GetHash(); err != nil || hex.EncodeToString(hash) != state.Hash {
+			report.add(IntegrityIssue{Check: StateCheck, State: id, Model: model, Event: eventId, Detail: "state contents do not match its latest event"})
+		}
+	}
+	if event.IsValid != nil && !*event.IsValid && event.Synced != nil && *event.Synced {
+		report.add(IntegrityIssue{Check: StateCheck, State: id, Model: model, Event: eventId, Detail: "latest event of state was rejected: " + event.Error})
+	}
+}
+
+// stateContentHash rehashes the contents of a state without the hash it was saved with, "" for models it does not cover
+func stateContentHash(model entities.EntityModel, data []byte) (string, error) {
+	var payload entities.Payload
+	var err error
+	switch model {
+	case entities.SubnetModel:
+		var state entities.Subnet
+		state, err = entities.UnpackSubnet(data)
+		state.Hash = ""
+		payload = state
+	case entities.AuthModel:
+		var state entities.Authorization
+		state, err = entities.UnpackAuthorization(data)
+		if err == nil && (state.Duration == nil || state.Priviledge == nil || state.Timestamp == nil) {
+			return "", fmt.Errorf("incomplete authorization state")
+		}
+		state.Hash = ""
+		payload = state
+	case entities.TopicModel:
+		var state entities.Topic
+		state, err = entities.UnpackTopic(data)
+		state.Hash = ""
+		payload = state
+	case entities.SubscriptionModel:
+		var state entities.Subscription
+		state, err = entities.UnpackSubscription(data)
+		state.Hash = ""
+		payload = state
+	default:
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	hash, err := payload.GetHash()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash), nil
+}
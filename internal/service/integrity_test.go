@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+)
+
+const integrityTopicId = "6f1c9a8e-3b2d-4c5e-9f10-2a3b4c5d6e7f"
+
+// putTestEvent stores an event of eventType carrying data under id
+func putTestEvent(t *testing.T, id string, eventType constants.EventType, data any) {
+	event := entities.Event{ID: id, EventType: uint16(eventType), Payload: entities.ClientPayload{Data: data}}
+	if err := stores.EventStore.Put(context.Background(), datastore.NewKey(event.DataKey()), event.MsgPack()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func integrityTopic(eventId string) entities.Topic {
+	return entities.Topic{ID: integrityTopicId, Ref: "general", Event: entities.EventPath{EntityPath: entities.EntityPath{Model: entities.TopicModel, ID: eventId}}}
+}
+
+func topicStateData(t *testing.T, topic entities.Topic) []byte {
+	hash, err := topic.GetHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	topic.Hash = hex.EncodeToString(hash)
+	return topic.MsgPack()
+}
+
+func TestVerifyState(t *testing.T) {
+	withTestStores(t)
+	putTestEvent(t, "e1", constants.CreateTopicEvent, integrityTopic("e1"))
+	putTestEvent(t, "e2", constants.CreateSubnetEvent, entities.Subnet{ID: "s1"})
+	// the state keeps the hash of the event's data but its contents were edited
+	tampered, err := entities.UnpackTopic(topicStateData(t, integrityTopic("e1")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered.Ref = "renamed"
+
+	tests := []struct {
+		name    string
+		eventId string
+		data    []byte
+		check   IntegrityCheck
+	}{
+		{"state of its latest event", "e1", topicStateData(t, integrityTopic("e1")), ""},
+		{"no data", "e1", nil, StateCheck},
+		{"undecodable data", "e1", []byte{0xc1}, StateCheck},
+		{"data written by another event", "e1", topicStateData(t, integrityTopic("e0")), StateCheck},
+		{"contents changed after the event", "e1", tampered.MsgPack(), StateCheck},
+		{"latest event missing", "e3", topicStateData(t, integrityTopic("e3")), DanglingCheck},
+		{"latest event of another model", "e2", topicStateData(t, integrityTopic("e2")), ModelCheck},
+	}
+	for _, tt := range tests {
+		report := &IntegrityReport{}
+		verifyState(entities.TopicModel, integrityTopicId, tt.eventId, tt.data, report)
+		if tt.check == "" {
+			if len(report.Issues) != 0 {
+				t.Errorf("%s: expected no issues, got %+v", tt.name, report.Issues)
+			}
+			continue
+		}
+		if len(report.Issues) == 0 || report.Issues[0].Check != tt.check {
+			t.Errorf("%s: expected a %s issue, got %+v", tt.name, tt.check, report.Issues)
+		}
+	}
+}
+
+func TestVerifyEventRef(t *testing.T) {
+	withTestStores(t)
+	putTestEvent(t, "e1", constants.CreateTopicEvent, integrityTopic("e1"))
+	event := &entities.Event{ID: "e2"}
+	ref := func(model entities.EntityModel, id string) entities.EventPath {
+		return entities.EventPath{EntityPath: entities.EntityPath{Model: model, ID: id}}
+	}
+
+	tests := []struct {
+		name  string
+		ref   entities.EventPath
+		check IntegrityCheck
+	}{
+		{"no link", entities.EventPath{}, ""},
+		{"stored event of the model", ref(entities.TopicModel, "e1"), ""},
+		{"stored event without a model", ref("", "e1"), ""},
+		{"stored event of another model", ref(entities.SubnetModel, "e1"), ModelCheck},
+		{"missing event", ref(entities.TopicModel, "e9"), DanglingCheck},
+	}
+	for _, tt := range tests {
+		report := &IntegrityReport{}
+		verifyEventRef(event, tt.ref, nil, false, report)
+		if tt.check == "" {
+			if len(report.Issues) != 0 {
+				t.Errorf("%s: expected no issues, got %+v", tt.name, report.Issues)
+			}
+			continue
+		}
+		if len(report.Issues) != 1 || report.Issues[0].Check != tt.check || report.Issues[0].Refetched {
+			t.Errorf("%s: expected a %s issue, got %+v", tt.name, tt.check, report.Issues)
+		}
+	}
+}
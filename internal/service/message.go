@@ -19,7 +19,7 @@ import (
 /*
 Validate an agent authorization
 */
-func ValidateMessageData(payload *entities.ClientPayload, topic *entities.Topic) (currentSubscription *models.SubscriptionState, err error) {
+func ValidateMessageData(payload *entities.ClientPayload, topic *entities.Topic, txn *datastore.Txn) (currentSubscription *models.SubscriptionState, err error) {
 	defer utils.TrackExecutionTime(time.Now(), "ValidateMessageData::")
 
 	// check fields of message
@@ -44,6 +44,9 @@ func ValidateMessageData(payload *entities.ClientPayload, topic *entities.Topic)
 	if payload.Account != message.Sender {
 		return nil,  apperror.BadRequest("Invalid message signer")
 	}
+	if err = ValidateAgentTopicScope(payload, topic.ID, txn); err != nil {
+		return nil, err
+	}
 
 	
 	subsribers := []entities.DIDString{entities.DIDString(payload.Account.ToString()), entities.DIDString(payload.Agent)}
@@ -53,7 +56,7 @@ func ValidateMessageData(payload *entities.ClientPayload, topic *entities.Topic)
 		Subnet: payload.Subnet,
 		Topic: message.Topic,
 		Subscriber: subsribers[0],
-	}, dsquery.DefaultQueryLimit, nil)
+	}, dsquery.DefaultQueryLimit, txn)
 
 	if err != nil && !dsquery.IsErrorNotFound(err) {
 		return nil, err
@@ -63,7 +66,7 @@ func ValidateMessageData(payload *entities.ClientPayload, topic *entities.Topic)
 			Subnet: payload.Subnet,
 			Topic: message.Topic,
 			Subscriber: subsribers[1],
-		}, dsquery.DefaultQueryLimit, nil)
+		}, dsquery.DefaultQueryLimit, txn)
 		
 		if _err != nil {
 			if dsquery.IsErrorNotFound(err) {
@@ -140,12 +143,7 @@ func HandleNewPubSubMessageEvent(event *entities.Event, ctx *context.Context) (e
 	if !ok {
 		panic("Unable to load config from context")
 	}
-	dataStates := dsquery.DataStates{
-		Events: make(map[string]entities.Event),
-		CurrentStates: make(map[entities.EntityPath]interface{}),
-		HistoricState: make(map[entities.EntityPath][]byte),
-		Config: cfg,
-	}
+	dataStates, batch := eventDataStates(ctx, cfg)
 	dataStates.AddEvent(*event)
 
 	validator := utils.IfThenElse(event.IsLocal(cfg),  "",  string(event.Validator))
@@ -174,6 +172,10 @@ func HandleNewPubSubMessageEvent(event *entities.Event, ctx *context.Context) (e
 			if err != nil {
 					return
 			}
+			if batch != nil {
+				batch.onCommit(func() { OnFinishProcessingEvent(ctx, event, &data) })
+				return
+			}
 			stateUpdateError := dataStates.Commit(nil, nil, nil)
 			if err != nil {
 				logger.Error("HandleNewPubSubMessageEvent: ", err)
@@ -211,7 +213,7 @@ func HandleNewPubSubMessageEvent(event *entities.Event, ctx *context.Context) (e
 	logger.Debugf("Processing 1...: %s", event.ID)
 	
 	
-	previousEventUptoDate,  authEventUpToDate, _, _, err := ProcessEvent(event,  eventData, true, saveMessageEvent, nil, nil, ctx, &dataStates)
+	previousEventUptoDate,  authEventUpToDate, _, _, err := ProcessEvent(event,  eventData, true, saveMessageEvent, nil, nil, ctx, dataStates)
 	if err != nil {
 		logger.Errorf("Processing Error...: %v", err)
 		return err
@@ -224,7 +226,7 @@ func HandleNewPubSubMessageEvent(event *entities.Event, ctx *context.Context) (e
 	if previousEventUptoDate  && authEventUpToDate {
 		// _topic, err := dsquery.GetTopicById( data.Topic)
 		_topic := &entities.Topic{}
-		_, err := SyncTypedStateById(data.Topic, _topic, cfg, validator)
+		_, err := SyncTypedStateByIdTxn(data.Topic, _topic, cfg, validator, batch.Txn(entities.TopicModel))
 
 		if (err != nil || _topic == nil  ) {
 			return err
@@ -243,7 +245,7 @@ func HandleNewPubSubMessageEvent(event *entities.Event, ctx *context.Context) (e
 		// 	// return err
 		// }
 		if event.Validator != entities.PublicKeyString(cfg.PublicKeyEDDHex) {
-		 	_, err = ValidateMessageData(&event.Payload, _topic)
+		 	_, err = ValidateMessageData(&event.Payload, _topic, batch.Txn(entities.SubscriptionModel))
 		}
 		if err != nil {
 			// update error and mark as synced
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+)
+
+/*
+AllowLegacyNonce accepts payloads with nonce 0, the value of clients that do not send nonces yet.
+Such payloads are not replay protected, a captured one can be submitted again to any validator.
+Nodes turn it off with require_payload_nonce. Nonces will be required by default in the next minor release,
+and the switch removed with nonce 0 in the release after it
+*/
+var AllowLegacyNonce = true
+
+/*
+reservedNonces holds the highest nonces of payloads accepted by this node that may not have been committed yet,
+so that a payload can not be replayed while its first submission is still being processed.
+Reservations are kept by the same keys as the high-water marks, and released once the event is committed or rejected
+*/
+var (
+	nonceMutex     sync.Mutex
+	reservedNonces = map[string]uint64{}
+)
+
+// nonceKeys are the high-water marks a payload of the agent for the account raises
+func nonceKeys(account entities.DIDString, agent entities.DeviceString) []string {
+	return []string{entities.AgentNonceKey(account, agent), entities.DeviceNonceKey(agent), entities.AccountNonceKey(account)}
+}
+
+// highestNonce is the higher of a saved high-water mark and the nonce reserved under its key
+func highestNonce(key string, saved uint64, err error) (uint64, error) {
+	if err != nil {
+		return 0, err
+	}
+	if reserved := reservedNonces[key]; reserved > saved {
+		return reserved, nil
+	}
+	return saved, nil
+}
+
+func highestAgentNonce(account entities.DIDString, agent entities.DeviceString) (uint64, error) {
+	nonce, err := dsquery.GetAgentNonce(account, agent, nil)
+	return highestNonce(entities.AgentNonceKey(account, agent), nonce, err)
+}
+
+// NextAgentNonce returns the nonce the agents next payload for the account must carry
+func NextAgentNonce(account entities.DIDString, agent entities.DeviceString) (uint64, error) {
+	nonceMutex.Lock()
+	defer nonceMutex.Unlock()
+	nonce, err := highestAgentNonce(account, agent)
+	if err != nil {
+		return 0, err
+	}
+	return nonce + 1, nil
+}
+
+// NextDeviceNonce returns the nonce above every payload of the agent, for SDKs that keep one counter per agent
+func NextDeviceNonce(agent entities.DeviceString) (uint64, error) {
+	nonceMutex.Lock()
+	defer nonceMutex.Unlock()
+	saved, err := dsquery.GetDeviceNonce(agent, nil)
+	nonce, err := highestNonce(entities.DeviceNonceKey(agent), saved, err)
+	if err != nil {
+		return 0, err
+	}
+	return nonce + 1, nil
+}
+
+// NextAccountNonce returns the nonce above every payload of the accounts agents, for SDKs that keep one counter per account
+func NextAccountNonce(account entities.DIDString) (uint64, error) {
+	nonceMutex.Lock()
+	defer nonceMutex.Unlock()
+	saved, err := dsquery.GetAccountNonce(account, nil)
+	nonce, err := highestNonce(entities.AccountNonceKey(account), saved, err)
+	if err != nil {
+		return 0, err
+	}
+	return nonce + 1, nil
+}
+
+// ValidatePayloadNonce rejects payloads whose nonce is not above the agents high-water mark for the account
+func ValidatePayloadNonce(payload *entities.ClientPayload) error {
+	nonceMutex.Lock()
+	defer nonceMutex.Unlock()
+	return validatePayloadNonce(payload)
+}
+
+/*
+The agent signs the payload for the account, so its high-water mark for the account is the one a replay must pass.
+The marks of the agent and of the account only help SDKs pick the next nonce
+*/
+func validatePayloadNonce(payload *entities.ClientPayload) error {
+	if payload.Agent == "" {
+		return nil
+	}
+	if payload.Nonce == 0 {
+		if !AllowLegacyNonce {
+			return apperror.BadRequest("Payload nonce is required")
+		}
+		logger.Warnf("Deprecated: payload from agent %s has no nonce, nonces will be required in a future release", payload.Agent)
+		return nil
+	}
+	highest, err := highestAgentNonce(payload.Account, payload.Agent)
+	if err != nil {
+		return err
+	}
+	if payload.Nonce <= highest {
+		return apperror.BadRequest(fmt.Sprintf("Stale or duplicate nonce %d, next nonce is %d", payload.Nonce, highest+1))
+	}
+	return nil
+}
+
+// ReservePayloadNonce validates the payloads nonce and holds it until the event is committed
+func ReservePayloadNonce(payload *entities.ClientPayload) error {
+	nonceMutex.Lock()
+	defer nonceMutex.Unlock()
+	if err := validatePayloadNonce(payload); err != nil {
+		return err
+	}
+	if payload.Agent == "" || payload.Nonce == 0 {
+		return nil
+	}
+	for _, key := range nonceKeys(payload.Account, payload.Agent) {
+		if payload.Nonce > reservedNonces[key] {
+			reservedNonces[key] = payload.Nonce
+		}
+	}
+	return nil
+}
+
+// ReleasePayloadNonce drops the reservations of a payload that was committed or not applied, so an unused nonce can be used again
+func ReleasePayloadNonce(payload *entities.ClientPayload) {
+	nonceMutex.Lock()
+	defer nonceMutex.Unlock()
+	for _, key := range nonceKeys(payload.Account, payload.Agent) {
+		if reservedNonces[key] == payload.Nonce {
+			delete(reservedNonces, key)
+		}
+	}
+}
+
+/*
+ValidateEventNonce rejects an event whose nonce another event of the agent already used.
+Events of an agent can reach validators in any order, so rather than the high-water mark every validator checks the nonce is unused,
+and when two events use it the earlier one by CompareEventOrder keeps it on every validator.
+It returns the event that lost the nonce to this one, if any
+*/
+func ValidateEventNonce(event *entities.Event, txn *datastore.Txn) (*entities.Event, error) {
+	if event.Payload.Agent == "" || event.Payload.Nonce == 0 {
+		return nil, nil
+	}
+	var read datastore.Read
+	if txn != nil {
+		read = *txn
+	}
+	used, err := dsquery.GetAgentNonceUse(event.Payload.Account, event.Payload.Agent, event.Payload.Nonce, read)
+	if err != nil || used == nil || used.ID == event.ID {
+		return nil, err
+	}
+	usedBy, err := dsquery.GetEventFromPath(used)
+	if err != nil || usedBy == nil {
+		if dsquery.IsErrorNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if order, _ := entities.CompareEventOrder(usedBy.Order(), event.Order()); order < 0 {
+		return nil, apperror.BadRequest(fmt.Sprintf("Nonce %d already used by event %s", event.Payload.Nonce, used.ID))
+	}
+	return usedBy, nil
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/common/utils"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+)
+
+const (
+	nonceAccount   entities.DIDString    = "did:0x00000000000000000000000000000000000000a1"
+	nonceAgent     entities.DeviceString = "did:0x1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d"
+	nonceRecipient entities.DIDString    = "did:0x00000000000000000000000000000000000000b0"
+	nonceValidator                       = "02ebec9d95769bb3d71712f0bf1e7e88b199fc945f67f908bbab81e9b7cb1092d8"
+	nonceTopicId                         = "6f1c9a8e-3b2d-4c5e-9f10-2a3b4c5d6e7f"
+)
+
+// nonceEvent is an event of the agent, its signature gives it its id
+func nonceEvent(signature string, eventType constants.EventType, nonce uint64, block uint64, previous *entities.Event, data any) *entities.Event {
+	event := &entities.Event{
+		Signature: signature, Validator: nonceValidator, EventType: uint16(eventType), BlockNumber: block, Timestamp: block * 10,
+		Payload: entities.ClientPayload{Account: nonceAccount, Agent: nonceAgent, Nonce: nonce, EventType: uint16(eventType), Data: data},
+	}
+	event.ID, _ = event.GetId()
+	if previous != nil {
+		event.PreviousEvent = *previous.GetPath()
+	}
+	return event
+}
+
+/*
+applyNonceEvent handles event the way the event handlers do: the nonce is checked, an event more recent than the
+current state replaces it and an older one is kept as a historic state
+*/
+func applyNonceEvent(t *testing.T, event *entities.Event) {
+	dataStates := dsquery.NewDataStates(&configs.MainConfiguration{DataDir: filepath.Join(t.TempDir(), "none")})
+	dataStates.AddEvent(*event)
+	lost, err := ValidateEventNonce(event, nil)
+	if err != nil {
+		dataStates.AddEvent(entities.Event{ID: event.ID, Error: err.Error(), IsValid: utils.FalsePtr(), Synced: utils.TruePtr()})
+		if err := dataStates.Commit(nil, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if lost != nil {
+		dataStates.AddLostNonce(*lost)
+	}
+	dataStates.AddEvent(entities.Event{ID: event.ID, IsValid: utils.TruePtr(), Synced: utils.TruePtr()})
+	stateTxn, err := stores.StateStore.NewTransaction(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stateTxn.Discard(context.Background())
+	switch data := event.Payload.Data.(type) {
+	case entities.Topic:
+		data.Event = *event.GetPath()
+		if current, err := dsquery.GetTopicById(data.ID); err == nil {
+			currentEvent, err := dsquery.GetEventFromPath(&current.Event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cmp, _ := entities.CompareEventOrder(currentEvent.Order(), event.Order()); cmp > 0 {
+				dataStates.AddHistoricState(entities.TopicModel, event.ID, data.MsgPack())
+				break
+			}
+		}
+		dataStates.AddCurrentState(entities.TopicModel, data.ID, data)
+	case entities.TokenTransaction:
+		data.Event = *event.GetPath()
+		data.BlockNumber = event.BlockNumber
+		data.Account = event.Payload.Account
+		data.Agent = event.Payload.Agent
+		data.Nonce = event.Payload.Nonce
+		if _, err := dsquery.InsertTokenTransaction(&data, stateTxn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dataStates.Commit(&stateTxn, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateTxn.Commit(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type nonceState struct {
+	topicRef string
+	balances [2]string
+	valid    map[string]bool
+}
+
+// runNonceValidator applies the setup events, then the conflicting ones in the order one validator received them
+func runNonceValidator(t *testing.T, setup []*entities.Event, conflicting []*entities.Event) nonceState {
+	withTestStores(t)
+	txn, err := stores.StateStore.NewTransaction(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mint := &entities.TokenTransaction{Wallet: "w1", To: nonceAccount, Amount: "100", Account: nonceRecipient, Nonce: 1, Event: entities.EventPath{EntityPath: entities.EntityPath{Model: entities.WalletModel, ID: "mint"}}}
+	if _, err := dsquery.InsertTokenTransaction(mint, txn); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Commit(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range append(append([]*entities.Event{}, setup...), conflicting...) {
+		applyNonceEvent(t, event)
+	}
+	state := nonceState{valid: map[string]bool{}}
+	topic, err := dsquery.GetTopicById(nonceTopicId)
+	if err == nil {
+		state.topicRef = topic.Ref
+	} else if !dsquery.IsErrorNotFound(err) {
+		t.Fatal(err)
+	}
+	for i, account := range []entities.DIDString{nonceAccount, nonceRecipient} {
+		balance, err := dsquery.GetWalletBalance("w1", account, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state.balances[i] = balance.Balance.String()
+	}
+	for _, event := range conflicting {
+		saved, err := dsquery.GetEventGeneric(event.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state.valid[event.ID] = saved.IsValid != nil && *saved.IsValid
+	}
+	return state
+}
+
+// two events of an agent with the same nonce leave every validator in the same state, whichever it received first
+func TestConflictingNonceStateIsIndependentOfArrivalOrder(t *testing.T) {
+	create := nonceEvent("0123456789abcdef0123456789abcdef0123456789abcdef", constants.CreateTopicEvent, 0, 1, nil, entities.Topic{ID: nonceTopicId, Ref: "created"})
+	topicUpdate := func(signature string, block uint64, ref string) *entities.Event {
+		return nonceEvent(signature, constants.UpdateTopicEvent, 1, block, create, entities.Topic{ID: nonceTopicId, Ref: ref})
+	}
+	transfer := func(signature string, block uint64) *entities.Event {
+		return nonceEvent(signature, constants.TransferTokenEvent, 1, block, nil, entities.TokenTransaction{Wallet: "w1", From: nonceAccount, To: nonceRecipient, Amount: "30", Timestamp: block * 10})
+	}
+	tests := []struct {
+		name   string
+		winner *entities.Event
+		loser  *entities.Event
+		want   nonceState
+	}{
+		{
+			"updates of one topic",
+			topicUpdate("1111111111111111111111111111111111111111111111111", 2, "winner"),
+			topicUpdate("2222222222222222222222222222222222222222222222222", 3, "loser"),
+			nonceState{topicRef: "winner", balances: [2]string{"100", "0"}},
+		},
+		{
+			"transfer losing to a topic update",
+			topicUpdate("3333333333333333333333333333333333333333333333333", 2, "winner"),
+			transfer("4444444444444444444444444444444444444444444444444", 3),
+			nonceState{topicRef: "winner", balances: [2]string{"100", "0"}},
+		},
+		{
+			"topic update losing to a transfer",
+			transfer("5555555555555555555555555555555555555555555555555", 2),
+			topicUpdate("6666666666666666666666666666666666666666666666666", 3, "loser"),
+			nonceState{topicRef: "created", balances: [2]string{"70", "30"}},
+		},
+	}
+	for _, tt := range tests {
+		for _, order := range [][]*entities.Event{{tt.winner, tt.loser}, {tt.loser, tt.winner}} {
+			state := runNonceValidator(t, []*entities.Event{create}, order)
+			if state.topicRef != tt.want.topicRef || state.balances != tt.want.balances {
+				t.Errorf("%s, %s first: topic %q and balances %v, want %q and %v", tt.name, order[0].ID, state.topicRef, state.balances, tt.want.topicRef, tt.want.balances)
+			}
+			if !state.valid[tt.winner.ID] || state.valid[tt.loser.ID] {
+				t.Errorf("%s, %s first: expected only the earlier event to be valid, got %v", tt.name, order[0].ID, state.valid)
+			}
+		}
+	}
+}
+
+func withLegacyNonce(t *testing.T, allow bool) {
+	previous := AllowLegacyNonce
+	AllowLegacyNonce = allow
+	t.Cleanup(func() { AllowLegacyNonce = previous })
+}
+
+func TestValidatePayloadNonce(t *testing.T) {
+	withTestStores(t)
+	payload := &entities.ClientPayload{Account: nonceAccount, Agent: nonceAgent, Nonce: 2}
+	if err := ReservePayloadNonce(payload); err != nil {
+		t.Fatal(err)
+	}
+	defer ReleasePayloadNonce(payload)
+	for _, nonce := range []uint64{1, 2} {
+		if err := ValidatePayloadNonce(&entities.ClientPayload{Account: nonceAccount, Agent: nonceAgent, Nonce: nonce}); err == nil {
+			t.Errorf("expected nonce %d to be rejected after nonce 2", nonce)
+		}
+	}
+	if err := ValidatePayloadNonce(&entities.ClientPayload{Account: nonceAccount, Agent: nonceAgent, Nonce: 3}); err != nil {
+		t.Errorf("expected nonce 3 to be accepted, got %v", err)
+	}
+	// the mark is kept for the agent acting for the account, another account starts over
+	if err := ValidatePayloadNonce(&entities.ClientPayload{Account: nonceRecipient, Agent: nonceAgent, Nonce: 1}); err != nil {
+		t.Errorf("expected nonce 1 to be accepted for another account, got %v", err)
+	}
+}
+
+func TestValidatePayloadLegacyNonce(t *testing.T) {
+	withTestStores(t)
+	payload := &entities.ClientPayload{Account: nonceAccount, Agent: nonceAgent}
+	withLegacyNonce(t, true)
+	if err := ValidatePayloadNonce(payload); err != nil {
+		t.Fatalf("expected nonce 0 to be accepted while legacy nonces are allowed, got %v", err)
+	}
+	withLegacyNonce(t, false)
+	if err := ValidatePayloadNonce(payload); err == nil {
+		t.Fatal("expected nonce 0 to be rejected once nonces are required")
+	}
+	if err := ValidatePayloadNonce(&entities.ClientPayload{Account: nonceAccount}); err != nil {
+		t.Fatalf("expected payloads without an agent to be left to their own signature checks, got %v", err)
+	}
+}
+
+func TestNextNonces(t *testing.T) {
+	withTestStores(t)
+	txn, err := stores.StateStore.NewTransaction(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dsquery.UpdateAgentNonce(nonceAccount, nonceAgent, 5, txn); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Commit(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// a reserved payload of the agent for another account raises the agent mark only
+	reserved := &entities.ClientPayload{Account: nonceRecipient, Agent: nonceAgent, Nonce: 8}
+	if err := ReservePayloadNonce(reserved); err != nil {
+		t.Fatal(err)
+	}
+	expect := func(name string, next func() (uint64, error), want uint64) {
+		got, err := next()
+		if err != nil || got != want {
+			t.Errorf("%s: got %d %v, want %d", name, got, err, want)
+		}
+	}
+	expect("agent for the account", func() (uint64, error) { return NextAgentNonce(nonceAccount, nonceAgent) }, 6)
+	expect("agent", func() (uint64, error) { return NextDeviceNonce(nonceAgent) }, 9)
+	expect("account", func() (uint64, error) { return NextAccountNonce(nonceAccount) }, 6)
+	ReleasePayloadNonce(reserved)
+	expect("agent once released", func() (uint64, error) { return NextDeviceNonce(nonceAgent) }, 6)
+}
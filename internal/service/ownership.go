@@ -0,0 +1,129 @@
+package service
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+)
+
+func isSameAccount(a entities.DIDString, b entities.DIDString) bool {
+	addr := entities.AddressFromString(string(a)).Addr
+	return addr != "" && strings.EqualFold(addr, entities.AddressFromString(string(b)).Addr)
+}
+
+func validateOwnershipOffer(owner entities.DIDString, pendingOwner entities.DIDString, current entities.DIDString) error {
+	if !isSameAccount(owner, current) {
+		return apperror.Forbidden("Only the current owner can transfer ownership")
+	}
+	if entities.AddressFromString(string(pendingOwner)).Addr == "" {
+		return apperror.BadRequest("New owner is required")
+	}
+	if isSameAccount(pendingOwner, current) {
+		return apperror.BadRequest("Account already owns this entity")
+	}
+	return nil
+}
+
+func validateOwnershipAcceptance(newOwner entities.DIDString, pendingOwner entities.DIDString, current entities.DIDString) error {
+	if current == "" {
+		return apperror.BadRequest("No pending ownership transfer")
+	}
+	if !isSameAccount(newOwner, current) {
+		return apperror.Forbidden("Only the offered owner can accept ownership")
+	}
+	if pendingOwner != "" {
+		return apperror.BadRequest("Pending owner must be cleared on acceptance")
+	}
+	return nil
+}
+
+/*
+ValidateSubnetOwnershipTransfer checks the two step ownership transfer of a subnet.
+The current owner offers the subnet to a pending owner who then accepts it.
+Acceptance cannot change anything but the owner
+*/
+func ValidateSubnetOwnershipTransfer(eventType uint16, subnet *entities.Subnet, current *entities.Subnet) error {
+	switch constants.EventType(eventType) {
+	case constants.TransferSubnetOwnershipEvent, constants.AcceptSubnetOwnershipEvent:
+		if current == nil {
+			return apperror.NotFound("Subnet not found")
+		}
+	default:
+		if subnet.PendingOwner != "" && (current == nil || !isSameAccount(subnet.PendingOwner, current.PendingOwner)) {
+			return apperror.BadRequest("Ownership can only be offered with a transfer event")
+		}
+		return nil
+	}
+	if constants.EventType(eventType) == constants.TransferSubnetOwnershipEvent {
+		return validateOwnershipOffer(subnet.Account, subnet.PendingOwner, current.Account)
+	}
+	if err := validateOwnershipAcceptance(subnet.Account, subnet.PendingOwner, current.PendingOwner); err != nil {
+		return err
+	}
+	expected := *current
+	expected.Account = subnet.Account
+	expected.PendingOwner = ""
+	expected.Timestamp = subnet.Timestamp
+	a, err := expected.EncodeBytes()
+	if err != nil {
+		return err
+	}
+	b, err := subnet.EncodeBytes()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(a, b) || !slices.Equal(expected.Categories, subnet.Categories) {
+		return apperror.BadRequest("Accepting ownership cannot change the subnet")
+	}
+	return nil
+}
+
+/*
+TopicOwner returns the account that owns a topic after the event.
+Only accepting a transfer moves ownership, updates by any other member keep the current owner
+*/
+func TopicOwner(eventType uint16, account entities.DIDString, current *entities.Topic) entities.DIDString {
+	if current == nil || current.ID == "" || constants.EventType(eventType) == constants.AcceptTopicOwnershipEvent {
+		return account
+	}
+	return current.Account
+}
+
+// ValidateTopicOwnershipTransfer is the topic equivalent. Topic owners are the payload account
+func ValidateTopicOwnershipTransfer(payload *entities.ClientPayload, topic *entities.Topic, current *entities.Topic) error {
+	switch constants.EventType(payload.EventType) {
+	case constants.TransferTopicOwnershipEvent, constants.AcceptTopicOwnershipEvent:
+		if current == nil || current.ID == "" {
+			return apperror.NotFound("Topic not found")
+		}
+	default:
+		if topic.PendingOwner != "" && (current == nil || !isSameAccount(topic.PendingOwner, current.PendingOwner)) {
+			return apperror.BadRequest("Ownership can only be offered with a transfer event")
+		}
+		return nil
+	}
+	if constants.EventType(payload.EventType) == constants.TransferTopicOwnershipEvent {
+		return validateOwnershipOffer(payload.Account, topic.PendingOwner, current.Account)
+	}
+	if err := validateOwnershipAcceptance(payload.Account, topic.PendingOwner, current.PendingOwner); err != nil {
+		return err
+	}
+	expected := *current
+	expected.PendingOwner = ""
+	a, err := expected.EncodeBytes()
+	if err != nil {
+		return err
+	}
+	b, err := topic.EncodeBytes()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(a, b) || expected.Subnet != topic.Subnet {
+		return apperror.BadRequest("Accepting ownership cannot change the topic")
+	}
+	return nil
+}
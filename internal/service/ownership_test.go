@@ -0,0 +1,58 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+)
+
+const (
+	topicOwner  entities.DIDString = "did:0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"
+	topicMember entities.DIDString = "did:0x70997970C51812dc3A010C7d01b50e0d17dc79C8"
+)
+
+func TestTopicOwnerOnlyMovesOnAcceptance(t *testing.T) {
+	current := &entities.Topic{ID: "t1", Account: topicOwner, PendingOwner: topicMember}
+	if owner := TopicOwner(uint16(constants.CreateTopicEvent), topicOwner, &entities.Topic{}); owner != topicOwner {
+		t.Fatalf("new topics are owned by their creator, got %s", owner)
+	}
+	if owner := TopicOwner(uint16(constants.UpdateTopicEvent), topicMember, current); owner != topicOwner {
+		t.Fatalf("a members update should not take ownership, got %s", owner)
+	}
+	if owner := TopicOwner(uint16(constants.TransferTopicOwnershipEvent), topicOwner, current); owner != topicOwner {
+		t.Fatalf("offering a topic should not move ownership, got %s", owner)
+	}
+	if owner := TopicOwner(uint16(constants.AcceptTopicOwnershipEvent), topicMember, current); owner != topicMember {
+		t.Fatalf("accepting a transfer should move ownership, got %s", owner)
+	}
+}
+
+func TestValidateTopicOwnershipTransfer(t *testing.T) {
+	current := &entities.Topic{ID: "t1", Ref: "general", Account: topicOwner}
+	offer := *current
+	offer.PendingOwner = topicMember
+
+	for _, tc := range []struct {
+		name      string
+		eventType constants.EventType
+		account   entities.DIDString
+		topic     entities.Topic
+		current   entities.Topic
+		valid     bool
+	}{
+		{"owner offers", constants.TransferTopicOwnershipEvent, topicOwner, offer, *current, true},
+		{"member offers", constants.TransferTopicOwnershipEvent, topicMember, offer, *current, false},
+		{"update sets pending owner", constants.UpdateTopicEvent, topicOwner, offer, *current, false},
+		{"offered account accepts", constants.AcceptTopicOwnershipEvent, topicMember, *current, offer, true},
+		{"other account accepts", constants.AcceptTopicOwnershipEvent, topicOwner, *current, offer, false},
+		{"accept without offer", constants.AcceptTopicOwnershipEvent, topicMember, *current, *current, false},
+	} {
+		payload := entities.ClientPayload{EventType: uint16(tc.eventType), Account: tc.account}
+		topic, state := tc.topic, tc.current
+		err := ValidateTopicOwnershipTransfer(&payload, &topic, &state)
+		if (err == nil) != tc.valid {
+			t.Errorf("%s: expected valid=%v, got %v", tc.name, tc.valid, err)
+		}
+	}
+}
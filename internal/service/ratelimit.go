@@ -0,0 +1,282 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+)
+
+type RateLimitScope string
+
+const (
+	AgentRateLimitScope   RateLimitScope = "agt"
+	AccountRateLimitScope RateLimitScope = "acct"
+	SubnetRateLimitScope  RateLimitScope = "snet"
+)
+
+/*
+events a validator receives from a peer may arrive in bursts the peer never saw,
+so peer buckets hold this many times the subnets burst before they throttle
+*/
+const PeerRateLimitBurstFactor = 2
+
+// buckets that stayed full this long are dropped, a new bucket starts full so nothing is lost
+const RateLimitBucketIdleTime = 10 * time.Minute
+
+type tokenBucket struct {
+	mu        sync.Mutex
+	tokens    float64
+	updated   time.Time
+	allowed   uint64
+	throttled uint64
+	// the limit of the last refill, used to tell when an idle bucket is full again
+	rate  float64
+	burst float64
+	// set once the bucket is dropped from its map, holders of the bucket look it up again
+	evicted bool
+}
+
+type RateLimitStat struct {
+	Scope     RateLimitScope `json:"scope"`
+	Key       string         `json:"key"`
+	Tokens    float64        `json:"tokens"`
+	Allowed   uint64         `json:"allowed"`
+	Throttled uint64         `json:"throttled"`
+}
+
+/*
+tokenBuckets holds the buckets of payloads sent to this validator, or of the events one peer validator sent.
+Buckets are keyed by "<subnet>/<scope>/<key>"
+*/
+type tokenBuckets struct {
+	buckets     sync.Map
+	burstFactor float64
+	mu          sync.Mutex
+	lastEvicted time.Time
+}
+
+var rateLimitBuckets = &tokenBuckets{burstFactor: 1}
+
+// peer buckets are kept per validator, so one validator skipping the limits does not throttle the others
+var peerRateLimitBuckets sync.Map
+
+func getPeerTokenBuckets(validator entities.PublicKeyString) *tokenBuckets {
+	b, _ := peerRateLimitBuckets.LoadOrStore(validator, &tokenBuckets{burstFactor: PeerRateLimitBurstFactor})
+	return b.(*tokenBuckets)
+}
+
+func (t *tokenBuckets) burst(limit *entities.RateLimit) float64 {
+	if limit.Burst == 0 {
+		return float64(limit.Rate) * t.burstFactor
+	}
+	return float64(limit.Burst) * t.burstFactor
+}
+
+func (t *tokenBuckets) refill(b *tokenBucket, limit *entities.RateLimit, now time.Time) {
+	burst := t.burst(limit)
+	if b.updated.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens += now.Sub(b.updated).Minutes() * float64(limit.Rate)
+	}
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.updated = now
+	b.rate, b.burst = float64(limit.Rate), burst
+}
+
+// lock returns the locked bucket of key
+func (t *tokenBuckets) lock(subnet string, scope RateLimitScope, key string) *tokenBucket {
+	for {
+		v, _ := t.buckets.LoadOrStore(fmt.Sprintf("%s/%s/%s", subnet, scope, key), &tokenBucket{})
+		b := v.(*tokenBucket)
+		b.mu.Lock()
+		if !b.evicted {
+			return b
+		}
+		b.mu.Unlock()
+	}
+}
+
+/*
+evictIdle drops the buckets that have not been used for RateLimitBucketIdleTime and have refilled since.
+It scans the buckets at most once per RateLimitBucketIdleTime
+*/
+func (t *tokenBuckets) evictIdle(now time.Time) {
+	t.mu.Lock()
+	if now.Sub(t.lastEvicted) < RateLimitBucketIdleTime {
+		t.mu.Unlock()
+		return
+	}
+	t.lastEvicted = now
+	t.mu.Unlock()
+	t.buckets.Range(func(k, v any) bool {
+		b := v.(*tokenBucket)
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		idle := now.Sub(b.updated)
+		if idle < RateLimitBucketIdleTime || b.tokens+idle.Minutes()*b.rate < b.burst {
+			return true
+		}
+		b.evicted = true
+		t.buckets.Delete(k)
+		return true
+	})
+}
+
+func scopeLimit(limits *entities.SubnetRateLimits, scope RateLimitScope) *entities.RateLimit {
+	if limits == nil {
+		return nil
+	}
+	switch scope {
+	case SubnetRateLimitScope:
+		return limits.Subnet
+	case AccountRateLimitScope:
+		return limits.Account
+	case AgentRateLimitScope:
+		return limits.Agent
+	}
+	return nil
+}
+
+// subnet events are not rate limited, so that a subnet can always change its limits
+func isRateLimited(payload *entities.ClientPayload) bool {
+	return payload.Subnet != "" && entities.GetModelTypeFromEventType(constants.EventType(payload.EventType)) != entities.SubnetModel
+}
+
+/*
+Take one token from the agent, account and subnet buckets of the payloads subnet.
+Nothing is taken unless every bucket has a token available
+*/
+func CheckRateLimit(payload *entities.ClientPayload) error {
+	return rateLimitBuckets.check(payload, true)
+}
+
+// PeekRateLimit reports whether CheckRateLimit would throttle the payload without taking any tokens
+func PeekRateLimit(payload *entities.ClientPayload) error {
+	return rateLimitBuckets.check(payload, false)
+}
+
+/*
+CheckPeerRateLimit takes the tokens of an event a peer validator sent.
+The validator that accepted the event took them from its own buckets already, so an honest validator
+never exceeds these buckets, which hold PeerRateLimitBurstFactor times the burst. Events over them
+come from a validator that skips the limits
+*/
+func CheckPeerRateLimit(event *entities.Event) error {
+	return getPeerTokenBuckets(event.Validator).check(&event.Payload, true)
+}
+
+func (t *tokenBuckets) check(payload *entities.ClientPayload, take bool) error {
+	if !isRateLimited(payload) {
+		return nil
+	}
+	subnet, err := dsquery.GetSubnetStateById(payload.Subnet)
+	if err != nil || subnet.RateLimits == nil {
+		return nil
+	}
+	type check struct {
+		scope  RateLimitScope
+		key    string
+		limit  *entities.RateLimit
+		bucket *tokenBucket
+	}
+	checks := []check{
+		{scope: SubnetRateLimitScope, key: payload.Subnet, limit: subnet.RateLimits.Subnet},
+		{scope: AccountRateLimitScope, key: string(payload.Account), limit: subnet.RateLimits.Account},
+		{scope: AgentRateLimitScope, key: string(payload.Agent), limit: subnet.RateLimits.Agent},
+	}
+	now := time.Now()
+	t.evictIdle(now)
+	var exceeded *check
+	// every bucket stays locked until the tokens are taken, so two payloads can not both take the last token
+	for i := range checks {
+		c := &checks[i]
+		if c.limit == nil || c.limit.Rate == 0 || c.key == "" {
+			continue
+		}
+		c.bucket = t.lock(payload.Subnet, c.scope, c.key)
+		t.refill(c.bucket, c.limit, now)
+		if c.bucket.tokens < 1 && exceeded == nil {
+			exceeded = c
+		}
+	}
+	for _, c := range checks {
+		if c.bucket == nil {
+			continue
+		}
+		if take {
+			if exceeded != nil {
+				c.bucket.throttled++
+			} else {
+				c.bucket.tokens--
+				c.bucket.allowed++
+			}
+		}
+		c.bucket.mu.Unlock()
+	}
+	if exceeded != nil {
+		return apperror.TooManyRequests(fmt.Sprintf("Rate limit exceeded for %s %s", exceeded.scope, exceeded.key))
+	}
+	return nil
+}
+
+// RefundRateLimit gives back the tokens CheckRateLimit took for a payload that was not applied
+func RefundRateLimit(payload *entities.ClientPayload) {
+	if !isRateLimited(payload) {
+		return
+	}
+	subnet, err := dsquery.GetSubnetStateById(payload.Subnet)
+	if err != nil || subnet.RateLimits == nil {
+		return
+	}
+	keys := map[RateLimitScope]string{
+		SubnetRateLimitScope:  payload.Subnet,
+		AccountRateLimitScope: string(payload.Account),
+		AgentRateLimitScope:   string(payload.Agent),
+	}
+	for scope, key := range keys {
+		limit := scopeLimit(subnet.RateLimits, scope)
+		if limit == nil || limit.Rate == 0 || key == "" {
+			continue
+		}
+		b := rateLimitBuckets.lock(payload.Subnet, scope, key)
+		rateLimitBuckets.refill(b, limit, time.Now())
+		b.tokens = min(b.tokens+1, rateLimitBuckets.burst(limit))
+		if b.allowed > 0 {
+			b.allowed--
+		}
+		b.mu.Unlock()
+	}
+}
+
+func GetRateLimitStats(subnet string) []RateLimitStat {
+	stats := []RateLimitStat{}
+	prefix := subnet + "/"
+	rateLimitBuckets.buckets.Range(func(k, v any) bool {
+		key := k.(string)
+		if !strings.HasPrefix(key, prefix) {
+			return true
+		}
+		parts := strings.SplitN(strings.TrimPrefix(key, prefix), "/", 2)
+		b := v.(*tokenBucket)
+		b.mu.Lock()
+		stats = append(stats, RateLimitStat{
+			Scope:     RateLimitScope(parts[0]),
+			Key:       parts[1],
+			Tokens:    b.tokens,
+			Allowed:   b.allowed,
+			Throttled: b.throttled,
+		})
+		b.mu.Unlock()
+		return true
+	})
+	return stats
+}
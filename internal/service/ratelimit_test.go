@@ -0,0 +1,90 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+)
+
+func withRateLimitedSubnet(t *testing.T, id string) {
+	withTestStores(t)
+	subnet := entities.Subnet{ID: id, RateLimits: &entities.SubnetRateLimits{Agent: &entities.RateLimit{Rate: 1, Burst: 2}}}
+	putTestState(t, entities.SubnetModel, id, "ev-"+id, subnet.MsgPack())
+}
+
+func rateLimitedPayload(subnet string, agent entities.DeviceString) *entities.ClientPayload {
+	return &entities.ClientPayload{Subnet: subnet, Agent: agent, EventType: uint16(constants.SendMessageEvent)}
+}
+
+func TestRateLimitThrottlesAndRefunds(t *testing.T) {
+	withRateLimitedSubnet(t, "rl-refund")
+	payload := rateLimitedPayload("rl-refund", "did:agent1")
+	for i := 0; i < 2; i++ {
+		if err := CheckRateLimit(payload); err != nil {
+			t.Fatalf("payload %d within the burst was throttled: %v", i, err)
+		}
+	}
+	err := CheckRateLimit(payload)
+	if code, _ := apperror.Parse(err); err == nil || code != apperror.TooManyRequestsError {
+		t.Fatalf("expected the payload over the burst to be throttled, got %v", err)
+	}
+	RefundRateLimit(payload)
+	if err := CheckRateLimit(payload); err != nil {
+		t.Fatalf("expected the refunded token to be available, got %v", err)
+	}
+	subnetEvent := &entities.ClientPayload{Subnet: "rl-refund", Agent: "did:agent1", EventType: uint16(constants.UpdateSubnetEvent)}
+	if err := CheckRateLimit(subnetEvent); err != nil {
+		t.Fatalf("subnet events are not rate limited, got %v", err)
+	}
+}
+
+func TestPeerRateLimitIsPerValidator(t *testing.T) {
+	withRateLimitedSubnet(t, "rl-peer")
+	event := func(validator entities.PublicKeyString) *entities.Event {
+		return &entities.Event{Validator: validator, Payload: *rateLimitedPayload("rl-peer", "did:agent1")}
+	}
+	// peers get twice the burst for events that arrive together
+	for i := 0; i < 2*2; i++ {
+		if err := CheckPeerRateLimit(event("v1")); err != nil {
+			t.Fatalf("peer event %d within the burst was throttled: %v", i, err)
+		}
+	}
+	if err := CheckPeerRateLimit(event("v1")); err == nil {
+		t.Fatal("expected events over the peer burst to be throttled")
+	}
+	if err := CheckPeerRateLimit(event("v2")); err != nil {
+		t.Fatalf("another validators events should not be throttled, got %v", err)
+	}
+	if err := CheckRateLimit(rateLimitedPayload("rl-peer", "did:agent1")); err != nil {
+		t.Fatalf("peer events should not take the tokens of local payloads, got %v", err)
+	}
+}
+
+func TestIdleRateLimitBucketsAreEvicted(t *testing.T) {
+	withRateLimitedSubnet(t, "rl-evict")
+	buckets := &tokenBuckets{burstFactor: 1}
+	full := rateLimitedPayload("rl-evict", "did:full")
+	empty := rateLimitedPayload("rl-evict", "did:empty")
+	buckets.check(full, true)
+	for i := 0; i < 2; i++ {
+		buckets.check(empty, true)
+	}
+	// the emptied bucket refills too slowly to be full again after the idle time
+	buckets.buckets.Range(func(k, v any) bool {
+		b := v.(*tokenBucket)
+		if k.(string) == "rl-evict/agt/did:empty" {
+			b.rate = 0.01
+		}
+		return true
+	})
+	buckets.evictIdle(time.Now().Add(RateLimitBucketIdleTime + time.Minute))
+	if _, ok := buckets.buckets.Load("rl-evict/agt/did:full"); ok {
+		t.Error("expected the idle bucket that refilled to be evicted")
+	}
+	if _, ok := buckets.buckets.Load("rl-evict/agt/did:empty"); !ok {
+		t.Error("expected the bucket that has not refilled to be kept")
+	}
+}
@@ -39,6 +39,8 @@ type LocalDataStateEvent struct {
 	Hash string
 	ID string
 	Timestamp uint64
+	Order entities.EventOrder
+	PreviousEvent entities.EventPath
 }
 
 // deprecated
@@ -104,6 +106,19 @@ func ProcessEvent(
 	// updateState := false
 	// var eventError string
 	// // hash, _ := event.GetHash()
+
+	// nonces are kept in the state store, with the agents authorizations
+	lostNonce, err := ValidateEventNonce(event, BatchTxn(ctx, entities.AuthModel))
+	if err != nil {
+		dataDataStates.AddEvent(entities.Event{ID: event.ID, Error: err.Error(), IsValid: utils.FalsePtr(), Synced: utils.TruePtr()})
+		return false, false, nil, false, err
+	}
+	if lostNonce != nil {
+		logger.Infof("ProcessEvent: event %s takes nonce %d from later event %s", event.ID, event.Payload.Nonce, lostNonce.ID)
+		lostNonce.Error = fmt.Sprintf("Nonce %d already used by event %s", event.Payload.Nonce, event.ID)
+		// the states the later event applied are rolled back, as validators that received this event first never applied them
+		dataDataStates.AddLostNonce(*lostNonce)
+	}
 	
 	if event.IsLocal(cfg) {
 		if len(event.AuthEvent.ID) > 0 {
@@ -145,7 +160,7 @@ func ProcessEvent(
 		logger.Errorf("ValidateEventError: %v", err)
 		return false, false, nil, eventIsMoreRecent, err
 	}
-	d, err := event.Payload.EncodeBytes()
+	d, err := event.Payload.SigningBytes()
 	if err != nil || len(d) == 0 {
 		logger.Debug("Invalid event payload")
 		return false, false, nil, eventIsMoreRecent, fmt.Errorf("invalid event payload")
@@ -153,7 +168,7 @@ func ProcessEvent(
 	
 	//subnet := models.SubnetState{}
 	var _subnet *entities.Subnet
-	if event.EventType != uint16(constants.CreateSubnetEvent) && event.EventType != uint16(constants.UpdateSubnetEvent) {
+	if entities.GetModelTypeFromEventType(constants.EventType(event.EventType)) != entities.SubnetModel {
 	// err = query.GetOne(models.SubnetState{Subnet: entities.Subnet{ID: data.Subnet}}, &subnet)
 	_subnet, err = dsquery.GetSubnetStateById(data.Subnet)
 	
@@ -255,6 +270,17 @@ func ProcessEvent(
 			// if len(_agentAuthState) > 0 && currentLocaltAuthState.ID == "" {
 			// 	currentLocaltAuthState = models.AuthorizationState{Authorization: *(_agentAuthState[0])}
 			// }
+			// a revocation only rejects events ordered after it, events signed before it are accepted even if they arrive later.
+			// the auth state the event references is checked below
+			if IsRevokedBefore(&currentLocaltAuthState.Authorization, event) {
+				return  false, false, nil, eventIsMoreRecent, fmt.Errorf("agent authorization revoked")
+			}
+			// revoking any grantor up the chain invalidates the agent
+			if currentLocaltAuthState.ID != "" {
+				if err := ValidateDelegation(&currentLocaltAuthState.Authorization, event); err != nil {
+					return  false, false, nil, eventIsMoreRecent, fmt.Errorf("invalid delegation chain: %v", err)
+				}
+			}
 			if currentLocaltAuthState.Priviledge != nil && *currentLocaltAuthState.Priviledge < constants.MemberPriviledge && currentLocaltAuthState.ID == event.AuthEvent.ID {
 					// authorizationIndex = i
 				return  false, false, nil, eventIsMoreRecent, fmt.Errorf("no write priviledge")
@@ -274,7 +300,7 @@ func ProcessEvent(
 		// 	// check the node that sent the event to see if it has the record
 
 		// }
-		logger.Debugf("PreviousEvent: %s", event.PreviousEvent)
+		logger.Debugf("PreviousEvent: %v", event.PreviousEvent)
 		if len(event.PreviousEvent.ID) > 0 {
 			// previousEvent, err = query.GetEventFromPath(&event.PreviousEvent)
 
@@ -405,6 +431,9 @@ func ProcessEvent(
 					return previousEventUptoDate, false, nil, eventIsMoreRecent, fmt.Errorf("authEvent not synced")
 				}
 			}
+			if authEventAuthState.IsRevoked() {
+				return false, false, nil, eventIsMoreRecent, fmt.Errorf("agent authorization revoked")
+			}
 			if authEvent != nil && currentLocaltAuthState.ID != authEventAuthState.ID {
 				if authEvent.Timestamp >= event.Timestamp {
 					return false, false, nil, eventIsMoreRecent, fmt.Errorf("invalid auth signature")
@@ -412,7 +441,7 @@ func ProcessEvent(
 				if *authEventAuthState.Priviledge < constants.MemberPriviledge {
 					return false, false, nil, eventIsMoreRecent, fmt.Errorf("invalid event auth state")
 				}
-				hash, err := authEvent.Payload.GetHash()
+				hash, err := authEvent.Payload.SignatureDataMessage(authEventAuthState.SignatureData.Type)
 				if err != nil {
 					return false, false, nil, eventIsMoreRecent, fmt.Errorf("invalid event auth state")
 				}
@@ -427,7 +456,7 @@ func ProcessEvent(
 				// 	return false, false, nil, eventIsMoreRecent, fmt.Errorf("unable to get local auth state")
 				// } 
 				// if len(localAuthState) == 0 || IsMoreRecentEvent(localAuthState[0].Event.ID, int(*localAuthState[0].Timestamp), _auth.Event.ID, int(*_auth.Timestamp), ) {
-				if currentLocaltAuthState.ID == "" || IsMoreRecentEvent(currentLocaltAuthState.Event.ID, int(*currentLocaltAuthState.Timestamp), authEventAuthState.Event.ID, int(*authEventAuthState.Timestamp), ) {
+				if currentLocaltAuthState.ID == "" || IsMoreRecentEvent(currentLocaltAuthState.Order(), authEventAuthState.Order()) {
 					// dataDataStates.CurrentStates[entities.EntityPath{Model: entities.AuthModel, Hash: authEventAuthState.ID}] = authEventAuthState
 					dataDataStates.AddCurrentState(entities.AuthModel, authEventAuthState.ID, authEventAuthState)
 					dataDataStates.AddEvent(*authEvent)
@@ -440,7 +469,7 @@ func ProcessEvent(
 					}
 					// err = dsquery.SaveHistoricState(entities.AuthModel, _auth.ID, authData)
 					dataDataStates.AddEvent(*authEvent)
-					dataDataStates.AddHistoricState(entities.AuthModel, authEventAuthState.ID, authEventAuthState.MsgPack())
+					dataDataStates.AddHistoricState(entities.AuthModel, authEvent.ID, authEventAuthState.MsgPack())
 				}
 				// if err != nil {
 				// 	return false, false, nil, eventIsMoreRecent, fmt.Errorf("unable to save auth state")
@@ -488,13 +517,17 @@ func ProcessEvent(
 				// 	logger.Debug(err)
 				// }
 				logger.Debugf("STATEEVENT %v, %+v", stateEvent.Timestamp, event.Hash)
-				if len(stateEvent.ID) > 0 {
-					eventIsMoreRecent = IsMoreRecentEvent(stateEvent.Hash, int(stateEvent.Timestamp), event.Hash, int(event.Timestamp))
+				if len(stateEvent.ID) > 0 && stateEvent.ID != event.ID {
+					eventIsMoreRecent = IsMoreRecentEvent(stateEvent.Order, event.Order())
 
 					logger.Debugf("STATEEVENT %v, %+v", stateEvent, previousEvent)
-					 // if this event is more recent, then it must referrence our local event or an event after it
-					if previousEvent != nil && eventIsMoreRecent  && stateEvent.Hash != event.PreviousEvent.ID {
-						previousEventMoreRecent := IsMoreRecentEvent(stateEvent.Hash, int(stateEvent.Timestamp), previousEvent.ID, int(previousEvent.Timestamp))
+					if stateEvent.PreviousEvent.ID == event.PreviousEvent.ID {
+						// both events update the same state. The one last in the event order wins on every validator and the other is logged
+						model := entities.GetModelTypeFromEventType(constants.EventType(event.EventType))
+						dataDataStates.AddConflict(entities.NewEventConflict(model, entityState.ID, stateEvent.Order, event.Order(), "concurrent update"))
+					} else if previousEvent != nil && eventIsMoreRecent  && stateEvent.ID != event.PreviousEvent.ID {
+						// if this event is more recent, then it must referrence our local event or an event after it
+						previousEventMoreRecent := IsMoreRecentEvent(stateEvent.Order, previousEvent.Order())
 						if !previousEventMoreRecent {
 							badEvent = fmt.Errorf(constants.ErrorBadRequest)
 						}
@@ -534,7 +567,7 @@ func ProcessEvent(
 			}
 			// agentAuthStateEvent = models.AuthorizationEvent{Event: *authEvent}
 			if localAuthEvent != nil && localAuthEvent.ID != ""  {
-				authMoreRecent = IsMoreRecentEvent(currentLocaltAuthState.ID, int(*currentLocaltAuthState.Timestamp), localAuthEvent.ID, int(localAuthEvent.Timestamp))
+				authMoreRecent = IsMoreRecentEvent(currentLocaltAuthState.Order(), localAuthEvent.Order())
 				// authMoreRecent = authMoreRecent &&
 				if !authMoreRecent {
 					// this is a bad event using an old auth state.
@@ -13,6 +13,7 @@ import (
 	"github.com/mlayerprotocol/go-mlayer/common/utils"
 	"github.com/mlayerprotocol/go-mlayer/configs"
 	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/chain"
 	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
 	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
 	"github.com/mlayerprotocol/go-mlayer/internal/sql/models"
@@ -76,9 +77,24 @@ func ValidateSubnetData(clientPayload *entities.ClientPayload, chainID configs.C
 	if len(subnet.Ref) > 0 && !utils.IsAlphaNumericDot(subnet.Ref) {
 		return nil, apperror.BadRequest("Ref can only include alpha-numerics, and .")
 	}
+	for _, cat := range subnet.Categories {
+		if constants.SubnetCategory(cat) < constants.CategoryGeneral || constants.SubnetCategory(cat) > constants.CategoryFileSharing {
+			return nil, apperror.BadRequest(fmt.Sprintf("Invalid subnet category %d", cat))
+		}
+	}
+	if subnet.RateLimits != nil {
+		for _, limit := range []*entities.RateLimit{subnet.RateLimits.Agent, subnet.RateLimits.Account, subnet.RateLimits.Subnet} {
+			if limit != nil && limit.Burst > 0 && limit.Burst < limit.Rate {
+				return nil, apperror.BadRequest("Rate limit burst cannot be less than the rate")
+			}
+		}
+	}
+	if err := ValidateSubnetAdmins(&subnet); err != nil {
+		return nil, err
+	}
 	var valid bool
 	// b, _ := subnet.EncodeBytes()
-	msg, err := clientPayload.GetHash()
+	msg, err := clientPayload.SignatureDataMessage(subnet.SignatureData.Type)
 	if err != nil {
 		return nil, err
 	}
@@ -91,6 +107,16 @@ func ValidateSubnetData(clientPayload *entities.ClientPayload, chainID configs.C
 
 		valid = crypto.VerifySignatureECC(entities.AddressFromString(string(subnet.Account)).Addr, &msgByte, subnet.SignatureData.Signature)
 
+	case entities.EIP1271PubKey:
+		authMsg := fmt.Sprintf(constants.SignatureMessageString, action,  subnet.Ref, chainID, encoder.ToBase64Padded(msg))
+		valid, err = chain.VerifyContractWalletSignature(chainID, entities.AddressFromString(string(subnet.Account)).Addr, []byte(authMsg), subnet.SignatureData.Signature)
+		if err != nil {
+			return nil, apperror.Unauthorized("Invalid subnet data signature")
+		}
+
+	case entities.EIP712PubKey:
+		valid = crypto.VerifySignatureECC(entities.AddressFromString(string(subnet.Account)).Addr, &msg, subnet.SignatureData.Signature)
+
 	case entities.TendermintsSecp256k1PubKey:
 		
 		decodedSig, err := base64.StdEncoding.DecodeString(subnet.SignatureData.Signature)
@@ -124,11 +150,22 @@ func ValidateSubnetData(clientPayload *entities.ClientPayload, chainID configs.C
 			if !dsquery.IsErrorNotFound(err) {
 				return nil, err
 			} else {
-				return nil, nil
+				return nil, ValidateSubnetOwnershipTransfer(clientPayload.EventType, &subnet, nil)
 			}
 		}
 		currentSubnetState = &models.SubnetState{Subnet: *snetS}
 	}
+	var currentSubnet *entities.Subnet
+	if currentSubnetState != nil {
+		currentSubnet = &currentSubnetState.Subnet
+	}
+	if err := ValidateSubnetOwnershipTransfer(clientPayload.EventType, &subnet, currentSubnet); err != nil {
+		return nil, err
+	}
+	// sensitive changes to a multisig subnet need its admins approval
+	if err := ValidateProposalApprovals(clientPayload, chainID); err != nil {
+		return nil, err
+	}
 	// logger.Infof("IsValidSigner %v, subId: %s, currentstate: %v, error: %v", valid, subnet.ID, currentSubnetState, err)
 	// logger.Infof("IsValidSigner %v, subId: %s, currentstate: %v, error: %v", valid, subnet.ID, currentSubnetState, err)
 	return currentSubnetState, nil
@@ -226,6 +263,8 @@ func HandleNewPubSubSubnetEvent(event *entities.Event, ctx *context.Context, ) e
 			ID: stateEvent.ID,
 			Hash: stateEvent.Hash,
 			Timestamp: stateEvent.Timestamp,
+			Order: stateEvent.Order(),
+			PreviousEvent: stateEvent.PreviousEvent,
 		}
 	}
 
@@ -286,7 +325,7 @@ func HandleNewPubSubSubnetEvent(event *entities.Event, ctx *context.Context, ) e
 				// 	_, err = saveSubnetEvent(entities.Event{ID: event.ID}, nil, &entities.Event{IsValid: utils.TruePtr(), Synced:  utils.TruePtr()}, &txn, nil )
 				// }
 			} else {
-				dataStates.AddHistoricState(entities.SubnetModel, data.ID, data.MsgPack())
+				dataStates.AddHistoricState(entities.SubnetModel, event.ID, data.MsgPack())
 			}
 			go dsquery.UpdateAccountCounter(event.Payload.Account.ToString())
 			// if err == nil {
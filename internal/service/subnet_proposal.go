@@ -0,0 +1,161 @@
+package service
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/common/utils"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/chain"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+)
+
+const ApproveProposalAction = "approve_proposal"
+
+// ErrApprovalsRequired is returned when a sensitive event does not yet carry enough admin approvals
+var ErrApprovalsRequired = apperror.Forbidden("Subnet admin approvals required")
+
+/*
+ProposalSubnet returns the subnet whose admins must approve the payload.
+It is nil when the event is not sensitive or the subnet has no admin threshold
+*/
+func ProposalSubnet(payload *entities.ClientPayload) (*entities.Subnet, error) {
+	var id string
+	switch constants.EventType(payload.EventType) {
+	// DeleteSubnetEvent is not held, the node does not process subnet deletion yet
+	case constants.UpdateSubnetEvent, constants.TransferSubnetOwnershipEvent:
+		data, ok := payload.Data.(entities.Subnet)
+		if !ok {
+			return nil, nil
+		}
+		id = data.ID
+	// renewing an admin authorization extends admin power as much as granting it
+	case constants.AuthorizationEvent, constants.RenewAuthorizationEvent:
+		data, ok := payload.Data.(entities.Authorization)
+		if !ok || utils.SafePointerValue(data.Priviledge, 0) < constants.AdminPriviledge {
+			return nil, nil
+		}
+		id = data.Subnet
+	default:
+		return nil, nil
+	}
+	if id == "" {
+		return nil, nil
+	}
+	subnet, err := dsquery.GetSubnetStateById(id)
+	if err != nil {
+		if dsquery.IsErrorNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if subnet.AdminThreshold == 0 {
+		return nil, nil
+	}
+	return subnet, nil
+}
+
+func IsSubnetAdmin(subnet *entities.Subnet, account entities.DIDString) bool {
+	addr := entities.AddressFromString(string(account)).Addr
+	for _, admin := range subnet.Admins {
+		if strings.EqualFold(entities.AddressFromString(string(admin)).Addr, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyProposalApproval checks that approval is a subnet admins signature over the proposal id
+func VerifyProposalApproval(subnet *entities.Subnet, proposalId string, approval entities.ProposalApproval, chainId configs.ChainId) error {
+	if !IsSubnetAdmin(subnet, approval.Admin) {
+		return apperror.Forbidden("Account is not an admin of this subnet")
+	}
+	hash, err := hex.DecodeString(proposalId)
+	if err != nil {
+		return apperror.BadRequest("Invalid proposal id")
+	}
+	valid, err := chain.VerifyAccountSignature(approval.SignatureData, entities.AddressFromString(string(approval.Admin)).Addr, ApproveProposalAction, subnet.ID, hash, chainId)
+	if err != nil || !valid {
+		return apperror.Unauthorized("Invalid approval signature")
+	}
+	return nil
+}
+
+// CountProposalApprovals returns how many distinct admins validly approved the payload
+func CountProposalApprovals(payload *entities.ClientPayload, subnet *entities.Subnet, chainId configs.ChainId) (uint16, error) {
+	id, err := entities.ProposalId(*payload)
+	if err != nil {
+		return 0, err
+	}
+	approved := map[string]bool{}
+	for _, approval := range payload.Approvals {
+		admin := strings.ToLower(entities.AddressFromString(string(approval.Admin)).Addr)
+		if approved[admin] {
+			continue
+		}
+		if err := VerifyProposalApproval(subnet, id, approval, chainId); err != nil {
+			logger.Debugf("CountProposalApprovals: %v", err)
+			continue
+		}
+		approved[admin] = true
+	}
+	return uint16(len(approved)), nil
+}
+
+// ValidateProposalApprovals returns ErrApprovalsRequired until a sensitive event has enough admin approvals
+func ValidateProposalApprovals(payload *entities.ClientPayload, chainId configs.ChainId) error {
+	subnet, err := ProposalSubnet(payload)
+	if err != nil || subnet == nil {
+		return err
+	}
+	count, err := CountProposalApprovals(payload, subnet, chainId)
+	if err != nil {
+		return err
+	}
+	if count < subnet.AdminThreshold {
+		return ErrApprovalsRequired
+	}
+	return nil
+}
+
+/*
+IsPendingProposal reports whether the payload is being finalized from a stored proposal that has not expired.
+The payload timestamp must have been fresh when the proposal was stored, so only the proposal itself skips the freshness check
+*/
+func IsPendingProposal(payload *entities.ClientPayload, timestamp uint64) bool {
+	subnet, err := ProposalSubnet(payload)
+	if err != nil || subnet == nil {
+		return false
+	}
+	id, err := entities.ProposalId(*payload)
+	if err != nil {
+		return false
+	}
+	proposal, err := dsquery.GetSubnetProposal(subnet.ID, id)
+	if err != nil || proposal.Status != entities.PendingProposal || proposal.IsExpired() {
+		return false
+	}
+	return timestamp+15000 >= proposal.Timestamp && timestamp <= proposal.Timestamp+15000
+}
+
+// ValidateSubnetAdmins checks the admin set and threshold an owner configures
+func ValidateSubnetAdmins(subnet *entities.Subnet) error {
+	if int(subnet.AdminThreshold) > len(subnet.Admins) {
+		return apperror.BadRequest("Admin threshold cannot exceed the number of admins")
+	}
+	seen := map[string]bool{}
+	for _, admin := range subnet.Admins {
+		addr := strings.ToLower(entities.AddressFromString(string(admin)).Addr)
+		if addr == "" {
+			return apperror.BadRequest("Invalid subnet admin")
+		}
+		if seen[addr] {
+			return apperror.BadRequest("Duplicate subnet admin")
+		}
+		seen[addr] = true
+	}
+	return nil
+}
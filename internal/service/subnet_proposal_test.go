@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+)
+
+func TestProposalSubnetGatesSensitiveEvents(t *testing.T) {
+	withTestStores(t)
+	subnet := entities.Subnet{
+		ID: "s1", Account: "did:0x8f6b2a1e3c4d5e6f708192a3b4c5d6e7f8091a2b", AdminThreshold: 2,
+		Admins: []entities.DIDString{"did:0x1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d", "did:0x2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e"},
+	}
+	putTestState(t, entities.SubnetModel, subnet.ID, "ev-s1", subnet.MsgPack())
+	admin, member := constants.AdminPriviledge, constants.MemberPriviledge
+	tests := []struct {
+		name      string
+		eventType constants.EventType
+		data      any
+		held      bool
+	}{
+		{"subnet update", constants.UpdateSubnetEvent, entities.Subnet{ID: "s1"}, true},
+		{"subnet ownership transfer", constants.TransferSubnetOwnershipEvent, entities.Subnet{ID: "s1"}, true},
+		{"subnet deletion is not processed", constants.DeleteSubnetEvent, entities.Subnet{ID: "s1"}, false},
+		{"admin grant", constants.AuthorizationEvent, entities.Authorization{Subnet: "s1", Priviledge: &admin}, true},
+		{"admin renewal", constants.RenewAuthorizationEvent, entities.Authorization{Subnet: "s1", Priviledge: &admin}, true},
+		{"member renewal", constants.RenewAuthorizationEvent, entities.Authorization{Subnet: "s1", Priviledge: &member}, false},
+		{"member grant", constants.AuthorizationEvent, entities.Authorization{Subnet: "s1", Priviledge: &member}, false},
+	}
+	for _, tt := range tests {
+		held, err := ProposalSubnet(&entities.ClientPayload{EventType: uint16(tt.eventType), Data: tt.data})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if (held != nil) != tt.held {
+			t.Errorf("%s: expected held %v", tt.name, tt.held)
+		}
+	}
+}
+
+func TestValidateSubnetAdmins(t *testing.T) {
+	admins := []entities.DIDString{"did:0x1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d", "did:0x2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e"}
+	if err := ValidateSubnetAdmins(&entities.Subnet{Admins: admins, AdminThreshold: 2}); err != nil {
+		t.Fatalf("expected a 2 of 2 admin set to be valid: %v", err)
+	}
+	if err := ValidateSubnetAdmins(&entities.Subnet{Admins: admins, AdminThreshold: 3}); err == nil {
+		t.Fatal("expected a threshold above the admin count to be rejected")
+	}
+	if err := ValidateSubnetAdmins(&entities.Subnet{Admins: append(admins, "did:0x1C2D3E4F5A6B7C8D9E0F1A2B3C4D5E6F7A8B9C0D"), AdminThreshold: 1}); err == nil {
+		t.Fatal("expected a duplicate admin to be rejected")
+	}
+}
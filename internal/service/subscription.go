@@ -22,17 +22,20 @@ import (
 /*
 Validate an agent authorization
 */
-func ValidateSubscriptionData(payload *entities.ClientPayload, topic *entities.Topic) (currentSubscriptionState *models.SubscriptionState, err error) {
+func ValidateSubscriptionData(payload *entities.ClientPayload, topic *entities.Topic, txn *datastore.Txn) (currentSubscriptionState *models.SubscriptionState, err error) {
 	// check fields of subscription
 
 	subscription := payload.Data.(entities.Subscription)
 	var currentState *models.SubscriptionState
+	if err = ValidateAgentTopicScope(payload, topic.ID, txn); err != nil {
+		return nil, err
+	}
 	
 logger.Info("ValidateSubscription...")
 	// err = query.GetOne(models.SubscriptionState{
 	// 	Subscription: entities.Subscription{Subscriber: subscription.Subscriber, Subnet: subscription.Subnet, Topic: subscription.Topic},
 	// }, &currentState)
-	_currentState, err := dsquery.GetSubscriptions(entities.Subscription{Subscriber: subscription.Subscriber, Subnet: subscription.Subnet, Topic: subscription.Topic}, dsquery.DefaultQueryLimit, nil)
+	_currentState, err := dsquery.GetSubscriptions(entities.Subscription{Subscriber: subscription.Subscriber, Subnet: subscription.Subnet, Topic: subscription.Topic}, dsquery.DefaultQueryLimit, txn)
 	if err != nil {
 		logger.Errorf("Invalid event payload %e ", err)
 
@@ -86,7 +89,7 @@ func HandleNewPubSubSubscriptionEvent(event *entities.Event, ctx *context.Contex
 		panic("Unable to load config from context")
 	}
 	data := event.Payload.Data.(entities.Subscription)
-	dataStates := dsquery.NewDataStates(cfg)
+	dataStates, batch := eventDataStates(ctx, cfg)
 	dataStates.AddEvent(*event)
 	// var id = data.ID
 	// if len(data.ID) == 0 {
@@ -113,6 +116,10 @@ func HandleNewPubSubSubscriptionEvent(event *entities.Event, ctx *context.Contex
 		validator = string(event.Validator)
 	}
 	defer func () {
+		if batch != nil {
+			batch.onCommit(func() { OnFinishProcessingEvent(ctx, event, &data) })
+			return
+		}
 		stateUpdateError := dataStates.Commit(nil, nil, nil)
 		if stateUpdateError != nil {
 			
@@ -132,7 +139,7 @@ func HandleNewPubSubSubscriptionEvent(event *entities.Event, ctx *context.Contex
 	// 	logger.Error(err)
 	// }
 	
-	subs, err := dsquery.GetSubscriptions(entities.Subscription{Subnet: subnet, Topic: data.Topic, Subscriber: entities.AddressFromString(string(data.Subscriber)).ToDIDString()}, nil, nil)
+	subs, err := dsquery.GetSubscriptions(entities.Subscription{Subnet: subnet, Topic: data.Topic, Subscriber: entities.AddressFromString(string(data.Subscriber)).ToDIDString()}, nil, batch.Txn(entities.SubscriptionModel))
 	if err == nil && subs != nil && len(subs) > 0 {
 		localState = models.SubscriptionState{
 			Subscription: *subs[0],
@@ -169,6 +176,8 @@ func HandleNewPubSubSubscriptionEvent(event *entities.Event, ctx *context.Contex
 			ID: stateEvent.ID,
 			Hash: stateEvent.Hash,
 			Timestamp: stateEvent.Timestamp,
+			Order: stateEvent.Order(),
+			PreviousEvent: stateEvent.PreviousEvent,
 		}
 	}
 
@@ -194,7 +203,7 @@ func HandleNewPubSubSubscriptionEvent(event *entities.Event, ctx *context.Contex
 	if previousEventUptoDate  && authEventUpToDate {
 
 		_topic := &entities.Topic{}
-		_, err = SyncTypedStateById(data.Topic, _topic, cfg, validator)
+		_, err = SyncTypedStateByIdTxn(data.Topic, _topic, cfg, validator, batch.Txn(entities.TopicModel))
 		if err != nil {
 			logger.Error("HandleNewPubSubSubscriptionEvent/SyncTypedStateById", err)
 			return err
@@ -202,7 +211,7 @@ func HandleNewPubSubSubscriptionEvent(event *entities.Event, ctx *context.Contex
 		
 
 		if !event.IsLocal(cfg) {
-			_, err = ValidateSubscriptionData(&event.Payload, &topic.Topic)
+			_, err = ValidateSubscriptionData(&event.Payload, &topic.Topic, batch.Txn(entities.SubscriptionModel))
 		}
 		if err != nil {
 			// update error and mark as synced
@@ -218,7 +227,7 @@ func HandleNewPubSubSubscriptionEvent(event *entities.Event, ctx *context.Contex
 				// update state
 					dataStates.AddCurrentState(entities.SubscriptionModel, data.DataKey(), data)	
 			} else {
-				dataStates.AddHistoricState(entities.SubscriptionModel, data.DataKey(), data.MsgPack())
+				dataStates.AddHistoricState(entities.SubscriptionModel, event.ID, data.MsgPack())
 			}
 			
 		}
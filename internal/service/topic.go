@@ -18,12 +18,12 @@ import (
 /*
 Validate an agent authorization
 */
-func ValidateTopicData(topic *entities.Topic, authState *models.AuthorizationState) (currentTopicState *models.TopicState, err error) {
+func ValidateTopicData(topic *entities.Topic, authState *models.AuthorizationState, txn *datastore.Txn) (currentTopicState *models.TopicState, err error) {
 
 	// TODO state might have changed befor receiving event, so we need to find state that is relevant to this event.
 
 	if topic.ID != "" {
-		_topicState, err := dsquery.GetTopicById(topic.ID)
+		_topicState, err := dsquery.GetTopicByIdTxn(topic.ID, txn)
 		if err != nil && !dsquery.IsErrorNotFound(err) {
 			if err == gorm.ErrRecordNotFound {
 				return nil, apperror.Forbidden("Invalid subnet id")
@@ -57,7 +57,7 @@ func HandleNewPubSubTopicEvent(event *entities.Event, ctx *context.Context) erro
 		panic("Unable to get config from context")
 	}
 
-	dataStates := dsquery.NewDataStates(cfg)
+	dataStates, batch := eventDataStates(ctx, cfg)
 	dataStates.AddEvent(*event)
 	
 	data := event.Payload.Data.(entities.Topic)
@@ -78,7 +78,6 @@ func HandleNewPubSubTopicEvent(event *entities.Event, ctx *context.Context) erro
 		return err
 	}
 	data.Hash = hex.EncodeToString(hash)
-	data.Account = event.Payload.Account
 	data.Agent = event.Payload.Agent
 	data.Timestamp = event.Payload.Timestamp
 	logger.Debug("Processing 1...")
@@ -87,6 +86,10 @@ func HandleNewPubSubTopicEvent(event *entities.Event, ctx *context.Context) erro
 	validator := utils.IfThenElse(event.IsLocal(cfg),  "",  string(event.Validator))
 	
 	defer func () {
+		if batch != nil {
+			batch.onCommit(func() { OnFinishProcessingEvent(ctx, event, &data) })
+			return
+		}
 		stateUpdateError := dataStates.Commit(nil, nil, nil)
 		if stateUpdateError != nil {
 			
@@ -102,7 +105,7 @@ func HandleNewPubSubTopicEvent(event *entities.Event, ctx *context.Context) erro
 	if data.ID != "" {
 		topic :=  &entities.Topic{}
 	 //topic, err := dsquery.GetTopicById(id)
-		_, err = SyncTypedStateById(id, topic, cfg, validator)
+		_, err = SyncTypedStateByIdTxn(id, topic, cfg, validator, batch.Txn(entities.TopicModel))
 		if err != nil {
 			logger.Error("HandleNewPubSubAuthEvent/SyncTypedStateById", err)
 			return err
@@ -110,6 +113,7 @@ func HandleNewPubSubTopicEvent(event *entities.Event, ctx *context.Context) erro
 		localState = models.TopicState{Topic: *topic}
 
 	}
+	data.Account = TopicOwner(event.EventType, event.Payload.Account, &localState.Topic)
 	logger.Debug("Processing 2...")
 	// stateTxn, err := stores.StateStore.NewTransaction(context.Background(), false) // true for read-write, false for read-only
 	// if err != nil {
@@ -150,9 +154,11 @@ func HandleNewPubSubTopicEvent(event *entities.Event, ctx *context.Context) erro
 	var localDataStateEvent *LocalDataStateEvent
 	if stateEvent != nil {
 		localDataStateEvent = &LocalDataStateEvent{
-			ID:        stateEvent.ID,
-			Hash:      stateEvent.Hash,
-			Timestamp: stateEvent.Timestamp,
+			ID:            stateEvent.ID,
+			Hash:          stateEvent.Hash,
+			Timestamp:     stateEvent.Timestamp,
+			Order:         stateEvent.Order(),
+			PreviousEvent: stateEvent.PreviousEvent,
 		}
 	}
 
@@ -179,7 +185,10 @@ func HandleNewPubSubTopicEvent(event *entities.Event, ctx *context.Context) erro
 	logger.Infof("SuccessfullyIncrementingCounters")
 	if previousEventUptoDate && authEventUptoDate {
 		if !event.IsLocal(cfg) {
-			_, err = ValidateTopicData(&data, authState)
+			_, err = ValidateTopicData(&data, authState, batch.Txn(entities.TopicModel))
+			if err == nil {
+				err = ValidateTopicOwnershipTransfer(&event.Payload, &data, &localState.Topic)
+			}
 		}
 		
 		if err != nil {
@@ -198,7 +207,7 @@ func HandleNewPubSubTopicEvent(event *entities.Event, ctx *context.Context) erro
 				// update state
 					dataStates.AddCurrentState(entities.TopicModel,id, data)	
 			} else {
-				dataStates.AddHistoricState(entities.TopicModel,id, data.MsgPack())
+				dataStates.AddHistoricState(entities.TopicModel, event.ID, data.MsgPack())
 			}
 			
 		}
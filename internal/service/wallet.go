@@ -3,225 +3,145 @@ package service
 import (
 	"context"
 	"encoding/hex"
-	"strings"
 
+	"github.com/ipfs/go-datastore"
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
 	"github.com/mlayerprotocol/go-mlayer/common/constants"
 	"github.com/mlayerprotocol/go-mlayer/common/utils"
 	"github.com/mlayerprotocol/go-mlayer/configs"
 	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
 	"github.com/mlayerprotocol/go-mlayer/internal/sql/models"
-	query "github.com/mlayerprotocol/go-mlayer/internal/sql/query"
-	"github.com/mlayerprotocol/go-mlayer/pkg/core/sql"
-	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 /*
-Validate an agent authorization
+Validate a subnet wallet
 */
-func ValidateWalletData(Wallet *entities.Wallet) (currentWalletState *models.WalletState, err error) {
-	// check fields of Wallet
-	// logger.Debug("Walletcc", Wallet.Ref)
-	// if len(Wallet.Ref) > 60 {
-	// 	return nil, apperror.BadRequest("Wallet ref cannont be more than 40 characters")
-	// }
-	// if len(Wallet.Ref) > 0 && !utils.IsAlphaNumericDot(Wallet.Ref) {
-	// 	return nil, apperror.BadRequest("Ref must be alphanumeric, _ and . but cannot start with a number")
-	// }
-	return nil, nil
+func ValidateWalletData(wallet *entities.Wallet, txn *datastore.Txn) (currentWalletState *models.WalletState, err error) {
+	if len(wallet.Name) == 0 || len(wallet.Name) > 12 {
+		return nil, apperror.BadRequest("Wallet name must be between 1 and 12 characters")
+	}
+	if len(wallet.Symbol) == 0 || len(wallet.Symbol) > 8 {
+		return nil, apperror.BadRequest("Wallet symbol must be between 1 and 8 characters")
+	}
+	if wallet.ID != "" {
+		_wallet, err := dsquery.GetWalletByIdTxn(wallet.ID, txn)
+		if err != nil && !dsquery.IsErrorNotFound(err) {
+			return nil, err
+		}
+		if _wallet != nil {
+			if _wallet.Subnet != wallet.Subnet {
+				return nil, apperror.BadRequest("Wallet subnet cannot be changed")
+			}
+			currentWalletState = &models.WalletState{Wallet: *_wallet}
+		}
+	}
+	return currentWalletState, nil
 }
 
-func HandleNewPubSubWalletEvent(event *entities.Event, ctx *context.Context) error {
-	logger.WithFields(logrus.Fields{"event": event}).Debug("New Wallet event from pubsub channel")
-	markAsSynced := false
-	updateState := false
-	var eventError string
-	// hash, _ := event.GetHash()
-	err := ValidateEvent(*event)
+func saveWalletEvent(where entities.Event, createData *entities.Event, updateData *entities.Event, txn *datastore.Txn, tx *gorm.DB) (*entities.Event, error) {
+	return SaveEvent(entities.WalletModel, where, createData, updateData, txn)
+}
 
-	if err != nil {
-		logger.Error(err)
-		return err
+func HandleNewPubSubWalletEvent(event *entities.Event, ctx *context.Context) error {
+	cfg, ok := (*ctx).Value(constants.ConfigKey).(*configs.MainConfiguration)
+	if !ok {
+		panic("Unable to get config from context")
 	}
 
-	logger.Debugf("Event is a valid event %s", event.PayloadHash)
-	cfg, _ := (*ctx).Value(constants.ConfigKey).(*configs.MainConfiguration)
-
-	// Extract and validate the Data of the paylaod which is an Events Payload Data,
-	data := event.Payload.Data.(*entities.Wallet)
-	hash, _ := data.GetHash()
-	data.Hash = hex.EncodeToString(hash)
-	authEventHash := event.AuthEvent
-	authState, authError := query.GetOneAuthorizationState(entities.Authorization{Event: authEventHash})
+	dataStates, batch := eventDataStates(ctx, cfg)
+	dataStates.AddEvent(*event)
 
-	currentState, err := ValidateWalletData(data)
+	data := event.Payload.Data.(entities.Wallet)
+	id, err := entities.GetId(data, data.ID)
 	if err != nil {
-		// penalize node for broadcasting invalid data
-		logger.Debugf("Invalid Wallet data %v. Node should be penalized", err)
 		return err
 	}
-
-	// check if we are upto date on this event
-	prevEventUpToDate := query.EventExist(&event.PreviousEvent) || (currentState == nil && event.PreviousEvent.ID == "") || (currentState != nil && currentState.Event.ID == event.PreviousEvent.ID)
-	authEventUpToDate := query.EventExist(&event.AuthEvent) || (authState == nil && event.AuthEvent.ID == "") || (authState != nil && authState.Event == authEventHash)
-
-	// Confirm if this is an older event coming after a newer event.
-	// If it is, then we only have to update our event history, else we need to also update our current state
-	isMoreRecent := false
-	if currentState != nil && currentState.ID != data.Hash {
-		var currentStateEvent = &models.WalletEvent{}
-		query.GetOne(entities.Event{Hash: currentState.Event.ID}, currentStateEvent)
-		isMoreRecent, markAsSynced = IsMoreRecent(
-			currentStateEvent.ID,
-			currentState.Event.ID,
-			currentStateEvent.Payload.Timestamp,
-			event.Hash,
-			event.Payload.Timestamp,
-			markAsSynced,
-		 )
+	data.Event = *event.GetPath()
+	data.BlockNumber = event.BlockNumber
+	data.Cycle = event.Cycle
+	data.Epoch = event.Epoch
+	data.Signature = event.Signature
+	hash, err := data.GetHash()
+	if err != nil {
+		return err
 	}
+	data.Hash = hex.EncodeToString(hash)
+	data.Account = event.Payload.Account
+	data.Agent = event.Payload.Agent
+	data.Timestamp = event.Payload.Timestamp
 
-	if authError != nil {
-		// check if we are upto date. If we are, then the error is an actual one
-		// the error should be attached when saving the event
-		// But if we are not upto date, then we might need to wait for more info from the network
-
-		if prevEventUpToDate && authEventUpToDate {
-			// we are upto date. This is an actual error. No need to expect an update from the network
-			eventError = authError.Error()
-			markAsSynced = true
-		} else {
-			if currentState == nil || (currentState != nil && isMoreRecent) { // it is a morer ecent event
-				if strings.HasPrefix(authError.Error(), constants.ErrorForbidden) || strings.HasPrefix(authError.Error(), constants.ErrorUnauthorized) {
-					markAsSynced = false
-				} else {
-					// entire event can be considered bad since the payload data is bad
-					// this should have been sorted out before broadcasting to the network
-					// TODO penalize the node that broadcasted this
-					eventError = authError.Error()
-					markAsSynced = true
-				}
-
-			} else {
-				// we are upto date. We just need to store this event as well.
-				// No need to update state
-				markAsSynced = true
-				eventError = authError.Error()
-			}
+	defer func() {
+		if batch != nil {
+			batch.onCommit(func() { OnFinishProcessingEvent(ctx, event, &data) })
+			return
 		}
-
-	}
-
-	// If no error, then we should act accordingly as well
-	// If are upto date, then we should update the state based on if its a recent or old event
-	if len(eventError) == 0 {
-		if prevEventUpToDate && authEventUpToDate { // we are upto date
-			if currentState == nil || isMoreRecent {
-				updateState = true
-				markAsSynced = true
-			} else {
-				// Its an old event
-				markAsSynced = true
-				updateState = false
-			}
+		stateUpdateError := dataStates.Commit(nil, nil, nil)
+		if stateUpdateError != nil {
+			panic(stateUpdateError)
 		} else {
-			updateState = false
-			markAsSynced = false
+			go OnFinishProcessingEvent(ctx, event, &data)
 		}
+	}()
 
+	var localState *entities.Wallet
+	if data.ID != "" {
+		localState, err = dsquery.GetWalletByIdTxn(data.ID, batch.Txn(entities.WalletModel))
+		if err != nil && !dsquery.IsErrorNotFound(err) {
+			logger.Error("HandleNewPubSubWalletEvent/GetWalletById", err)
+			return err
+		}
 	}
 
-	// Save stuff permanently
-	tx := sql.SqlDb.Begin()
-	logger.Debug(":::::updateState: Db Error", updateState, currentState == nil)
-
-	// If the event was not signed by your node
-	if string(event.Validator) != (*cfg).PublicKeyEDDHex  {
-		// save the event
-		event.Error = eventError
-		event.IsValid = utils.BoolPtr(markAsSynced && len(eventError) == 0)
-		event.Synced = &markAsSynced
-		event.Broadcasted = true
-		_, _, err := query.SaveRecord(models.WalletEvent{
-			Event: entities.Event{
-				PayloadHash: event.PayloadHash,
-			},
-		}, &models.WalletEvent{
-			Event: *event,
-		}, nil, tx)
-		if err != nil {
-			tx.Rollback()
-			logger.Error("1000: Db Error", err)
+	var localDataState *LocalDataState
+	var localDataStateEvent *LocalDataStateEvent
+	if localState != nil {
+		localDataState = &LocalDataState{
+			ID:        localState.ID,
+			Hash:      localState.ID,
+			Event:     &localState.Event,
+			Timestamp: localState.Timestamp,
+		}
+		stateEvent, err := dsquery.GetEventFromPath(&localState.Event)
+		if err != nil && !dsquery.IsErrorNotFound(err) {
+			logger.Error("HandleNewPubSubWalletEvent/GetEventFromPath", err)
 			return err
 		}
-	} else {
-		if markAsSynced {
-			_, _, err := query.SaveRecord(models.WalletEvent{
-				Event: entities.Event{PayloadHash: event.PayloadHash},
-			}, 
-			&models.WalletEvent{
-				Event: *event,
-			},
-			&models.WalletEvent{
-				Event: entities.Event{Synced: utils.TruePtr(), Broadcasted: true, Error: eventError, IsValid: utils.BoolPtr(len(eventError) == 0)},
-			}, tx)
-			if err != nil {
-				logger.Error("DB error", err)
-			}
-		} else {
-			// mark as broadcasted
-			_, _, err := query.SaveRecord(models.WalletEvent{
-				Event: entities.Event{PayloadHash: event.PayloadHash, Broadcasted: false},
-			},
-			&models.WalletEvent{
-				Event: *event,
-			}, 
-				&models.WalletEvent{
-					Event: entities.Event{Broadcasted: true},
-				}, tx)
-			if err != nil {
-				logger.Error("DB error", err)
+		if stateEvent != nil {
+			localDataStateEvent = &LocalDataStateEvent{
+				ID:            stateEvent.ID,
+				Hash:          stateEvent.Hash,
+				Timestamp:     stateEvent.Timestamp,
+				Order:         stateEvent.Order(),
+				PreviousEvent: stateEvent.PreviousEvent,
 			}
 		}
 	}
 
-	// d, err := event.Payload.EncodeBytes()
-	// if err != nil {
-	// 	logger.Errorf("Invalid event payload")
-	// }
-	// agent, err := crypto.GetSignerECC(&d, &event.Payload.Signature)
-	// if err != nil {
-	// 	logger.Errorf("Invalid event payload")
-	// }
-	data.Event = *entities.NewEventPath(event.Validator, entities.WalletModel, event.ID)
-	// data.Agent = entities.DIDString(agent)
-	data.Account = event.Payload.Account
-	// logger.Error("data.Public ", data.Public)
-
-	if updateState {
-		_, _, err := query.SaveRecord(models.WalletState{
-			Wallet: entities.Wallet{ID: data.ID},
-		}, &models.WalletState{
-			Wallet: *data,
-		}, utils.IfThenElse(event.EventType == uint16(constants.UpdateWalletEvent), &models.WalletState{
-			Wallet: *data,
-		}, nil ), tx)
-		if err != nil {
-			tx.Rollback()
-			logger.Error("7000: Db Error", err)
-			return err
-		}
+	eventData := PayloadData{Subnet: data.Subnet, localDataState: localDataState, localDataStateEvent: localDataStateEvent}
+	previousEventUptoDate, authEventUptoDate, _, eventIsMoreRecent, err := ProcessEvent(event, eventData, true, saveWalletEvent, nil, nil, ctx, dataStates)
+	if err != nil {
+		logger.Error("ProcessEventError ", err)
+		return err
 	}
-	tx.Commit()
-
-	if string(event.Validator) != (*cfg).PublicKeyEDDHex  {
-		dependent, err := query.GetDependentEvents(event)
-		if err != nil {
-			logger.Debug("Unable to get dependent events", err)
+	if previousEventUptoDate && authEventUptoDate {
+		if !event.IsLocal(cfg) {
+			_, err = ValidateWalletData(&data, batch.Txn(entities.WalletModel))
+			if err == nil && localState != nil && localState.Account != data.Account {
+				err = apperror.Forbidden("Only the wallet owner can update the wallet")
+			}
 		}
-		for _, dep := range *dependent {
-			go HandleNewPubSubWalletEvent(&dep, ctx)
+		if err != nil {
+			dataStates.AddEvent(entities.Event{ID: event.ID, Error: err.Error(), IsValid: utils.FalsePtr(), Synced: utils.TruePtr()})
+		} else {
+			dataStates.AddEvent(entities.Event{ID: event.ID, IsValid: utils.TruePtr(), Synced: utils.TruePtr()})
+			if eventIsMoreRecent {
+				dataStates.AddCurrentState(entities.WalletModel, id, data)
+			} else {
+				dataStates.AddHistoricState(entities.WalletModel, event.ID, data.MsgPack())
+			}
 		}
 	}
-return nil
-	// TODO Broadcast the updated state
+	return nil
 }
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"sync"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/common/utils"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+	"gorm.io/gorm"
+)
+
+// ledgerMutex serializes balance updates so that two transactions never read the same balance
+var ledgerMutex sync.Mutex
+
+/*
+ValidateTokenTransaction checks a mint, transfer or burn against the wallet and, locally, against the senders balance and nonce.
+It returns the wallet the transaction moves
+*/
+func ValidateTokenTransaction(payload *entities.ClientPayload, t *entities.TokenTransaction, txn *datastore.Txn) (*entities.Wallet, error) {
+	wallet, err := dsquery.GetWalletByIdTxn(t.Wallet, txn)
+	if err != nil {
+		if dsquery.IsErrorNotFound(err) {
+			return nil, apperror.NotFound("Wallet not found")
+		}
+		return nil, err
+	}
+	if wallet.Subnet != payload.Subnet {
+		return nil, apperror.BadRequest("Wallet does not belong to this subnet")
+	}
+	if _, err := t.AmountInt(); err != nil {
+		return nil, apperror.BadRequest(err.Error())
+	}
+	if payload.Nonce == 0 {
+		return nil, apperror.BadRequest("Token transactions require a nonce")
+	}
+	switch constants.EventType(payload.EventType) {
+	case constants.MintTokenEvent:
+		if !isSameAccount(payload.Account, wallet.Account) {
+			return nil, apperror.Forbidden("Only the wallet owner can mint tokens")
+		}
+		if t.From != "" || t.To == "" {
+			return nil, apperror.BadRequest("Mints require a recipient and no sender")
+		}
+	case constants.TransferTokenEvent:
+		if t.To == "" || isSameAccount(t.From, t.To) {
+			return nil, apperror.BadRequest("Transfers require a different recipient")
+		}
+		if !isSameAccount(t.From, payload.Account) {
+			return nil, apperror.Forbidden("Tokens can only be sent from the signing account")
+		}
+	case constants.BurnTokenEvent:
+		if t.To != "" {
+			return nil, apperror.BadRequest("Burns cannot have a recipient")
+		}
+		if !isSameAccount(t.From, payload.Account) {
+			return nil, apperror.Forbidden("Tokens can only be burnt from the signing account")
+		}
+	default:
+		return nil, apperror.BadRequest("Invalid token event")
+	}
+	t.Account = payload.Account
+	t.Nonce = payload.Nonce
+	return wallet, nil
+}
+
+// ValidateTokenBalance rejects transactions that reuse a nonce or spend more than the sender holds
+func ValidateTokenBalance(t *entities.TokenTransaction, txn *datastore.Txn) error {
+	var read datastore.Read
+	if txn != nil {
+		read = *txn
+	}
+	spent, err := dsquery.GetSpentNonce(t.Wallet, t.Account, t.Nonce, read)
+	if err != nil {
+		return err
+	}
+	if spent != nil {
+		return apperror.BadRequest("Nonce already used")
+	}
+	if t.From == "" {
+		return nil
+	}
+	amount, _ := t.AmountInt()
+	balance, err := dsquery.GetWalletBalance(t.Wallet, t.From, read)
+	if err != nil {
+		return err
+	}
+	if balance.Balance.Cmp(amount) < 0 {
+		return apperror.BadRequest(dsquery.ErrInsufficientBalance.Error())
+	}
+	return nil
+}
+
+func saveTokenEvent(where entities.Event, createData *entities.Event, updateData *entities.Event, txn *datastore.Txn, tx *gorm.DB) (*entities.Event, error) {
+	return SaveEvent(entities.WalletModel, where, createData, updateData, txn)
+}
+
+/*
+applyTokenEvent inserts the transaction into its wallets ledger, which replays every later transaction on top of it.
+Transactions whose validity changed in the replay are updated with it. An earlier transaction keeps a nonce both spend.
+Within a batch the ledger is updated in the batches transaction and committed with it
+*/
+func applyTokenEvent(t *entities.TokenTransaction, dataStates *dsquery.DataStates, batch *EventBatch) (valid bool, err error) {
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	var stateTxn datastore.Txn
+	if batch != nil {
+		stateTxn = batch.stateTxn
+	} else {
+		txn, err := stores.StateStore.NewTransaction(context.Background(), false)
+		if err != nil {
+			return false, err
+		}
+		defer txn.Discard(context.Background())
+		stateTxn = txn
+	}
+
+	outcomes, err := dsquery.InsertTokenTransaction(t, stateTxn)
+	if err != nil {
+		return false, err
+	}
+	for _, outcome := range outcomes {
+		if outcome.SpentBy != nil {
+			conflict := entities.NewEventConflict(entities.WalletModel, t.Wallet, outcome.Transaction.Order(), outcome.SpentBy.Order(), "nonce already used")
+			// unlike state updates, the earlier event keeps the nonce
+			conflict.Winner, conflict.Loser = outcome.SpentBy.Order(), outcome.Transaction.Order()
+			dataStates.AddConflict(conflict)
+		}
+		isValid := outcome.Valid
+		if outcome.Transaction.Event.ID == t.Event.ID {
+			valid = isValid
+			dataStates.AddEvent(entities.Event{ID: t.Event.ID, Error: outcome.Error, IsValid: &isValid, Synced: utils.TruePtr()})
+			continue
+		}
+		var eventTxn *datastore.Txn
+		if batch != nil {
+			eventTxn = &batch.eventTxn
+		}
+		event, err := dsquery.GetEventByIdTxn(outcome.Transaction.Event.ID, entities.WalletModel, eventTxn)
+		if err != nil {
+			return false, err
+		}
+		event.Error = outcome.Error
+		event.IsValid = &isValid
+		dataStates.AddEvent(*event)
+	}
+	if batch != nil {
+		return valid, nil
+	}
+	if err := dataStates.Commit(&stateTxn, nil, nil); err != nil {
+		return false, err
+	}
+	return valid, stateTxn.Commit(context.Background())
+}
+
+func HandleNewPubSubTokenEvent(event *entities.Event, ctx *context.Context) error {
+	cfg, ok := (*ctx).Value(constants.ConfigKey).(*configs.MainConfiguration)
+	if !ok {
+		panic("Unable to get config from context")
+	}
+
+	dataStates, batch := eventDataStates(ctx, cfg)
+	dataStates.AddEvent(*event)
+
+	data := event.Payload.Data.(entities.TokenTransaction)
+	data.Event = *event.GetPath()
+	data.BlockNumber = event.BlockNumber
+	data.Cycle = event.Cycle
+	data.Epoch = event.Epoch
+	data.EventSignature = event.Signature
+	data.Agent = event.Payload.Agent
+	data.Account = event.Payload.Account
+	data.Nonce = event.Payload.Nonce
+	hash, err := data.GetHash()
+	if err != nil {
+		return err
+	}
+	data.Hash = hex.EncodeToString(hash)
+
+	eventData := PayloadData{Subnet: event.Payload.Subnet, localDataState: nil, localDataStateEvent: nil}
+	previousEventUptoDate, authEventUptoDate, _, _, err := ProcessEvent(event, eventData, true, saveTokenEvent, nil, nil, ctx, dataStates)
+	if err != nil {
+		logger.Error("ProcessEventError ", err)
+		return err
+	}
+	if !previousEventUptoDate || !authEventUptoDate {
+		if batch != nil {
+			return nil
+		}
+		return dataStates.Commit(nil, nil, nil)
+	}
+
+	if !event.IsLocal(cfg) {
+		_, err = ValidateTokenTransaction(&event.Payload, &data, batch.Txn(entities.WalletModel))
+	}
+	if err == nil {
+		var valid bool
+		valid, err = applyTokenEvent(&data, dataStates, batch)
+		if err == nil && !valid {
+			// the rejection is saved with the ledger so a replay can still accept it
+			return nil
+		}
+		if err == nil && batch != nil {
+			batch.onCommit(func() { OnFinishProcessingEvent(ctx, event, &data) })
+			return nil
+		}
+		if err == nil {
+			go OnFinishProcessingEvent(ctx, event, &data)
+			return nil
+		}
+	}
+	logger.Errorf("TokenTransactionError: %v", err)
+	if batch != nil {
+		return err
+	}
+	// start over so that nothing staged for the failed ledger update is saved
+	dataStates = dsquery.NewDataStates(cfg)
+	dataStates.AddEvent(*event)
+	dataStates.AddEvent(entities.Event{ID: event.ID, Error: err.Error(), IsValid: utils.FalsePtr(), Synced: utils.TruePtr()})
+	return dataStates.Commit(nil, nil, nil)
+}
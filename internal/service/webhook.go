@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/chain"
+	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+	"github.com/mlayerprotocol/go-mlayer/internal/webhook"
+)
+
+/*
+Validate and save a webhook registration signed by the subnet owner
+*/
+func RegisterWebhook(wh *entities.Webhook, cfg *configs.MainConfiguration) (*entities.Webhook, error) {
+	if wh.Timestamp == 0 || wh.Timestamp > uint64(time.Now().UnixMilli())+15000 || wh.Timestamp < uint64(time.Now().UnixMilli())-15000 {
+		return nil, apperror.BadRequest("Invalid webhook timestamp")
+	}
+	if err := webhook.ValidateURL(wh.Url); err != nil {
+		return nil, apperror.BadRequest("Invalid webhook url: " + err.Error())
+	}
+	subnet, err := dsquery.GetSubnetStateById(wh.Subnet)
+	if err != nil {
+		if dsquery.IsErrorNotFound(err) {
+			return nil, apperror.NotFound("Subnet not found")
+		}
+		return nil, err
+	}
+	if !strings.EqualFold(subnet.Account.ToString(), wh.Account.ToString()) {
+		return nil, apperror.Forbidden("Only the subnet owner can register webhooks")
+	}
+	hash, err := wh.GetHash()
+	if err != nil {
+		return nil, err
+	}
+	valid, err := chain.VerifyAccountSignature(wh.SignatureData, entities.AddressFromString(string(wh.Account)).Addr, "write_webhook", wh.Subnet, hash, cfg.ChainId)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, apperror.Unauthorized("Invalid webhook signature")
+	}
+	wh.ID, err = wh.GetId()
+	if err != nil {
+		return nil, err
+	}
+	if err := dsquery.SaveWebhook(wh); err != nil {
+		return nil, err
+	}
+	return wh, nil
+}
+
+/*
+Post a finalized event to every matching webhook of its subnet
+*/
+func DispatchWebhooks(ctx *context.Context, event *entities.Event) {
+	if event.Subnet == "" {
+		return
+	}
+	webhooks, err := dsquery.GetSubnetWebhooks(event.Subnet)
+	if err != nil || len(webhooks) == 0 {
+		return
+	}
+	cfg, ok := (*ctx).Value(constants.ConfigKey).(*configs.MainConfiguration)
+	if !ok {
+		return
+	}
+	// only deliver events this node accepted
+	stored, err := dsquery.GetEventById(event.ID, event.GetDataModelType())
+	if err != nil || stored.IsValid == nil || !*stored.IsValid || stored.Synced == nil || !*stored.Synced {
+		return
+	}
+	sender := webhook.NewSender(cfg.PublicKeySECPHex, func(body []byte) string {
+		_, sig := crypto.SignSECP(body, cfg.PrivateKeySECP)
+		return sig
+	})
+	for _, wh := range webhooks {
+		if !wh.Matches(stored) {
+			continue
+		}
+		body, err := json.Marshal(map[string]interface{}{
+			"wh":    wh.ID,
+			"event": stored,
+		})
+		if err != nil {
+			logger.Errorf("DispatchWebhooks: %v", err)
+			continue
+		}
+		go func(wh *entities.Webhook) {
+			err := sender.Send(*ctx, wh.Url, body, func(a webhook.Attempt) {
+				delivery := entities.WebhookDelivery{
+					Webhook:    wh.ID,
+					Event:      stored.ID,
+					Attempt:    a.Number,
+					StatusCode: a.StatusCode,
+					Error:      a.Error,
+					Timestamp:  a.Timestamp,
+					DeadLetter: a.Error != "" && a.Number == sender.MaxAttempts,
+				}
+				if err := dsquery.SaveWebhookDelivery(&delivery); err != nil {
+					logger.Errorf("SaveWebhookDelivery: %v", err)
+				}
+			})
+			if err != nil {
+				logger.Errorf("WebhookDeliveryFailed: %s, %v", wh.ID, err)
+			}
+		}(wh)
+	}
+}
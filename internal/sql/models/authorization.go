@@ -23,6 +23,7 @@ func (AuthorizationEvent) TableName() string {
 type AuthorizationState struct {
 	entities.Authorization `msgpack:",noinline"`
 	BaseModel
+	Expired bool `json:"expired" gorm:"-" msgpack:"-"`
 }
 func (AuthorizationState) TableName() string {
     return "authorization_states"
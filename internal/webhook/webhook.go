@@ -0,0 +1,171 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/mlayerprotocol/go-mlayer/pkg/log"
+)
+
+var logger = &log.Logger
+
+const (
+	NodeHeader      = "X-Mlayer-Node"
+	SignatureHeader = "X-Mlayer-Signature"
+	TimestampHeader = "X-Mlayer-Timestamp"
+)
+
+// Attempt records the outcome of one POST to a webhook url
+type Attempt struct {
+	Number     int    `json:"n"`
+	StatusCode int    `json:"code"`
+	Error      string `json:"err,omitempty"`
+	Timestamp  uint64 `json:"ts"`
+}
+
+type Sender struct {
+	Client      *http.Client
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Sign returns the signature of the request body
+	Sign func(body []byte) string
+	// Node is sent in the NodeHeader so receivers know which key to verify against
+	Node string
+}
+
+var ErrBlockedAddress = errors.New("webhook address is not public")
+
+// carrier-grade NAT range, not covered by net.IP.IsPrivate
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsPublicIP reports whether ip can be reached on the public internet
+func IsPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip))
+}
+
+// refuse connections to non public addresses, checked after resolution so rebinding the host does not help
+func publicOnly(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublicIP(ip) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+/*
+ValidateURL checks that rawURL is an http(s) url whose host only resolves to public addresses.
+Delivery checks the address again when connecting
+*/
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("invalid webhook url")
+	}
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if !IsPublicIP(ip) {
+			return ErrBlockedAddress
+		}
+	}
+	return nil
+}
+
+// NewClient returns an http client that can only connect to public addresses
+func NewClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: publicOnly}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would connect on our behalf and skip the address check
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: 10 * time.Second, Transport: transport}
+}
+
+func NewSender(node string, sign func(body []byte) string) *Sender {
+	return &Sender{
+		Client:      NewClient(),
+		MaxAttempts: 5,
+		BaseDelay:   time.Second,
+		MaxDelay:    time.Minute,
+		Sign:        sign,
+		Node:        node,
+	}
+}
+
+// Backoff returns how long to wait before the next attempt, doubling on every failure
+func (s *Sender) Backoff(attempt int) time.Duration {
+	delay := s.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > s.MaxDelay {
+		return s.MaxDelay
+	}
+	return delay
+}
+
+func (s *Sender) post(ctx context.Context, url string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(NodeHeader, s.Node)
+	req.Header.Set(TimestampHeader, fmt.Sprint(time.Now().UnixMilli()))
+	if s.Sign != nil {
+		req.Header.Set(SignatureHeader, s.Sign(body))
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+/*
+Send POSTs the body to url until it is accepted or MaxAttempts is reached.
+onAttempt is called after every try so callers can persist the history
+*/
+func (s *Sender) Send(ctx context.Context, url string, body []byte, onAttempt func(Attempt)) error {
+	var err error
+	for n := 1; n <= s.MaxAttempts; n++ {
+		var code int
+		code, err = s.post(ctx, url, body)
+		attempt := Attempt{Number: n, StatusCode: code, Timestamp: uint64(time.Now().UnixMilli())}
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+		if onAttempt != nil {
+			onAttempt(attempt)
+		}
+		if err == nil {
+			return nil
+		}
+		logger.Debugf("WebhookDeliveryError: %s, attempt %d, %v", url, n, err)
+		if n == s.MaxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.Backoff(n)):
+		}
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSendRetriesUntilAccepted(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get(SignatureHeader) != "signed" || r.Header.Get(NodeHeader) != "node" {
+			t.Errorf("missing signature headers")
+		}
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender := NewSender("node", func(body []byte) string { return "signed" })
+	sender.Client = server.Client()
+	sender.BaseDelay = time.Millisecond
+	attempts := []Attempt{}
+	err := sender.Send(context.Background(), server.URL, []byte(`{}`), func(a Attempt) {
+		attempts = append(attempts, a)
+	})
+	if err != nil {
+		t.Fatalf("expected delivery, got %v", err)
+	}
+	if len(attempts) != 3 || attempts[0].StatusCode != http.StatusServiceUnavailable || attempts[2].Error != "" {
+		t.Fatalf("unexpected attempts %+v", attempts)
+	}
+}
+
+func TestSendGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	sender := NewSender("node", nil)
+	sender.Client = server.Client()
+	sender.BaseDelay = time.Millisecond
+	sender.MaxAttempts = 2
+	count := 0
+	err := sender.Send(context.Background(), server.URL, []byte(`{}`), func(a Attempt) { count++ })
+	if err == nil || count != 2 {
+		t.Fatalf("expected failure after 2 attempts, got %v after %d", err, count)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	sender := NewSender("node", nil)
+	if sender.Backoff(1) != time.Second || sender.Backoff(3) != 4*time.Second || sender.Backoff(20) != time.Minute {
+		t.Fatalf("unexpected backoff")
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	sender := NewSender("node", nil)
+	sender.MaxAttempts = 1
+	err := sender.Send(context.Background(), server.URL, []byte(`{}`), nil)
+	if !errors.Is(err, ErrBlockedAddress) || calls != 0 {
+		t.Fatalf("expected loopback delivery to be blocked, got %v after %d calls", err, calls)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	for _, u := range []string{"http://127.0.0.1/hook", "http://169.254.169.254/latest", "https://10.1.2.3", "http://[::1]:8080", "http://localhost", "ftp://example.com"} {
+		if ValidateURL(u) == nil {
+			t.Errorf("expected %s to be rejected", u)
+		}
+	}
+	for _, ip := range []string{"8.8.8.8", "2606:4700::1111"} {
+		if !IsPublicIP(net.ParseIP(ip)) {
+			t.Errorf("expected %s to be public", ip)
+		}
+	}
+}
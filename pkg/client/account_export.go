@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+	"github.com/mlayerprotocol/go-mlayer/internal/service"
+)
+
+type accountExporter struct {
+	encoder *json.Encoder
+	seen    map[string]bool
+}
+
+/*
+write encodes state once. States are told apart by the event that produced them,
+or by their own id when the event is not known
+*/
+func (e *accountExporter) write(model entities.EntityModel, id string, state interface{}, eventPath entities.EventPath) error {
+	key := string(model) + "/e/" + eventPath.ID
+	if eventPath.ID == "" {
+		key = string(model) + "/id/" + id
+	}
+	if e.seen[key] {
+		return nil
+	}
+	e.seen[key] = true
+	record := entities.AccountExportRecord{Model: model, State: state}
+	if eventPath.ID != "" {
+		event, err := dsquery.GetEventById(eventPath.ID, model)
+		if err != nil && !dsquery.IsErrorNotFound(err) {
+			return err
+		}
+		record.Event = event
+	}
+	return e.encoder.Encode(record)
+}
+
+/*
+Validate the export request and write every authorization, subnet, topic,
+subscription and message of the account to w as JSON lines with the events that produced them
+*/
+func ExportAccount(req entities.AccountExportRequest, ctx *context.Context, w io.Writer) error {
+	cfg, _ := (*ctx).Value(constants.ConfigKey).(*configs.MainConfiguration)
+	if err := service.ValidateAccountExportRequest(&req, cfg); err != nil {
+		return err
+	}
+	exporter := accountExporter{encoder: json.NewEncoder(w), seen: map[string]bool{}}
+	all := &dsquery.QueryLimit{}
+
+	auths, err := dsquery.GetAccountAuthorizations(entities.Authorization{Account: req.Account}, all, nil)
+	if err != nil && !dsquery.IsErrorNotFound(err) {
+		return err
+	}
+	subnetIds := []string{}
+	for _, auth := range auths {
+		if err := exporter.write(entities.AuthModel, auth.ID, auth, auth.Event); err != nil {
+			return err
+		}
+		subnetIds = append(subnetIds, auth.Subnet)
+	}
+
+	subnets, err := dsquery.GetAccountSubnets(req.Account, *all)
+	if err != nil && !dsquery.IsErrorNotFound(err) {
+		return err
+	}
+	for _, subnet := range subnets {
+		if err := exporter.write(entities.SubnetModel, subnet.ID, subnet, subnet.Event); err != nil {
+			return err
+		}
+		subnetIds = append(subnetIds, subnet.ID)
+	}
+
+	for _, subnet := range subnetIds {
+		topics, err := dsquery.GetAccountTopics(entities.Topic{Subnet: subnet, Account: req.Account}, all, nil)
+		if err != nil && !dsquery.IsErrorNotFound(err) {
+			return err
+		}
+		for _, topic := range topics {
+			if err := exporter.write(entities.TopicModel, topic.ID, topic, topic.Event); err != nil {
+				return err
+			}
+		}
+	}
+
+	// pending, banned and left subscriptions are the accounts data too
+	subs, err := dsquery.GetSubscriberSubscriptions(req.Account, all)
+	if err != nil && !dsquery.IsErrorNotFound(err) {
+		return err
+	}
+	for _, sub := range subs {
+		if err := exporter.write(entities.SubscriptionModel, sub.ID, sub, sub.Event); err != nil {
+			return err
+		}
+	}
+
+	for _, filter := range []entities.Message{{Sender: req.Account}, {Receiver: req.Account}} {
+		messages, err := dsquery.GetMessages(filter, all, nil)
+		if err != nil && !dsquery.IsErrorNotFound(err) {
+			return err
+		}
+		for _, message := range messages {
+			if err := exporter.write(entities.MessageModel, message.ID, message, message.Event); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mlayerprotocol/go-mlayer/entities"
+)
+
+func TestAccountExporterKeepsStatesWithoutEvents(t *testing.T) {
+	var out bytes.Buffer
+	exporter := accountExporter{encoder: json.NewEncoder(&out), seen: map[string]bool{}}
+	for _, id := range []string{"t1", "t2", "t1"} {
+		if err := exporter.write(entities.TopicModel, id, entities.Topic{ID: id}, entities.EventPath{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 2 {
+		t.Fatalf("expected one line per topic, got %d:\n%s", lines, out.String())
+	}
+}
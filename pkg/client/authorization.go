@@ -2,9 +2,11 @@ package client
 
 import (
 	"encoding/json"
+	"slices"
 	"time"
 
 	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
 	"github.com/mlayerprotocol/go-mlayer/configs"
 	"github.com/mlayerprotocol/go-mlayer/entities"
 	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
@@ -24,7 +26,8 @@ func ValidateAuthPayload(cfg *configs.MainConfiguration, payload entities.Client
 	}
 	
 	payload.Data = authData
-	if uint64(*authData.Timestamp) == 0 || uint64(*authData.Timestamp) > uint64(time.Now().UnixMilli())+15000 || uint64(*authData.Timestamp) < uint64(time.Now().UnixMilli())-15000 {
+	// proposals were checked for freshness when first submitted
+	if (uint64(*authData.Timestamp) == 0 || uint64(*authData.Timestamp) > uint64(time.Now().UnixMilli())+15000 || uint64(*authData.Timestamp) < uint64(time.Now().UnixMilli())-15000) && !service.IsPendingProposal(&payload, *authData.Timestamp) {
 		return nil, nil, apperror.BadRequest("Invalid event timestamp")
 	}
 	logger.Debugf("CurrentStateDD: %+v", payload.Data)
@@ -88,7 +91,105 @@ func GetAuthorizations(auth *entities.Authorization) (*[]models.AuthorizationSta
 		return &authState, err
 	}
 	for _, auth := range auths {
-		authState = append(authState, models.AuthorizationState{Authorization: *auth})
+		authState = append(authState, models.AuthorizationState{Authorization: *auth, Expired: service.IsAuthorizationExpired(auth)})
 	}
 	return &authState, nil
 }
+
+func GetAuthorizationHistory(auth *entities.Authorization) (*[]models.AuthorizationState, error) {
+	authStates := []models.AuthorizationState{}
+	auths, err := dsquery.GetAuthorizationHistory(*auth, dsquery.DefaultQueryLimit)
+	if err != nil {
+		if dsquery.IsErrorNotFound(err) {
+			return &authStates, nil
+		}
+		return &authStates, err
+	}
+	for _, auth := range auths {
+		authStates = append(authStates, models.AuthorizationState{Authorization: *auth})
+	}
+	return &authStates, nil
+}
+
+type AgentTopics struct {
+	Subnet    string            `json:"snet"`
+	Agent     string            `json:"agt"`
+	AllTopics bool              `json:"all"`
+	Topics    []*entities.Topic `json:"topics"`
+}
+
+/*
+List the topics an agent is authorized to act on within a subnet
+*/
+func GetAgentTopics(subnet string, agent string) (*AgentTopics, error) {
+	result := AgentTopics{Subnet: subnet, Agent: agent, Topics: []*entities.Topic{}}
+	auths, err := dsquery.GetAgentAuthorizationStates(subnet, entities.AddressFromString(agent).ToDeviceString(), dsquery.QueryLimit{})
+	if err != nil && !dsquery.IsErrorNotFound(err) {
+		return nil, err
+	}
+	topicIds := []string{}
+	for _, auth := range auths {
+		if auth.Priviledge == nil || *auth.Priviledge < constants.MemberPriviledge {
+			continue
+		}
+		if auth.TopicIds == "" || auth.TopicIds == "*" {
+			result.AllTopics = true
+			break
+		}
+		topicIds = append(topicIds, auth.TopicIdList()...)
+	}
+	if result.AllTopics {
+		topics, err := dsquery.GetAccountTopics(entities.Topic{Subnet: subnet}, &dsquery.QueryLimit{}, nil)
+		if err != nil && !dsquery.IsErrorNotFound(err) {
+			return nil, err
+		}
+		result.Topics = append(result.Topics, topics...)
+		return &result, nil
+	}
+	for _, id := range topicIds {
+		if slices.ContainsFunc(result.Topics, func(t *entities.Topic) bool { return t.ID == id }) {
+			continue
+		}
+		topic, err := dsquery.GetTopicById(id)
+		if err != nil {
+			if dsquery.IsErrorNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		result.Topics = append(result.Topics, topic)
+	}
+	return &result, nil
+}
+
+type AuthorizationChain struct {
+	Chain []*entities.Authorization `json:"chain"`
+	Valid bool                      `json:"valid"`
+	Error string                    `json:"err,omitempty"`
+}
+
+/*
+Return the delegation chain from an agent's authorization back to the account
+*/
+func GetAgentAuthorizationChain(subnet string, agent string) (*AuthorizationChain, error) {
+	auths, err := dsquery.GetAgentAuthorizationStates(subnet, entities.AddressFromString(agent).ToDeviceString(), dsquery.QueryLimit{Limit: 1})
+	if err != nil && !dsquery.IsErrorNotFound(err) {
+		return nil, err
+	}
+	if len(auths) == 0 {
+		return nil, apperror.NotFound("Authorization not found")
+	}
+	subnetState, err := dsquery.GetSubnetStateById(subnet)
+	if err != nil {
+		if dsquery.IsErrorNotFound(err) {
+			return nil, apperror.NotFound("Subnet not found")
+		}
+		return nil, err
+	}
+	chain, err := service.GetAuthorizationChain(auths[0], subnetState)
+	result := AuthorizationChain{Chain: chain, Valid: err == nil}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return &result, nil
+}
@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/service"
+)
+
+const MaxBatchSize = service.MaxBatchSize
+
+type BatchItemResult struct {
+	Index int             `json:"i"`
+	Event *entities.Event `json:"e,omitempty"`
+	Error string          `json:"err,omitempty"`
+}
+
+type BatchResult struct {
+	Applied bool              `json:"applied"`
+	Items   []BatchItemResult `json:"items"`
+}
+
+// fail marks the item at index as the cause of the batch being rejected and every other item as not applied
+func (r *BatchResult) fail(index int, err error) *BatchResult {
+	for i := range r.Items {
+		r.Items[i].Event = nil
+		if i == index || index < 0 {
+			r.Items[i].Error = err.Error()
+		} else {
+			r.Items[i].Error = "not applied"
+		}
+	}
+	return r
+}
+
+// parsePayloadData decodes the data of a payload into the entity of its event type
+func parsePayloadData(payload *entities.ClientPayload) error {
+	switch entities.GetModelTypeFromEventType(constants.EventType(payload.EventType)) {
+	case entities.SubnetModel:
+		parseEntity(entities.Subnet{}, payload)
+	case entities.AuthModel:
+		parseEntity(entities.Authorization{}, payload)
+	case entities.TopicModel:
+		parseEntity(entities.Topic{}, payload)
+	case entities.SubscriptionModel:
+		parseEntity(entities.Subscription{}, payload)
+	case entities.MessageModel:
+		parseEntity(entities.Message{}, payload)
+	case entities.WalletModel:
+		if entities.IsTokenEvent(payload.EventType) {
+			parseEntity(entities.TokenTransaction{}, payload)
+		} else {
+			parseEntity(entities.Wallet{}, payload)
+		}
+	default:
+		return apperror.BadRequest(fmt.Sprintf("Unknown event type %d", payload.EventType))
+	}
+	return nil
+}
+
+// parseBatchPayload decodes the data of a batched payload, which can not be a subnet or authorization event
+func parseBatchPayload(payload *entities.ClientPayload) error {
+	switch entities.GetModelTypeFromEventType(constants.EventType(payload.EventType)) {
+	case entities.SubnetModel, entities.AuthModel:
+		// subnet and authorization events are signed by the account and may need approvals
+		return apperror.BadRequest(fmt.Sprintf("Event type %d can not be batched", payload.EventType))
+	}
+	return parsePayloadData(payload)
+}
+
+/*
+CreateEventBatch validates and applies the payloads of the batch in order, all-or-nothing.
+Each payload is validated against the state the earlier payloads of the batch staged, so it can depend on them.
+Rate limit tokens, message indexes and nonces taken by a rejected batch are given back
+*/
+func CreateEventBatch(batch entities.ClientPayloadBatch, ctx *context.Context) (*BatchResult, error) {
+	cfg, _ := (*ctx).Value(constants.ConfigKey).(*configs.MainConfiguration)
+	if len(batch.Payloads) == 0 {
+		return nil, apperror.BadRequest("Batch has no payloads")
+	}
+	if len(batch.Payloads) > MaxBatchSize {
+		return nil, apperror.BadRequest(fmt.Sprintf("Batch can not have more than %d payloads", MaxBatchSize))
+	}
+	if string(batch.ChainId) != string(cfg.ChainId) {
+		return nil, apperror.Forbidden("Invalid chain Id")
+	}
+	// the batch hash covers its timestamp, so a signed batch can only be submitted while it is fresh
+	if batch.Timestamp == 0 || batch.Timestamp > uint64(time.Now().UnixMilli())+15000 || batch.Timestamp < uint64(time.Now().UnixMilli())-15000 {
+		return nil, apperror.BadRequest("Batch timestamp is missing or not recent")
+	}
+	result := &BatchResult{Items: make([]BatchItemResult, len(batch.Payloads))}
+	for i := range batch.Payloads {
+		result.Items[i].Index = i
+		if err := parseBatchPayload(&batch.Payloads[i]); err != nil {
+			return result.fail(i, err), nil
+		}
+		if batch.Payloads[i].Subnet != batch.Payloads[0].Subnet {
+			return result.fail(i, apperror.BadRequest("Batched payloads must belong to one subnet")), nil
+		}
+	}
+	agent, err := batch.GetSigner()
+	if err != nil || agent == "" {
+		return nil, apperror.Unauthorized("Invalid batch signature")
+	}
+	hash, err := batch.Hash()
+	if err != nil {
+		return nil, apperror.BadRequest(err.Error())
+	}
+
+	eventBatch, err := service.NewEventBatch(ctx, batch.Payloads[0].Subnet, hash, len(batch.Payloads))
+	if err != nil {
+		return nil, apperror.Internal(err.Error())
+	}
+	defer eventBatch.Discard()
+	// events are handled by reference, so the slice must not grow past its capacity
+	events := make([]entities.Event, 0, len(batch.Payloads))
+	reserved := 0
+	rejected := func(index int, err error) *BatchResult {
+		for i := len(events) - 1; i >= 0; i-- {
+			service.RefundRateLimit(&events[i].Payload)
+			releaseMessageIndex(&events[i])
+		}
+		for i := 0; i < reserved; i++ {
+			service.ReleasePayloadNonce(&events[i].Payload)
+		}
+		return result.fail(index, err)
+	}
+	for i, payload := range batch.Payloads {
+		model, err := prepareEvent(payload, eventBatch.Context(), false)
+		if err != nil {
+			return rejected(i, err), nil
+		}
+		event, ok := model.(entities.Event)
+		if !ok {
+			return rejected(i, apperror.BadRequest("Payloads that need approvals can not be batched")), nil
+		}
+		events = append(events, event)
+		if event.Payload.Agent != agent {
+			return rejected(i, apperror.Unauthorized("Payload is not signed by the batch agent")), nil
+		}
+		if err := service.ReservePayloadNonce(&event.Payload); err != nil {
+			return rejected(i, err), nil
+		}
+		reserved++
+		if err := eventBatch.Handle(&events[len(events)-1]); err != nil {
+			return rejected(i, err), nil
+		}
+	}
+	if err := eventBatch.Commit(); err != nil {
+		return rejected(-1, err), nil
+	}
+	for i := range events {
+		result.Items[i].Event = &events[i]
+	}
+	result.Applied = true
+	return result, nil
+}
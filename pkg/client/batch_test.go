@@ -0,0 +1,28 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+)
+
+// a signed batch can only be submitted while its timestamp is recent, so a captured one can not be replayed later
+func TestCreateEventBatchRejectsStaleTimestamp(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constants.ConfigKey, &configs.MainConfiguration{ChainId: "84532"})
+	now := uint64(time.Now().UnixMilli())
+	for name, ts := range map[string]uint64{"missing": 0, "old": now - 60000, "future": now + 60000} {
+		batch := entities.ClientPayloadBatch{
+			Payloads:  []entities.ClientPayload{{Subnet: "s1", EventType: uint16(constants.SendMessageEvent)}},
+			Timestamp: ts, ChainId: "84532",
+		}
+		_, err := CreateEventBatch(batch, &ctx)
+		if err == nil || err.Error() != apperror.BadRequest("Batch timestamp is missing or not recent").Error() {
+			t.Errorf("%s: expected the batch timestamp to be rejected, got %v", name, err)
+		}
+	}
+}
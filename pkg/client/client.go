@@ -126,6 +126,9 @@ func ValidateClientPayload(
 	if *subnet.Status ==  0 {
 		return nil, nil, apperror.Forbidden("Subnet is disabled")
 	}
+	if err := service.ValidatePayloadNonce(payload); err != nil {
+		return nil, &agent, err
+	}
 
 
 	// check if device is authorized
@@ -147,22 +150,20 @@ func ValidateClientPayload(
 				Agent: agent,
 			}
 			
-			authDatas, err := dsquery.GetAccountAuthorizations(filter, dsquery.DefaultQueryLimit, nil)
-			if err != nil {
+			currentAuth, err := dsquery.GetCurrentAuthorization(filter, nil)
+			if err != nil && !dsquery.IsErrorNotFound(err) {
 				// if err == gorm.ErrRecordNotFound {
 				// 	return nil, nil
 				// }
 				logger.Errorf("GetAuthError: %v", err)
 				return nil, &agent, err
 			} else {
-				if len(authDatas) == 0 {
+				if currentAuth == nil {
 					return nil, &agent, apperror.Unauthorized("agent not authorized")
 				}
-				for _, a := range authDatas {
-					logger.Debugf("AuthStatesss::: %s", a.Event.ID)
-				}
+				logger.Debugf("AuthStatesss::: %s", currentAuth.Event.ID)
 				
-				authData = models.AuthorizationState{Authorization: *authDatas[0]};
+				authData = models.AuthorizationState{Authorization: *currentAuth};
 				logger.Debugf("New Event for Agent/Device %+v, %d, %d", authData, *authData.Duration , *authData.Timestamp)
 				if *authData.Duration != 0 && (*authData.Duration + *authData.Timestamp) < uint64(time.Now().UnixMilli()) {
 					return nil, &agent,  fmt.Errorf("invalid authdata")
@@ -0,0 +1,27 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+)
+
+// stateEntityModel parses the model of an entity that is updated by events
+func stateEntityModel(model string) (entities.EntityModel, error) {
+	switch m := entities.EntityModel(model); m {
+	case entities.AuthModel, entities.TopicModel, entities.SubscriptionModel, entities.SubnetModel, entities.WalletModel:
+		return m, nil
+	}
+	return "", apperror.BadRequest(fmt.Sprintf("Invalid model %s", model))
+}
+
+// GetEntityConflicts lists the events that lost a conflicting update to the entity, most recent first
+func GetEntityConflicts(model string, id string, limits *dsquery.QueryLimit) ([]entities.EventConflict, error) {
+	m, err := stateEntityModel(model)
+	if err != nil {
+		return nil, err
+	}
+	return dsquery.GetEntityConflicts(m, id, limits)
+}
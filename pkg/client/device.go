@@ -0,0 +1,126 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/common/utils"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+	"github.com/mlayerprotocol/go-mlayer/internal/service"
+)
+
+// AccountDevice is an agent authorized by an account in one subnet
+type AccountDevice struct {
+	Agent         entities.DeviceString            `json:"agt"`
+	Subnet        string                           `json:"snet"`
+	Grantor       entities.DIDString               `json:"gr"`
+	Priviledge    constants.AuthorizationPrivilege `json:"privi"`
+	TopicIds      string                           `json:"topIds"`
+	ExpiresAt     uint64                           `json:"expAt"`
+	Expired       bool                             `json:"expired"`
+	LastSeen      uint64                           `json:"lastSeen"`
+	LastEvent     *entities.EventPath              `json:"lastE,omitempty"`
+	Authorization entities.EventPath               `json:"authE"`
+}
+
+/*
+List every agent the account has authorized across subnets.
+Revoked agents are left out and the last seen time comes from the agents latest stored event
+*/
+func GetAccountDevices(account string) ([]AccountDevice, error) {
+	devices := []AccountDevice{}
+	auths, err := dsquery.GetAccountAuthorizations(entities.Authorization{Account: entities.AddressFromString(account).ToDIDString()}, &dsquery.QueryLimit{}, nil)
+	if err != nil && !dsquery.IsErrorNotFound(err) {
+		return devices, err
+	}
+	latest := map[string]*entities.Authorization{}
+	keys := []string{}
+	for _, auth := range auths {
+		key := fmt.Sprintf("%s/%s", auth.Subnet, entities.AddressFromString(string(auth.Agent)).Addr)
+		current, ok := latest[key]
+		if !ok {
+			keys = append(keys, key)
+		}
+		if !ok || utils.SafePointerValue(auth.Timestamp, 0) > utils.SafePointerValue(current.Timestamp, 0) {
+			latest[key] = auth
+		}
+	}
+	for _, key := range keys {
+		auth := latest[key]
+		if auth.IsRevoked() {
+			continue
+		}
+		device := AccountDevice{
+			Agent:         auth.Agent,
+			Subnet:        auth.Subnet,
+			Grantor:       auth.Grantor,
+			Priviledge:    utils.SafePointerValue(auth.Priviledge, 0),
+			TopicIds:      auth.TopicIds,
+			ExpiresAt:     auth.ExpiresAt(),
+			Expired:       service.IsAuthorizationExpired(auth),
+			Authorization: auth.Event,
+		}
+		event, err := dsquery.GetAgentLastEvent(auth.Subnet, auth.Agent)
+		if err != nil && !dsquery.IsErrorNotFound(err) {
+			return devices, err
+		}
+		if event == nil && auth.Event.ID != "" {
+			// events sent before the agent index existed are not indexed, fall back to when the agent was authorized
+			event, err = dsquery.GetEventFromPath(&auth.Event)
+			if err != nil && !dsquery.IsErrorNotFound(err) {
+				return devices, err
+			}
+		}
+		if event != nil {
+			device.LastSeen = event.Timestamp
+			device.LastEvent = entities.NewEventPath(event.Validator, entities.GetModelTypeFromEventType(constants.EventType(event.EventType)), event.ID)
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+type DeviceRevocation struct {
+	Agent  entities.DeviceString `json:"agt"`
+	Subnet string                `json:"snet"`
+	Event  interface{}           `json:"event,omitempty"`
+	Error  string                `json:"err,omitempty"`
+}
+
+/*
+Submit signed revocations for several of the accounts agents at once.
+Each payload is processed on its own so one bad revocation does not block the rest
+*/
+func (p *ClientRequestProcessor) RevokeAccountDevices(account string, payloads []entities.ClientPayload) ([]DeviceRevocation, error) {
+	results := []DeviceRevocation{}
+	if len(payloads) == 0 {
+		return results, apperror.BadRequest("No revocations provided")
+	}
+	accountAddr := entities.AddressFromString(account).Addr
+	for _, payload := range payloads {
+		if payload.EventType != uint16(constants.UnauthorizationEvent) {
+			return results, apperror.BadRequest(fmt.Sprintf("Invalid revocation event type %d", payload.EventType))
+		}
+		if !strings.EqualFold(entities.AddressFromString(string(payload.Account)).Addr, accountAddr) {
+			return results, apperror.Forbidden("Revocation belongs to another account")
+		}
+	}
+	for _, payload := range payloads {
+		auth := entities.Authorization{}
+		d, _ := json.Marshal(payload.Data)
+		json.Unmarshal(d, &auth)
+		result := DeviceRevocation{Agent: auth.Agent, Subnet: auth.Subnet}
+		event, err := p.Process(WriteAuthorizationRequest, nil, payload)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Event = event
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ipfs/go-datastore"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+	"github.com/mlayerprotocol/go-mlayer/pkg/core/ds"
+)
+
+const (
+	deviceAccount = entities.DIDString("did:0x8f6b2a1e3c4d5e6f708192a3b4c5d6e7f8091a2b")
+	devicePhone   = entities.DeviceString("did:0x1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d")
+	deviceLaptop  = entities.DeviceString("did:0x2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e")
+	deviceTablet  = entities.DeviceString("did:0x3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f")
+)
+
+// withClientStores points the state and event stores at empty stores for the test
+func withClientStores(t *testing.T) {
+	state, err := ds.NewDatastore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := ds.NewDatastore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousState, previousEvents := stores.StateStore, stores.EventStore
+	stores.StateStore, stores.EventStore = state, events
+	t.Cleanup(func() {
+		stores.StateStore, stores.EventStore = previousState, previousEvents
+		state.Close()
+		events.Close()
+	})
+}
+
+func authorizeDevice(t *testing.T, agent entities.DeviceString, subnet string, privilege constants.AuthorizationPrivilege, eventId string, timestamp uint64) {
+	t.Helper()
+	_, err := dsquery.CreateAuthorizationState(&entities.Authorization{
+		ID: "auth-" + eventId, Account: deviceAccount, Agent: agent, Subnet: subnet, Grantor: deviceAccount, Priviledge: &privilege,
+		Timestamp: &timestamp, Event: entities.EventPath{EntityPath: entities.EntityPath{Model: entities.AuthModel, ID: eventId}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func putDeviceEvent(t *testing.T, event entities.Event, indexed bool) {
+	t.Helper()
+	ctx := context.Background()
+	if err := stores.EventStore.Put(ctx, datastore.NewKey(event.DataKey()), event.MsgPack()); err != nil {
+		t.Fatal(err)
+	}
+	if indexed {
+		if err := stores.EventStore.Put(ctx, datastore.NewKey(event.AgentEventKey()), []byte(event.ID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGetAccountDevices(t *testing.T) {
+	withClientStores(t)
+	authorizeDevice(t, devicePhone, "s1", constants.MemberPriviledge, "e1", 1000)
+	authorizeDevice(t, deviceLaptop, "s1", constants.MemberPriviledge, "e2", 1000)
+	authorizeDevice(t, deviceTablet, "s2", constants.MemberPriviledge, "e3", 1000)
+	authorizeDevice(t, deviceTablet, "s2", constants.UnauthorizedPriviledge, "e4", 2000)
+	// the latest event wins by milliseconds, even within the same second
+	for id, timestamp := range map[string]uint64{"m1": 1705392177900, "m2": 1705392177100} {
+		putDeviceEvent(t, entities.Event{
+			ID: id, Subnet: "s1", Timestamp: timestamp, EventType: uint16(constants.CreateTopicEvent),
+			Payload: entities.ClientPayload{Agent: devicePhone, Data: entities.Topic{}},
+		}, true)
+	}
+	// the laptop never sent an event, it was last seen when it was authorized
+	granted, duration, privilege := uint64(1000), uint64(0), constants.MemberPriviledge
+	putDeviceEvent(t, entities.Event{
+		ID: "e2", Subnet: "s1", Timestamp: 1000, EventType: uint16(constants.AuthorizationEvent),
+		Payload: entities.ClientPayload{Data: entities.Authorization{Agent: deviceLaptop, Timestamp: &granted, Duration: &duration, Priviledge: &privilege}},
+	}, false)
+
+	devices, err := GetAccountDevices(string(deviceAccount))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[entities.DeviceString]AccountDevice{}
+	for _, device := range devices {
+		seen[device.Agent] = device
+	}
+	if len(devices) != 2 {
+		t.Fatalf("expected the revoked tablet to be left out, got %d devices", len(devices))
+	}
+	if phone := seen[devicePhone]; phone.LastSeen != 1705392177900 || phone.LastEvent == nil || phone.LastEvent.ID != "m1" {
+		t.Fatalf("expected the phone to be last seen at its latest event, got %d", phone.LastSeen)
+	}
+	if laptop := seen[deviceLaptop]; laptop.LastSeen != 1000 {
+		t.Fatalf("expected the laptop to be last seen when it was authorized, got %d", laptop.LastSeen)
+	}
+}
+
+func TestRevokeAccountDevices(t *testing.T) {
+	withClientStores(t)
+	cfg := &configs.MainConfiguration{OwnerAddress: common.HexToAddress("0x0000000000000000000000000000000000000001")}
+	ctx := context.WithValue(context.Background(), constants.ConfigKey, cfg)
+	p := &ClientRequestProcessor{Ctx: &ctx, Cfg: cfg}
+	revocation := func(account entities.DIDString, agent entities.DeviceString, eventType constants.EventType) entities.ClientPayload {
+		return entities.ClientPayload{Account: account, EventType: uint16(eventType), Subnet: "s1", Data: entities.Authorization{Agent: agent, Subnet: "s1"}}
+	}
+
+	if _, err := p.RevokeAccountDevices(string(deviceAccount), nil); err == nil {
+		t.Fatal("expected an empty request to be rejected")
+	}
+	if _, err := p.RevokeAccountDevices(string(deviceAccount), []entities.ClientPayload{
+		revocation(deviceAccount, devicePhone, constants.UnauthorizationEvent),
+		revocation(deviceAccount, deviceLaptop, constants.AuthorizationEvent),
+	}); err == nil {
+		t.Fatal("expected a request with a grant to be rejected")
+	}
+	if _, err := p.RevokeAccountDevices(string(deviceAccount), []entities.ClientPayload{
+		revocation("did:0x4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a", devicePhone, constants.UnauthorizationEvent),
+	}); err == nil {
+		t.Fatal("expected a revocation of another account to be rejected")
+	}
+
+	// every revocation is processed on its own, the validator rejects both here
+	results, err := p.RevokeAccountDevices(string(deviceAccount), []entities.ClientPayload{
+		revocation(deviceAccount, devicePhone, constants.UnauthorizationEvent),
+		revocation(deviceAccount, deviceLaptop, constants.UnauthorizationEvent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Agent != devicePhone || results[1].Agent != deviceLaptop {
+		t.Fatalf("expected a result per revocation, got %+v", results)
+	}
+	for _, result := range results {
+		if result.Error == "" {
+			t.Fatalf("expected the revocation of %s to report its error", result.Agent)
+		}
+	}
+}
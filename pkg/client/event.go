@@ -36,8 +36,60 @@ type PaddedInt64 struct {
 }
 var messageVectors  sync.Map
 
+// releaseMessageIndex gives back the index of a message event that was not applied, when no later message took one
+func releaseMessageIndex(event *entities.Event) {
+	message, ok := event.Payload.Data.(entities.Message)
+	if !ok || event.Index == 0 {
+		return
+	}
+	if vector, ok := messageVectors.Load(event.VectorKey(message.Topic)); ok {
+		atomic.CompareAndSwapInt64(&vector.(*PaddedInt64).value, event.Index, event.Index-1)
+	}
+}
+
+// nextMessageIndex returns the index of a message event in its topic vector. Simulations read the next index without taking it
+func nextMessageIndex(event *entities.Event, simulate bool) (int64, error) {
+	vecKey := event.VectorKey(event.Payload.Data.(entities.Message).Topic)
+	vectorInterface, loaded := messageVectors.LoadOrStore(vecKey, &PaddedInt64{})
+	vector := vectorInterface.(*PaddedInt64)
+	if !loaded {
+		// load it from your local db
+		d, err := stores.NetworkStatsStore.Get(context.Background(), datastore.NewKey(vecKey))
+		if err != nil && !dsquery.IsErrorNotFound(err) {
+			return 0, err
+		}
+		i, err := strconv.Atoi(string(d))
+		if err != nil {
+			i = 0
+		}
+		atomic.StoreInt64(&vector.value, int64(i))
+	}
+	if simulate {
+		return atomic.LoadInt64(&vector.value) + 1, nil
+	}
+	return atomic.AddInt64(&vector.value, 1), nil
+}
+
 func CreateEvent(payload entities.ClientPayload, ctx *context.Context) (model any, err error) {
 	defer utils.TrackExecutionTime(time.Now(), "CreateEvent::")
+	model, err = prepareEvent(payload, ctx, false)
+	if event, ok := model.(entities.Event); ok && err == nil {
+		if err = service.ReservePayloadNonce(&event.Payload); err != nil {
+			service.RefundRateLimit(&event.Payload)
+			releaseMessageIndex(&event)
+			return nil, err
+		}
+		go service.HandleNewPubSubEvent(event, ctx)
+	}
+	return model, err
+}
+
+/*
+prepareEvent validates the payload and builds the signed event for it, or the proposal when it needs approvals.
+When simulating nothing is saved or consumed: no proposal is created, no rate limit tokens or message index are taken
+and the event is left unsigned
+*/
+func prepareEvent(payload entities.ClientPayload, ctx *context.Context, simulate bool) (model any, err error) {
 	cfg, _ := (*ctx).Value(constants.ConfigKey).(*configs.MainConfiguration)
 	stateDS, _ := (*ctx).Value(constants.ValidStateStore).(*ds.Datastore)
 	if !strings.EqualFold(utils.AddressToHex(payload.Validator), utils.AddressToHex(cfg.OwnerAddress.String())) {
@@ -47,7 +99,7 @@ func CreateEvent(payload entities.ClientPayload, ctx *context.Context) (model an
 	
 	var authState *models.AuthorizationState
 	var agent *entities.DeviceString
-	excludedEvents := []constants.EventType{constants.CreateSubnetEvent, constants.UpdateSubnetEvent, constants.DeleteSubnetEvent, constants.AuthorizationEvent}
+	excludedEvents := []constants.EventType{constants.CreateSubnetEvent, constants.UpdateSubnetEvent, constants.DeleteSubnetEvent, constants.TransferSubnetOwnershipEvent, constants.AcceptSubnetOwnershipEvent, constants.AuthorizationEvent, constants.UnauthorizationEvent, constants.RenewAuthorizationEvent}
 	if !slices.Contains(excludedEvents, constants.EventType(payload.EventType)) {
 		logger.Infof("ISNOTEXLUCDED: %d",  payload.EventType)
 		authState, agent, err = ValidateClientPayload(stateDS, &payload, true, cfg)
@@ -56,6 +108,14 @@ func CreateEvent(payload entities.ClientPayload, ctx *context.Context) (model an
 		if err != nil && err != gorm.ErrRecordNotFound && !dsquery.IsErrorNotFound(err)  {
 			return model, err
 		}
+		if authState != nil && authState.IsRevoked() {
+			return model, apperror.Unauthorized("Agent authorization revoked")
+		}
+		if authState != nil {
+			if err := service.ValidateDelegation(&authState.Authorization, nil); err != nil {
+				return model, err
+			}
+		}
 		if authState == nil || *authState.Authorization.Priviledge < constants.MemberPriviledge {
 			// agent not authorized
 			return model, apperror.Unauthorized("agent unauthorized to write in this subnet")
@@ -87,7 +147,7 @@ func CreateEvent(payload entities.ClientPayload, ctx *context.Context) (model an
 	logger.Debugf("authState****** 2: %v ", authState)
 	
 	switch payload.EventType {
-	case uint16(constants.AuthorizationEvent):
+	case uint16(constants.AuthorizationEvent), uint16(constants.UnauthorizationEvent), uint16(constants.RenewAuthorizationEvent):
 		// authData := entities.Authorization{}
 		// d, _ := json.Marshal(payload.Data)
 		// e := json.Unmarshal(d, &authData)
@@ -98,18 +158,21 @@ func CreateEvent(payload entities.ClientPayload, ctx *context.Context) (model an
 		// logger.Infof("NewRequest: %v",  "Authorization")
 		assocPrevEvent, assocAuthEvent, err = ValidateAuthPayload(cfg, payload)
 		logger.Infof("NewRequestProcessed: %v",  "Authorization")
+		if err == service.ErrApprovalsRequired && !simulate {
+			return ProposeSubnetEvent(payload, cfg)
+		}
 		if err != nil {
 			logger.Errorf("AuthDataVerificationError: %v", err)
 			return model, err
 		}
 		
 		
-	case uint16(constants.CreateTopicEvent), uint16(constants.UpdateNameEvent), uint16(constants.UpdateTopicEvent), uint16(constants.LeaveEvent):
+	case uint16(constants.CreateTopicEvent), uint16(constants.UpdateNameEvent), uint16(constants.UpdateTopicEvent), uint16(constants.LeaveEvent), uint16(constants.TransferTopicOwnershipEvent), uint16(constants.AcceptTopicOwnershipEvent):
 		
 		// if authState.Authorization.Priviledge < constants.AdminPriviledge {
 		// 	return nil, apperror.Forbidden("Agent not authorized to perform this action")
 		// }
-		assocPrevEvent, assocAuthEvent, err = ValidateTopicPayload(payload, authState)
+		assocPrevEvent, assocAuthEvent, err = ValidateTopicPayload(payload, authState, service.BatchTxn(ctx, entities.TopicModel))
 		// return nil, fmt.Errorf("just debugiing")
 		if err != nil {
 			return model, err
@@ -129,13 +192,16 @@ func CreateEvent(payload entities.ClientPayload, ctx *context.Context) (model an
 		// 	if err != nil {
 		// 		return nil, err
 		// 	}
-	case uint16(constants.CreateSubnetEvent), uint16(constants.UpdateSubnetEvent):
+	case uint16(constants.CreateSubnetEvent), uint16(constants.UpdateSubnetEvent), uint16(constants.TransferSubnetOwnershipEvent), uint16(constants.AcceptSubnetOwnershipEvent):
 		
 		// if authState.Authorization.Priviledge < constants.AdminPriviledge {
 		// 	return nil, apperror.Forbidden("Agent not authorized to perform this action")
 		// }
 		logger.Infof("ValidatingSubnetPayload: %v", payload)
 		assocPrevEvent, assocAuthEvent, err = ValidateSubnetPayload(payload, authState, ctx)
+		if err == service.ErrApprovalsRequired && !simulate {
+			return ProposeSubnetEvent(payload, cfg)
+		}
 		if err != nil {
 			logger.Errorf("InvalidSubnetPayload: %v", err)
 			return model, err
@@ -147,7 +213,12 @@ func CreateEvent(payload entities.ClientPayload, ctx *context.Context) (model an
 		// if authState.Authorization.Priviledge < constants.AdminPriviledge {
 		// 	return nil, apperror.Forbidden("Agent not authorized to perform this action")
 		// }
-		assocPrevEvent, assocAuthEvent, err = ValidateWalletPayload(payload, authState)
+		assocPrevEvent, assocAuthEvent, err = ValidateWalletPayload(payload, authState, service.BatchTxn(ctx, entities.WalletModel))
+		if err != nil {
+			return model, err
+		}
+	case uint16(constants.MintTokenEvent), uint16(constants.TransferTokenEvent), uint16(constants.BurnTokenEvent):
+		assocPrevEvent, assocAuthEvent, err = ValidateTokenPayload(payload, authState, service.BatchTxn(ctx, entities.WalletModel))
 		if err != nil {
 			return model, err
 		}
@@ -157,7 +228,7 @@ func CreateEvent(payload entities.ClientPayload, ctx *context.Context) (model an
 		}
 		
 		logger.Infof("ValidatingTopic...")
-		assocPrevEvent, assocAuthEvent, err = ValidateSubscriptionPayload(payload, authState, cfg)
+		assocPrevEvent, assocAuthEvent, err = ValidateSubscriptionPayload(payload, authState, cfg, service.BatchTxn(ctx, entities.SubscriptionModel))
 		
 		if err != nil {
 			logger.Debugf("SubscriptionError: %+v", err)
@@ -170,13 +241,35 @@ func CreateEvent(payload entities.ClientPayload, ctx *context.Context) (model an
 		// 	return nil, apperror.Forbidden("Agent not authorized to perform this action")
 		// }
 		
-		assocPrevEvent, assocAuthEvent, err = ValidateMessagePayload(payload, authState)
+		assocPrevEvent, assocAuthEvent, err = ValidateMessagePayload(payload, authState, service.BatchTxn(ctx, entities.SubscriptionModel))
 		if err != nil {
 			logger.Error("ERRRRRRR:::", err)
 			return model, err
 		}
 	default:
 	}
+	// rate limits are keyed by the agent and account, so tokens are only taken once the payload is authenticated
+	if eventPayloadType != entities.SubnetModel && payload.Subnet != "" {
+		if simulate {
+			err = service.PeekRateLimit(&payload)
+		} else {
+			err = service.CheckRateLimit(&payload)
+		}
+		if err != nil {
+			return model, err
+		}
+		if !simulate {
+			// the tokens are given back when no event is built for the payload
+			defer func() {
+				if err != nil {
+					service.RefundRateLimit(&payload)
+				}
+			}()
+		}
+		if err = service.ValidateSubnetBudget(ctx, payload.Subnet); err != nil {
+			return model, err
+		}
+	}
 	// logger.Debugf("UPDATINGSUBNE1: %v", err)
 	payloadHash, err := payload.GetHash()
 	
@@ -205,10 +298,11 @@ func CreateEvent(payload entities.ClientPayload, ctx *context.Context) (model an
 	if payload.EventType == uint16(constants.UpdateAvatarEvent) {
 		subnet = payload.Data.(entities.Subnet).ID
 	}
-	if payload.EventType == uint16(constants.AuthorizationEvent) {
+	if entities.GetModelTypeFromEventType(constants.EventType(payload.EventType)) == entities.AuthModel {
 		subnet = payload.Data.(entities.Authorization).Subnet
 	}
 	
+	payload.Version = entities.PayloadSchemaVersion(eventPayloadType)
 	event := entities.Event{
 		Payload:           payload,
 		Timestamp:         uint64(time.Now().UnixMilli()),
@@ -224,46 +318,23 @@ func CreateEvent(payload entities.ClientPayload, ctx *context.Context) (model an
 		Epoch: 				chain.NetworkInfo.CurrentEpoch.Uint64(),		
 		Validator:         entities.PublicKeyString(cfg.PublicKeyEDDHex),
 		Subnet: subnet,
+		Version: entities.EventSchemaVersion,
 	}
 	
 	if  uint16(constants.SendMessageEvent) == event.EventType {
-		vecKey := event.VectorKey(event.Payload.Data.(entities.Message).Topic)
-		vectorInterface, loaded := messageVectors.LoadOrStore(vecKey, &PaddedInt64{})
-		vector := vectorInterface.(*PaddedInt64)
-		// var  _err error
-		if !loaded {
-			// load it from your local db
-			// vector.once.Do(func() {
-				d, err := stores.NetworkStatsStore.Get(context.Background(), datastore.NewKey(vecKey))
-				if err != nil && !dsquery.IsErrorNotFound(err) {
-					return nil, err
-				}
-				i, err := strconv.Atoi(string(d))
-				if err != nil {
-					i = 0
-				}
-				// messageVectors[vecKey] = &v
-				atomic.StoreInt64(&vector.value, int64(i))
-			// })
+		if event.Index, err = nextMessageIndex(&event, simulate); err != nil {
+			return nil, err
 		}
-		// if _err != nil {
-		// 	return nil, _err
-		// }
-		logger.Infof("Vector")
-		event.Index = atomic.AddInt64(&vector.value, 1)
-		
-		// err := stores.NetworkStatsStore.Set(context.Background(), datastore.NewKey(vecKey), []byte(fmt.Sprint(vector.value)), true)
-		// if err != nil {
-		// 	return nil, err
-		// }
-		// defer func ()  {
-		// 	if err == nil {
-		// 		stores.EventStore.Get(context.Background(), datastore.NewKey(vecKey))
-		// 	}
-		// } ()
 	}
 
 	
+	if batch := service.ContextBatch(ctx); batch != nil {
+		// validators apply the events of a batch together once they have all of them
+		event.Batch = batch.Hash
+		event.BatchIndex = uint16(batch.Len())
+		event.BatchSize = uint16(batch.Size)
+	}
+
 	 logger.Debugf("NewEvent: %v, %v", event.ID, eventPayloadType)
 
 	b, err := event.EncodeBytes()
@@ -274,6 +345,9 @@ func CreateEvent(payload entities.ClientPayload, ctx *context.Context) (model an
 	// logger.Debugf("eventPayloadType 2: %s", eventPayloadType)
 
 	event.Hash = hex.EncodeToString(crypto.Sha256(b))
+	if simulate {
+		return event, nil
+	}
 	_, event.Signature = crypto.SignEDD(b, cfg.PrivateKeyEDD)
 	// err = dsquery.CreateEvent(&event, nil)
 	// 	if err != nil {
@@ -284,7 +358,6 @@ func CreateEvent(payload entities.ClientPayload, ctx *context.Context) (model an
 	if err != nil {
 		return model, err
 	}
-	go service.HandleNewPubSubEvent(event, ctx) 
 	
 	// dispatch to network
 
@@ -354,7 +427,7 @@ func GetEventByPath(eventHash string, eventType int) (model interface{}, err err
 		return event, nil
 
 	// switch uint16(eventType) {
-	// case uint16(constants.CreateTopicEvent), uint16(constants.UpdateNameEvent), uint16(constants.UpdateTopicEvent), uint16(constants.LeaveEvent):
+	// case uint16(constants.CreateTopicEvent), uint16(constants.UpdateNameEvent), uint16(constants.UpdateTopicEvent), uint16(constants.LeaveEvent), uint16(constants.TransferTopicOwnershipEvent), uint16(constants.AcceptTopicOwnershipEvent):
 	// 	event, err1 := dsquery.GetEventById(eventHash, entities.TopicModel)
 
 	// 	if err1 != nil {
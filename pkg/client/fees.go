@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/mlayerprotocol/go-mlayer/internal/service"
+)
+
+// GetFeeQuote prices `events` events (one when not set) and lists `history` past cycle prices
+func GetFeeQuote(ctx *context.Context, params map[string]interface{}) (*service.FeeQuote, error) {
+	events := uint64(1)
+	if v, ok := params["events"]; ok {
+		if n, err := strconv.ParseUint(fmt.Sprint(v), 10, 64); err == nil {
+			events = n
+		}
+	}
+	history := uint64(service.DefaultFeeHistoryCycles)
+	if v, ok := params["history"]; ok {
+		if n, err := strconv.ParseUint(fmt.Sprint(v), 10, 64); err == nil {
+			history = n
+		}
+	}
+	return service.QuoteEventFees(ctx, events, history)
+}
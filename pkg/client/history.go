@@ -0,0 +1,66 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+)
+
+// EntityChange is an event in the history of an entity and the state it produced
+type EntityChange struct {
+	Event       *entities.Event          `json:"e"`
+	State       any                      `json:"st,omitempty"`
+	Signer      string                   `json:"sign"`
+	Account     entities.DIDString       `json:"acct"`
+	Validator   entities.PublicKeyString `json:"val"`
+	BlockNumber uint64                   `json:"blk"`
+	Cycle       uint64                   `json:"cy"`
+	Epoch       uint64                   `json:"ep"`
+	// LostTo is the event this one lost a conflicting update to, its state never became current
+	LostTo string `json:"lostTo,omitempty"`
+}
+
+/*
+GetEntityHistory returns the changes made to an entity, most recent first.
+Changes that lost a conflict follow the change they lost to. Changes whose state is not on this node are returned without it
+*/
+func GetEntityHistory(model string, id string, limits *dsquery.QueryLimit) ([]EntityChange, error) {
+	m, err := stateEntityModel(model)
+	if err != nil {
+		return nil, err
+	}
+	events, lostTo, err := dsquery.GetEntityEvents(m, id, limits)
+	if err != nil {
+		if dsquery.IsErrorNotFound(err) {
+			return nil, apperror.NotFound(fmt.Sprintf("%s %s not found", model, id))
+		}
+		return nil, err
+	}
+	history := make([]EntityChange, len(events))
+	for i, event := range events {
+		// agents sign on behalf of accounts, subnet and some authorization events are signed by the account
+		signer := string(event.Payload.Agent)
+		if signer == "" {
+			signer = string(event.Payload.Account)
+		}
+		history[i] = EntityChange{
+			Event:       event,
+			Signer:      signer,
+			Account:     event.Payload.Account,
+			Validator:   event.Validator,
+			BlockNumber: event.BlockNumber,
+			Cycle:       event.Cycle,
+			Epoch:       event.Epoch,
+			LostTo:      lostTo[event.ID],
+		}
+		state, err := dsquery.GetStateFromEventPath(&entities.EventPath{EntityPath: entities.EntityPath{Model: m, ID: event.ID}})
+		if err == nil {
+			history[i].State = state
+		} else if !dsquery.IsErrorNotFound(err) {
+			logger.Errorf("GetStateFromEventPath: %v", err)
+		}
+	}
+	return history, nil
+}
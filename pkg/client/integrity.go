@@ -0,0 +1,15 @@
+package client
+
+import (
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/internal/service"
+)
+
+type VerifyRequest struct {
+	Refetch bool `json:"refetch"`
+}
+
+// VerifyEventStore checks the stored event graph and current states of the node
+func VerifyEventStore(cfg *configs.MainConfiguration, req VerifyRequest) (*service.IntegrityReport, error) {
+	return service.VerifyEventStore(cfg, req.Refetch)
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/ipfs/go-datastore"
 	"github.com/mlayerprotocol/go-mlayer/common/apperror"
 	"github.com/mlayerprotocol/go-mlayer/common/constants"
 	"github.com/mlayerprotocol/go-mlayer/common/utils"
@@ -100,7 +101,7 @@ func NewMessageService(mainCtx *context.Context) *MessageService {
 // 	return nil, errors.New("INVALID MESSAGE SIGNER")
 // }
 
-func ValidateMessagePayload(payload entities.ClientPayload, currentAuthState *models.AuthorizationState) (assocPrevEvent *entities.EventPath, assocAuthEvent *entities.EventPath, err error) {
+func ValidateMessagePayload(payload entities.ClientPayload, currentAuthState *models.AuthorizationState, txn *datastore.Txn) (assocPrevEvent *entities.EventPath, assocAuthEvent *entities.EventPath, err error) {
 	defer utils.TrackExecutionTime(time.Now(), "ValidateMessagePayload")
 	payloadData := entities.Message{}
 	d, _ := json.Marshal(payload.Data)
@@ -110,7 +111,7 @@ func ValidateMessagePayload(payload entities.ClientPayload, currentAuthState *mo
 	}
 	payload.Data = payloadData
 
-	topicData, err := dsquery.GetTopicById(payloadData.Topic)
+	topicData, err := dsquery.GetTopicByIdTxn(payloadData.Topic, txn)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -126,7 +127,7 @@ func ValidateMessagePayload(payload entities.ClientPayload, currentAuthState *mo
 	
 
 
-	subscription, err := service.ValidateMessageData(&payload, topicData)
+	subscription, err := service.ValidateMessageData(&payload, topicData, txn)
 	
 	
 	
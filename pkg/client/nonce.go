@@ -0,0 +1,38 @@
+package client
+
+import (
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/service"
+)
+
+/*
+AgentNonce is what an SDK needs to pick the nonce of its next payload.
+Nonce is the one the agents next payload for the account must carry, AgentNonce and AccountNonce
+are above every payload of the agent and of the account, for SDKs that keep one counter for either
+*/
+type AgentNonce struct {
+	Account      entities.DIDString    `json:"acct"`
+	Agent        entities.DeviceString `json:"agt"`
+	Nonce        uint64                `json:"nonce"`
+	AgentNonce   uint64                `json:"agtNonce"`
+	AccountNonce uint64                `json:"acctNonce"`
+}
+
+// GetNextNonce returns the nonces the agents next payload for the account can carry
+func GetNextNonce(account string, agent string) (*AgentNonce, error) {
+	next := AgentNonce{
+		Account: entities.AddressFromString(account).ToDIDString(),
+		Agent:   entities.AddressFromString(agent).ToDeviceString(),
+	}
+	var err error
+	if next.Nonce, err = service.NextAgentNonce(next.Account, next.Agent); err != nil {
+		return nil, err
+	}
+	if next.AgentNonce, err = service.NextDeviceNonce(next.Agent); err != nil {
+		return nil, err
+	}
+	if next.AccountNonce, err = service.NextAccountNonce(next.Account); err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/service"
+)
+
+func TestGetNextNonce(t *testing.T) {
+	withClientStores(t)
+	agent := entities.DeviceString("did:0x1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d")
+	payload := &entities.ClientPayload{Account: "did:0x00000000000000000000000000000000000000a1", Agent: agent, Nonce: 4}
+	if err := service.ReservePayloadNonce(payload); err != nil {
+		t.Fatal(err)
+	}
+	defer service.ReleasePayloadNonce(payload)
+	next, err := GetNextNonce(string(deviceAccount), string(agent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the agent used nonce 4 for another account only
+	if next.Nonce != 1 || next.AgentNonce != 5 || next.AccountNonce != 1 {
+		t.Fatalf("expected nonces 1, 5 and 1, got %+v", next)
+	}
+}
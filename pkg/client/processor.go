@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
 	"github.com/mlayerprotocol/go-mlayer/common/constants"
 	"github.com/mlayerprotocol/go-mlayer/common/utils"
 	"github.com/mlayerprotocol/go-mlayer/configs"
@@ -41,6 +42,15 @@ const (
 	BlockStatsRequest          = "READ:block-stats"
 	GetEventByTypeAndIdRequest = "READ:event/:type/:id"
 	GetMainStatsRequest        = "READ:main-stats"
+	GetAccountBalancesRequest  = "READ:subnets/:id/accounts/:acct/balances"
+	GetWalletTransactionsRequest = "READ:wallets/:id/accounts/:acct/transactions"
+	GetWalletSupplyRequest     = "READ:wallets/:id/supply"
+	WriteBatchRequest          = "WRITE:batch"
+	GetNextNonceRequest        = "READ:accounts/:acct/devices/:agent/nonce"
+	SimulateRequest            = "WRITE:simulate"
+	GetFeeQuoteRequest         = "READ:fees/quote"
+	GetEntityConflictsRequest  = "READ:conflicts/:model/:id"
+	GetEntityHistoryRequest    = "READ:history/:model/:id"
 )
 
 var requestPatterns = []RequestType{
@@ -69,6 +79,16 @@ var requestPatterns = []RequestType{
 	BlockStatsRequest,
 	GetEventByTypeAndIdRequest,
 	GetMainStatsRequest,
+
+	GetAccountBalancesRequest,
+	GetWalletTransactionsRequest,
+	GetWalletSupplyRequest,
+	WriteBatchRequest,
+	GetNextNonceRequest,
+	SimulateRequest,
+	GetFeeQuoteRequest,
+	GetEntityConflictsRequest,
+	GetEntityHistoryRequest,
 }
 
 type ClientRequestProcessor struct {
@@ -240,6 +260,25 @@ func (p *ClientRequestProcessor) Process(requestPath RequestType, params map[str
 		}
 		cpl.Data = data
 		return CreateEvent(cpl, p.Ctx)
+	case GetNextNonceRequest:
+		return GetNextNonce(fmt.Sprint(params["acct"]), fmt.Sprint(params["agent"]))
+	case GetFeeQuoteRequest:
+		return GetFeeQuote(p.Ctx, params)
+	case GetEntityConflictsRequest:
+		return GetEntityConflicts(fmt.Sprint(params["model"]), fmt.Sprint(params["id"]), QueryLimitFromParams(params))
+	case GetEntityHistoryRequest:
+		return GetEntityHistory(fmt.Sprint(params["model"]), fmt.Sprint(params["id"]), QueryLimitFromParams(params))
+	case SimulateRequest:
+		return SimulateEvent(payload.(entities.ClientPayload), p.Ctx)
+	case WriteBatchRequest:
+		// the batch is carried as the data of the client payload
+		cpl := payload.(entities.ClientPayload)
+		batch := entities.ClientPayloadBatch{}
+		d, _ := json.Marshal(cpl.Data)
+		if e := json.Unmarshal(d, &batch); e != nil {
+			return nil, apperror.BadRequest(e.Error())
+		}
+		return CreateEventBatch(batch, p.Ctx)
 	// case GetSubscriptionByIdRequest:
 	// 	return dsquery.GetSubsc(params["id"].(string))
 	case GetTopicSubscribersRequest:
@@ -326,7 +365,13 @@ func (p *ClientRequestProcessor) Process(requestPath RequestType, params map[str
 		}
 		return event, nil
 	case FindSubnetsRequest:
-
+		if params["acct"] == nil || fmt.Sprint(params["acct"]) == "" {
+			filter, sortByActivity, limits, err := SubnetQueryFromParams(params)
+			if err != nil {
+				return nil, err
+			}
+			return DiscoverSubnets(filter, sortByActivity, limits)
+		}
 		b, parseError := json.Marshal(params)
 		if parseError != nil {
 			return nil, parseError
@@ -343,6 +388,12 @@ func (p *ClientRequestProcessor) Process(requestPath RequestType, params map[str
 			return nil, err
 		}
 		return models.SubnetState{Subnet: *subnet}, nil
+	case GetAccountBalancesRequest:
+		return GetAccountBalances(params["id"].(string), params["acct"].(string))
+	case GetWalletTransactionsRequest:
+		return GetWalletTransactions(params["id"].(string), params["acct"].(string), QueryLimitFromParams(params))
+	case GetWalletSupplyRequest:
+		return GetWalletSupply(params["id"].(string))
 	default:
 		return nil, ErrorInvalidRequest
 	}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	"github.com/mlayerprotocol/go-mlayer/internal/service"
+)
+
+type SimulationError struct {
+	Code    apperror.ErrorCode `json:"code,omitempty"`
+	Message string             `json:"msg"`
+}
+
+type SimulationResult struct {
+	Valid             bool                `json:"valid"`
+	ApprovalsRequired bool                `json:"apprvReq,omitempty"`
+	Event             *entities.Event     `json:"e,omitempty"`
+	PreviousEvent     *entities.EventPath `json:"preE,omitempty"`
+	AuthEvent         *entities.EventPath `json:"authE,omitempty"`
+	Errors            []SimulationError   `json:"errors"`
+}
+
+/*
+SimulateEvent runs the payload through the same validation as CreateEvent without saving or broadcasting anything.
+The returned event is the one CreateEvent would build, except that it is not signed by the validator
+*/
+func SimulateEvent(payload entities.ClientPayload, ctx *context.Context) (*SimulationResult, error) {
+	result := &SimulationResult{Errors: []SimulationError{}}
+	if err := parsePayloadData(&payload); err != nil {
+		return nil, err
+	}
+	model, err := prepareEvent(payload, ctx, true)
+	if err == service.ErrApprovalsRequired {
+		result.Valid = true
+		result.ApprovalsRequired = true
+		return result, nil
+	}
+	if err != nil {
+		code, message := apperror.Parse(err)
+		result.Errors = append(result.Errors, SimulationError{Code: code, Message: message})
+		return result, nil
+	}
+	if event, ok := model.(entities.Event); ok {
+		result.Event = &event
+		if event.PreviousEvent.ID != "" {
+			result.PreviousEvent = &event.PreviousEvent
+		}
+		if event.AuthEvent.ID != "" {
+			result.AuthEvent = &event.AuthEvent
+		}
+	}
+	result.Valid = true
+	return result, nil
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+	"github.com/mlayerprotocol/go-mlayer/internal/ds/stores"
+	"github.com/mlayerprotocol/go-mlayer/internal/service"
+)
+
+func simulatedPayload(t *testing.T) *entities.ClientPayload {
+	withClientStores(t)
+	subnet := entities.Subnet{ID: "sim", RateLimits: &entities.SubnetRateLimits{Agent: &entities.RateLimit{Rate: 1, Burst: 1}}}
+	ctx := context.Background()
+	if err := stores.StateStore.Put(ctx, datastore.NewKey(dsquery.EntityKey(entities.SubnetModel, subnet.ID)), []byte("ev-sim")); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.StateStore.Put(ctx, datastore.NewKey(dsquery.EntityDataKey(entities.SubnetModel, "ev-sim")), subnet.MsgPack()); err != nil {
+		t.Fatal(err)
+	}
+	return &entities.ClientPayload{
+		Subnet: "sim", Account: deviceAccount, Agent: "did:0x1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d",
+		EventType: uint16(constants.SendMessageEvent), Nonce: 1,
+	}
+}
+
+func TestSimulationTakesNoRateLimitTokens(t *testing.T) {
+	payload := simulatedPayload(t)
+	for i := 0; i < 3; i++ {
+		if err := service.PeekRateLimit(payload); err != nil {
+			t.Fatalf("simulation %d was throttled: %v", i, err)
+		}
+	}
+	if err := service.CheckRateLimit(payload); err != nil {
+		t.Fatalf("expected the burst to be untouched by simulations, got %v", err)
+	}
+	if err := service.PeekRateLimit(payload); err == nil {
+		t.Fatal("expected a simulation over the limit to report it")
+	}
+}
+
+func TestSimulationReservesNoNonce(t *testing.T) {
+	payload := simulatedPayload(t)
+	for i := 0; i < 3; i++ {
+		if err := service.ValidatePayloadNonce(payload); err != nil {
+			t.Fatalf("simulation %d rejected the nonce: %v", i, err)
+		}
+	}
+	if next, _ := service.NextAgentNonce(payload.Account, payload.Agent); next != 1 {
+		t.Fatalf("expected simulations to leave the next nonce at 1, got %d", next)
+	}
+	if err := service.ReservePayloadNonce(payload); err != nil {
+		t.Fatalf("expected the simulated nonce to still be usable: %v", err)
+	}
+	defer service.ReleasePayloadNonce(payload)
+	if next, _ := service.NextAgentNonce(payload.Account, payload.Agent); next != 2 {
+		t.Fatalf("expected the submitted payload to take the nonce, got next %d", next)
+	}
+}
+
+func TestSimulationTakesNoMessageIndex(t *testing.T) {
+	event := &entities.Event{Validator: "sim-validator", Payload: entities.ClientPayload{Data: entities.Message{Topic: "sim-topic"}}}
+	key := event.VectorKey("sim-topic")
+	messageVectors.Store(key, &PaddedInt64{value: 5})
+	defer messageVectors.Delete(key)
+	for i := 0; i < 2; i++ {
+		if index, err := nextMessageIndex(event, true); err != nil || index != 6 {
+			t.Fatalf("simulation %d: expected index 6, got %d %v", i, index, err)
+		}
+	}
+	if index, _ := nextMessageIndex(event, false); index != 6 {
+		t.Fatalf("expected the submitted message to take index 6, got %d", index)
+	}
+	if index, _ := nextMessageIndex(event, true); index != 7 {
+		t.Fatalf("expected the next simulation to see index 7, got %d", index)
+	}
+}
@@ -6,11 +6,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/mlayerprotocol/go-mlayer/common/apperror"
 	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/common/utils"
 	"github.com/mlayerprotocol/go-mlayer/configs"
 	"github.com/mlayerprotocol/go-mlayer/entities"
 	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
@@ -147,6 +150,121 @@ func GetSubscribedSubnets(item models.SubnetState) (*[]models.SubnetState, error
 	return &SubnetStates, nil
 }
 
+// SubnetQueryFromParams reads the cat, q, sort, page and perPage request params
+func SubnetQueryFromParams(params map[string]interface{}) (filter dsquery.SubnetQuery, sortByActivity bool, limits *dsquery.QueryLimit, err error) {
+	if cat, ok := params["cat"]; ok && fmt.Sprint(cat) != "" {
+		c, err := strconv.Atoi(fmt.Sprint(cat))
+		if err != nil {
+			return filter, false, nil, apperror.BadRequest("Invalid category")
+		}
+		category := constants.SubnetCategory(c)
+		filter.Category = &category
+	}
+	if q, ok := params["q"]; ok {
+		filter.Keyword = strings.TrimSpace(fmt.Sprint(q))
+	}
+	sortByActivity = fmt.Sprint(params["sort"]) == "activity"
+	return filter, sortByActivity, QueryLimitFromParams(params), nil
+}
+
+// QueryLimitFromParams reads the page and perPage request params
+func QueryLimitFromParams(params map[string]interface{}) *dsquery.QueryLimit {
+	limits := &dsquery.QueryLimit{Limit: dsquery.DefaultQueryLimit.Limit}
+	if perPage, ok := params["perPage"]; ok {
+		if n, err := strconv.Atoi(fmt.Sprint(perPage)); err == nil && n > 0 {
+			limits.Limit = n
+		}
+	}
+	if page, ok := params["page"]; ok {
+		if n, err := strconv.Atoi(fmt.Sprint(page)); err == nil && n > 1 {
+			limits.Offset = (n - 1) * limits.Limit
+		}
+	}
+	return limits
+}
+
+type SubnetListing struct {
+	models.SubnetState
+	EventCount uint64 `json:"evC"`
+}
+
+/*
+Discover public subnets by category and ref/meta keyword.
+When sortByActivity is set, subnets with the most events come first
+*/
+func DiscoverSubnets(filter dsquery.SubnetQuery, sortByActivity bool, limits *dsquery.QueryLimit) (*[]SubnetListing, error) {
+	listings := []SubnetListing{}
+	if limits == nil {
+		limits = dsquery.DefaultQueryLimit
+	}
+	filter.Public = true
+	findLimits := limits
+	if sortByActivity {
+		// we need every match to rank them before paginating
+		findLimits = &dsquery.QueryLimit{}
+	}
+	subnets, err := dsquery.FindSubnets(filter, findLimits)
+	if err != nil {
+		return &listings, err
+	}
+	for _, subnet := range subnets {
+		listing := SubnetListing{SubnetState: models.SubnetState{Subnet: *subnet}}
+		counts, err := dsquery.GetNetworkCounts(&subnet.ID, dsquery.DefaultQueryLimit)
+		if err != nil {
+			logger.Debugf("DiscoverSubnets/GetNetworkCounts: %v", err)
+		}
+		for _, c := range counts {
+			if c.Subnet == subnet.ID {
+				listing.EventCount = utils.SafePointerValue(c.Count, 0)
+			}
+		}
+		listings = append(listings, listing)
+	}
+	if !sortByActivity {
+		return &listings, nil
+	}
+	sort.SliceStable(listings, func(i, j int) bool {
+		return listings[i].EventCount > listings[j].EventCount
+	})
+	if limits.Offset >= len(listings) {
+		return &[]SubnetListing{}, nil
+	}
+	listings = listings[limits.Offset:]
+	if limits.Limit > 0 && len(listings) > limits.Limit {
+		listings = listings[:limits.Limit]
+	}
+	return &listings, nil
+}
+
+func GetSubnetBudget(id string, ctx *context.Context) (*service.SubnetBudget, error) {
+	if _, err := dsquery.GetSubnetStateById(id); err != nil {
+		if dsquery.IsErrorNotFound(err) {
+			return nil, apperror.NotFound("Subnet not found")
+		}
+		return nil, err
+	}
+	return service.GetSubnetBudget(ctx, id)
+}
+
+type SubnetRateLimitStats struct {
+	Limits *entities.SubnetRateLimits `json:"limits"`
+	Stats  []service.RateLimitStat     `json:"stats"`
+}
+
+func GetSubnetRateLimitStats(id string) (*SubnetRateLimitStats, error) {
+	subnet, err := dsquery.GetSubnetStateById(id)
+	if err != nil {
+		if dsquery.IsErrorNotFound(err) {
+			return nil, apperror.NotFound("Subnet not found")
+		}
+		return nil, err
+	}
+	return &SubnetRateLimitStats{
+		Limits: subnet.RateLimits,
+		Stats:  service.GetRateLimitStats(id),
+	}, nil
+}
+
 // func GetSubnetEvents() (*[]models.SubnetEvent, error) {
 // 	var SubnetEvents []models.SubnetEvent
 
@@ -195,7 +313,8 @@ func ValidateSubnetPayload(payload entities.ClientPayload, authState *models.Aut
 	payload.Data = payloadData
 
 
-	if uint64(payloadData.Timestamp) == 0 || uint64(payloadData.Timestamp) > uint64(time.Now().UnixMilli())+15000 || uint64(payloadData.Timestamp) < uint64(time.Now().UnixMilli())-15000 {
+	// proposals were checked for freshness when first submitted
+	if (uint64(payloadData.Timestamp) == 0 || uint64(payloadData.Timestamp) > uint64(time.Now().UnixMilli())+15000 || uint64(payloadData.Timestamp) < uint64(time.Now().UnixMilli())-15000) && !service.IsPendingProposal(&payload, payloadData.Timestamp) {
 		return nil, nil, apperror.BadRequest("Invalid event timestamp")
 	}
 	cfg, _ := (*ctx).Value(constants.ConfigKey).(*configs.MainConfiguration)
@@ -215,7 +334,7 @@ func ValidateSubnetPayload(payload entities.ClientPayload, authState *models.Aut
 		}
 		// var found []models.SubnetState
 		// query.GetMany(&models.SubnetState{Subnet: entities.Subnet{Ref: payloadData.Ref}}, &found, nil)
-		refExists, err := dsquery.RefExists(entities.SubnetModel, payloadData.Ref, "")
+		refExists, err := dsquery.RefExists(entities.SubnetModel, payloadData.Ref, "", nil)
 		logger.Debugf("DATATATAA: %v", refExists)
 		if err != nil {
 			return nil, nil, err
@@ -229,7 +348,7 @@ func ValidateSubnetPayload(payload entities.ClientPayload, authState *models.Aut
 		// logger.Debug("FOUNDDDDD", found, payloadData.Ref)
 
 	}
-	if payload.EventType == uint16(constants.UpdateSubnetEvent) {
+	if payload.EventType != uint16(constants.CreateSubnetEvent) {
 		if payloadData.ID == "" {
 			return nil, nil, apperror.BadRequest("Subnet ID must be provided")
 		}
@@ -239,7 +358,8 @@ func ValidateSubnetPayload(payload entities.ClientPayload, authState *models.Aut
 	// generate associations
 	if currentState != nil {
 		//logger.Debugf("SUBNETINFO %v, %s, %s", strings.EqualFold(currentState.Account.ToString(), payloadData.Account.ToString()), currentState.Account.ToString(), payloadData.Account.ToString())
-		if !strings.EqualFold(currentState.Account.ToString(), payloadData.Account.ToString()) {
+		// the new owner signs the acceptance, which was checked against the pending owner
+		if payload.EventType != uint16(constants.AcceptSubnetOwnershipEvent) && !strings.EqualFold(currentState.Account.ToString(), payloadData.Account.ToString()) {
 			return nil, nil, apperror.BadRequest("subnet account do not match")
 		}
 		assocPrevEvent = &currentState.Event
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+	"github.com/mlayerprotocol/go-mlayer/internal/service"
+)
+
+// serializes the read-modify-write of stored proposals and their approvals
+var proposalMutex sync.Mutex
+
+// proposals whose event is being created, so that concurrent final approvals submit it once
+var finalizingProposals = map[string]bool{}
+
+/*
+Store a sensitive event as a proposal until the subnets admins approve it.
+Resubmitting the same payload merges its approvals into the existing proposal
+*/
+func ProposeSubnetEvent(payload entities.ClientPayload, cfg *configs.MainConfiguration) (*entities.SubnetProposal, error) {
+	subnet, err := service.ProposalSubnet(&payload)
+	if err != nil {
+		return nil, err
+	}
+	if subnet == nil {
+		return nil, apperror.BadRequest("Event does not require admin approval")
+	}
+	id, err := entities.ProposalId(payload)
+	if err != nil {
+		return nil, err
+	}
+	proposalMutex.Lock()
+	defer proposalMutex.Unlock()
+	proposal, err := dsquery.GetSubnetProposal(subnet.ID, id)
+	if err != nil && !dsquery.IsErrorNotFound(err) {
+		return nil, err
+	}
+	if proposal != nil && proposal.Status != entities.PendingProposal {
+		return nil, apperror.BadRequest("Proposal already finalized")
+	}
+	if proposal != nil && proposal.IsExpired() {
+		return nil, apperror.BadRequest("Proposal expired")
+	}
+	approvals := payload.Approvals
+	if proposal == nil {
+		payload.Approvals = nil
+		proposal = &entities.SubnetProposal{
+			ID:        id,
+			Subnet:    subnet.ID,
+			Payload:   payload,
+			Threshold: subnet.AdminThreshold,
+			Status:    entities.PendingProposal,
+			Timestamp: uint64(time.Now().UnixMilli()),
+		}
+	}
+	for _, approval := range approvals {
+		if proposal.IsApprovedBy(approval.Admin) || service.VerifyProposalApproval(subnet, id, approval, cfg.ChainId) != nil {
+			continue
+		}
+		proposal.Payload.Approvals = append(proposal.Payload.Approvals, approval)
+	}
+	if err := dsquery.SaveSubnetProposal(proposal); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+func GetSubnetProposals(subnet string) ([]*entities.SubnetProposal, error) {
+	proposals, err := dsquery.GetSubnetProposals(subnet)
+	if err != nil {
+		return nil, err
+	}
+	return proposals, nil
+}
+
+/*
+Add an admins approval to a pending proposal.
+Once the threshold is met the event is created and broadcast like any other
+*/
+func (p *ClientRequestProcessor) SignSubnetProposal(subnetId string, id string, approval entities.ProposalApproval) (*entities.SubnetProposal, error) {
+	proposal, subnet, err := addProposalApproval(subnetId, id, approval, p.Cfg.ChainId)
+	if err != nil {
+		return nil, err
+	}
+	// approvals of admins that were since removed no longer count
+	approved, err := service.CountProposalApprovals(&proposal.Payload, subnet, p.Cfg.ChainId)
+	if err != nil {
+		return proposal, err
+	}
+	if approved < subnet.AdminThreshold {
+		return proposal, nil
+	}
+	claimed, err := claimProposal(subnetId, id)
+	if err != nil || !claimed {
+		// another approval is already submitting the event
+		return proposal, err
+	}
+	defer releaseProposal(subnetId, id)
+	var request RequestType = WriteSubnetRequest
+	if entities.GetModelTypeFromEventType(constants.EventType(proposal.Payload.EventType)) == entities.AuthModel {
+		request = WriteAuthorizationRequest
+	}
+	result, err := p.Process(request, nil, proposal.Payload)
+	if err != nil {
+		return proposal, err
+	}
+	event, ok := result.(entities.Event)
+	if !ok {
+		return proposal, apperror.Forbidden("Proposal approvals no longer meet the subnets threshold")
+	}
+	proposalMutex.Lock()
+	defer proposalMutex.Unlock()
+	// keep the approvals added while the event was created
+	if proposal, err = dsquery.GetSubnetProposal(subnetId, id); err != nil {
+		return nil, err
+	}
+	proposal.Status = entities.FinalizedProposal
+	proposal.Event = event.ID
+	if err := dsquery.SaveSubnetProposal(proposal); err != nil {
+		return proposal, err
+	}
+	return proposal, nil
+}
+
+/*
+claimProposal reserves a pending proposal for the approval that submits its event.
+It returns false while another approval holds the claim or once the proposal was finalized.
+The claim is released after the proposal is finalized, so a later approval never submits the event again
+*/
+func claimProposal(subnetId string, id string) (bool, error) {
+	proposalMutex.Lock()
+	defer proposalMutex.Unlock()
+	key := subnetId + "/" + id
+	if finalizingProposals[key] {
+		return false, nil
+	}
+	proposal, err := dsquery.GetSubnetProposal(subnetId, id)
+	if err != nil {
+		return false, err
+	}
+	if proposal.Status != entities.PendingProposal {
+		return false, nil
+	}
+	finalizingProposals[key] = true
+	return true, nil
+}
+
+func releaseProposal(subnetId string, id string) {
+	proposalMutex.Lock()
+	defer proposalMutex.Unlock()
+	delete(finalizingProposals, subnetId+"/"+id)
+}
+
+// addProposalApproval verifies and stores an admins approval of a pending proposal
+func addProposalApproval(subnetId string, id string, approval entities.ProposalApproval, chainId configs.ChainId) (*entities.SubnetProposal, *entities.Subnet, error) {
+	proposalMutex.Lock()
+	defer proposalMutex.Unlock()
+	proposal, err := dsquery.GetSubnetProposal(subnetId, id)
+	if err != nil {
+		if dsquery.IsErrorNotFound(err) {
+			return nil, nil, apperror.NotFound("Proposal not found")
+		}
+		return nil, nil, err
+	}
+	if proposal.Status != entities.PendingProposal {
+		return nil, nil, apperror.BadRequest("Proposal already finalized")
+	}
+	if proposal.IsExpired() {
+		return nil, nil, apperror.BadRequest("Proposal expired")
+	}
+	subnet, err := dsquery.GetSubnetStateById(subnetId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if proposal.IsApprovedBy(approval.Admin) {
+		return nil, nil, apperror.BadRequest("Admin already approved this proposal")
+	}
+	if err := service.VerifyProposalApproval(subnet, id, approval, chainId); err != nil {
+		return nil, nil, err
+	}
+	if approval.Timestamp == 0 {
+		approval.Timestamp = uint64(time.Now().UnixMilli())
+	}
+	proposal.Payload.Approvals = append(proposal.Payload.Approvals, approval)
+	if err := dsquery.SaveSubnetProposal(proposal); err != nil {
+		return nil, nil, err
+	}
+	return proposal, subnet, nil
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+)
+
+func TestFinalApprovalsClaimTheProposalOnce(t *testing.T) {
+	withClientStores(t)
+	proposal := &entities.SubnetProposal{ID: "p1", Subnet: "s1", Status: entities.PendingProposal}
+	if err := dsquery.SaveSubnetProposal(proposal); err != nil {
+		t.Fatal(err)
+	}
+	var claims atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			claimed, err := claimProposal("s1", "p1")
+			if err != nil {
+				t.Error(err)
+			}
+			if claimed {
+				claims.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if claims.Load() != 1 {
+		t.Fatalf("expected one approval to submit the event, got %d", claims.Load())
+	}
+	// a failed submission releases the claim for the next approval
+	releaseProposal("s1", "p1")
+	if claimed, _ := claimProposal("s1", "p1"); !claimed {
+		t.Fatal("expected the released proposal to be claimable")
+	}
+	proposal.Status = entities.FinalizedProposal
+	if err := dsquery.SaveSubnetProposal(proposal); err != nil {
+		t.Fatal(err)
+	}
+	releaseProposal("s1", "p1")
+	if claimed, _ := claimProposal("s1", "p1"); claimed {
+		t.Fatal("expected a finalized proposal not to be submitted again")
+	}
+}
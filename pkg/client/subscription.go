@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/ipfs/go-datastore"
 	"github.com/mlayerprotocol/go-mlayer/common/apperror"
 	"github.com/mlayerprotocol/go-mlayer/common/constants"
 	"github.com/mlayerprotocol/go-mlayer/configs"
@@ -106,7 +107,7 @@ func GetAccountSubscriptionsV2(payload entities.Subscription) (*[]models.Subscri
 
 // }
 
-func ValidateSubscriptionPayload(payload entities.ClientPayload, authState *models.AuthorizationState, cfg *configs.MainConfiguration) (
+func ValidateSubscriptionPayload(payload entities.ClientPayload, authState *models.AuthorizationState, cfg *configs.MainConfiguration, txn *datastore.Txn) (
 	assocPrevEvent *entities.EventPath,
 	assocAuthEvent *entities.EventPath,
 	err error,
@@ -130,7 +131,7 @@ func ValidateSubscriptionPayload(payload entities.ClientPayload, authState *mode
 	// query.GetOne(models.TopicState{
 	// 	Topic: entities.Topic{ID: payloadData.Topic, Subnet: payload.Subnet},
 	// }, &topicData)
-	_topic, err := dsquery.GetTopicById(payloadData.Topic)
+	_topic, err := dsquery.GetTopicByIdTxn(payloadData.Topic, txn)
 	
 	if dsquery.IsErrorNotFound(err) || _topic == nil {
 		logger.Infof("ValidatingTopic 2... %v", err)
@@ -142,7 +143,7 @@ func ValidateSubscriptionPayload(payload entities.ClientPayload, authState *mode
 		_topic = state.(*entities.Topic)
 	}
 
-	currentState, err := service.ValidateSubscriptionData(&payload, _topic)
+	currentState, err := service.ValidateSubscriptionData(&payload, _topic, txn)
 	logger.Infof("SubscriptionError: %+v", err)
 	if err != nil && (!dsquery.IsErrorNotFound(err) && payload.EventType == uint16(constants.SubscribeTopicEvent)) {
 		return nil, nil, err
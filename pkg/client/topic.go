@@ -7,6 +7,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/ipfs/go-datastore"
 	"github.com/mlayerprotocol/go-mlayer/common/apperror"
 	"github.com/mlayerprotocol/go-mlayer/common/constants"
 	"github.com/mlayerprotocol/go-mlayer/entities"
@@ -68,7 +69,7 @@ Validate and Process the topic request
 //			go service.HandleNewPubSubTopicEvent(event, ctx)
 //		}
 //	}
-func ValidateTopicPayload(payload entities.ClientPayload, authState *models.AuthorizationState) (assocPrevEvent *entities.EventPath, assocAuthEvent *entities.EventPath, err error) {
+func ValidateTopicPayload(payload entities.ClientPayload, authState *models.AuthorizationState, txn *datastore.Txn) (assocPrevEvent *entities.EventPath, assocAuthEvent *entities.EventPath, err error) {
 
 	payloadData := entities.Topic{}
 	d, _ := json.Marshal(payload.Data)
@@ -84,7 +85,7 @@ func ValidateTopicPayload(payload entities.ClientPayload, authState *models.Auth
 		// topic, _ := query.GetTopic(models.TopicState{
 		// 	Topic: entities.Topic{Ref: payloadData.Ref, Subnet: payloadData.Subnet},
 		// })
-		refExists, err := dsquery.RefExists(entities.TopicModel, payloadData.Ref, payload.Subnet)
+		refExists, err := dsquery.RefExists(entities.TopicModel, payloadData.Ref, payload.Subnet, txn)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -103,10 +104,17 @@ func ValidateTopicPayload(payload entities.ClientPayload, authState *models.Auth
 
 	}
 
-	currentState, err := service.ValidateTopicData(&payloadData, authState)
+	currentState, err := service.ValidateTopicData(&payloadData, authState, txn)
 	if err != nil {
 		return nil, nil, err
 	}
+	var currentTopic *entities.Topic
+	if currentState != nil {
+		currentTopic = &currentState.Topic
+	}
+	if err := service.ValidateTopicOwnershipTransfer(&payload, &payloadData, currentTopic); err != nil {
+		return nil, nil, err
+	}
 
 	// generate associations
 	if currentState != nil {
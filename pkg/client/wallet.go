@@ -7,8 +7,10 @@ import (
 	"errors"
 	"time"
 
+	"github.com/ipfs/go-datastore"
 	"github.com/mlayerprotocol/go-mlayer/common/constants"
 	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
 	"github.com/mlayerprotocol/go-mlayer/internal/service"
 	"github.com/mlayerprotocol/go-mlayer/internal/sql/models"
 	query "github.com/mlayerprotocol/go-mlayer/internal/sql/query"
@@ -146,7 +148,7 @@ func GetWalletEvents() (*[]models.WalletEvent, error) {
 //			go service.HandleNewPubSubWalletEvent(event, ctx)
 //		}
 //	}
-func ValidateWalletPayload(payload entities.ClientPayload, authState *models.AuthorizationState) (assocPrevEvent *entities.EventPath, assocAuthEvent *entities.EventPath, err error) {
+func ValidateWalletPayload(payload entities.ClientPayload, authState *models.AuthorizationState, txn *datastore.Txn) (assocPrevEvent *entities.EventPath, assocAuthEvent *entities.EventPath, err error) {
 
 	payloadData := entities.Wallet{}
 	d, _ := json.Marshal(payload.Data)
@@ -164,7 +166,7 @@ func ValidateWalletPayload(payload entities.ClientPayload, authState *models.Aut
 
 	}
 
-	currentState, err := service.ValidateWalletData(&payloadData)
+	currentState, err := service.ValidateWalletData(&payloadData, txn)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -179,3 +181,52 @@ func ValidateWalletPayload(payload entities.ClientPayload, authState *models.Aut
 	}
 	return assocPrevEvent, assocAuthEvent, nil
 }
+
+func ValidateTokenPayload(payload entities.ClientPayload, authState *models.AuthorizationState, txn *datastore.Txn) (assocPrevEvent *entities.EventPath, assocAuthEvent *entities.EventPath, err error) {
+	payloadData := entities.TokenTransaction{}
+	d, _ := json.Marshal(payload.Data)
+	e := json.Unmarshal(d, &payloadData)
+	if e != nil {
+		logger.Errorf("UnmarshalError %v", e)
+	}
+	if uint64(payloadData.Timestamp) > uint64(time.Now().UnixMilli())+15000 {
+		return nil, nil, errors.New("Token transaction timestamp exceeded")
+	}
+
+	wallet, err := service.ValidateTokenTransaction(&payload, &payloadData, txn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = service.ValidateTokenBalance(&payloadData, txn); err != nil {
+		return nil, nil, err
+	}
+
+	assocPrevEvent = &wallet.Event
+	if authState != nil {
+		assocAuthEvent = &authState.Event
+	}
+	return assocPrevEvent, assocAuthEvent, nil
+}
+
+// GetAccountBalances returns an accounts balance in each wallet of a subnet
+func GetAccountBalances(subnet string, account string) ([]*entities.WalletBalance, error) {
+	if _, err := dsquery.GetSubnetStateById(subnet); err != nil {
+		return nil, err
+	}
+	return dsquery.GetAccountWalletBalances(subnet, entities.DIDString(account))
+}
+
+// GetWalletTransactions returns a page of the transfers an account made or received in a wallet
+func GetWalletTransactions(wallet string, account string, limits *dsquery.QueryLimit) ([]*entities.TokenTransaction, error) {
+	if _, err := dsquery.GetWalletById(wallet); err != nil {
+		return nil, err
+	}
+	return dsquery.GetWalletTransactions(wallet, entities.DIDString(account), limits)
+}
+
+func GetWalletSupply(wallet string) (*entities.WalletSupply, error) {
+	if _, err := dsquery.GetWalletById(wallet); err != nil {
+		return nil, err
+	}
+	return dsquery.GetWalletSupply(wallet, nil)
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
+	"github.com/mlayerprotocol/go-mlayer/configs"
+	"github.com/mlayerprotocol/go-mlayer/entities"
+	dsquery "github.com/mlayerprotocol/go-mlayer/internal/ds/query"
+	"github.com/mlayerprotocol/go-mlayer/internal/service"
+)
+
+type WebhookDeliveries struct {
+	Attempts    []*entities.WebhookDelivery `json:"attempts"`
+	DeadLetters []*entities.WebhookDelivery `json:"deadLetters"`
+}
+
+func RegisterWebhook(webhook entities.Webhook, ctx *context.Context) (*entities.Webhook, error) {
+	cfg, _ := (*ctx).Value(constants.ConfigKey).(*configs.MainConfiguration)
+	return service.RegisterWebhook(&webhook, cfg)
+}
+
+func GetSubnetWebhooks(subnet string) ([]*entities.Webhook, error) {
+	webhooks, err := dsquery.GetSubnetWebhooks(subnet)
+	if webhooks == nil {
+		webhooks = []*entities.Webhook{}
+	}
+	return webhooks, err
+}
+
+func GetWebhookDeliveries(webhook string, limits *dsquery.QueryLimit) (*WebhookDeliveries, error) {
+	attempts, err := dsquery.GetWebhookDeliveries(webhook, limits)
+	if err != nil {
+		return nil, err
+	}
+	deadLetters, err := dsquery.GetWebhookDeadLetters(webhook, limits)
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookDeliveries{
+		Attempts:    append([]*entities.WebhookDelivery{}, attempts...),
+		DeadLetters: append([]*entities.WebhookDelivery{}, deadLetters...),
+	}, nil
+}
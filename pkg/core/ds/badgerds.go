@@ -807,7 +807,13 @@ func (t *txn) query(q dsq.Query) (dsq.Results, error) {
 		defer it.Close()
 
 		// All iterators must be started by rewinding.
-		it.Rewind()
+		// A reverse iterator rewinds to the prefix itself, which sorts before
+		// every key under it, so seek past the prefix instead.
+		if opt.Reverse && len(opt.Prefix) > 0 {
+			it.Seek(append(append([]byte{}, opt.Prefix...), 0xff))
+		} else {
+			it.Rewind()
+		}
 
 		// skip to the offset
 		for skipped := 0; skipped < q.Offset && it.Valid(); it.Next() {
@@ -0,0 +1,40 @@
+package ds
+
+import (
+	"context"
+	"testing"
+
+	datastore "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+)
+
+func TestQueryPrefixDescending(t *testing.T) {
+	d, err := NewDatastore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	ctx := context.Background()
+	for _, k := range []string{"/a/1", "/a/2", "/a/3", "/b/1"} {
+		if err := d.Put(ctx, datastore.NewKey(k), []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rsl, err := d.Query(ctx, dsq.Query{Prefix: "/a", Orders: []dsq.Order{dsq.OrderByKeyDescending{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := rsl.Rest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/a/3", "/a/2", "/a/1"}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(want))
+	}
+	for i, e := range entries {
+		if e.Key != want[i] {
+			t.Errorf("entry %d: got %s, want %s", i, e.Key, want[i])
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/mlayerprotocol/go-mlayer/common/apperror"
+	"github.com/mlayerprotocol/go-mlayer/common/constants"
 	"github.com/mlayerprotocol/go-mlayer/common/encoder"
 	"github.com/mlayerprotocol/go-mlayer/common/utils"
 	"github.com/mlayerprotocol/go-mlayer/configs"
@@ -58,6 +59,7 @@ const (
 	P2pActionGetState P2pAction = 6
 	P2pActionSyncCycle P2pAction = 7
 	P2pActionGetCert P2pAction = 8
+	P2pActionGetBatchEvent P2pAction = 9
 	
 	
 )
@@ -362,6 +364,57 @@ func GetEvent(config *configs.MainConfiguration, eventPath entities.EventPath, v
 	return event, &data, err
 }
 
+// BatchEventRequest asks the validator that created a client batch for its event at Index
+type BatchEventRequest struct {
+	Batch string `json:"b"`
+	Index uint16 `json:"i"`
+}
+
+func (r *BatchEventRequest) MsgPack() []byte {
+	b, _ := encoder.MsgPackStruct(r)
+	return b
+}
+
+func UnpackBatchEventRequest(b []byte) (BatchEventRequest, error) {
+	var r BatchEventRequest
+	err := encoder.MsgPackUnpackStruct(b, &r)
+	return r, err
+}
+
+// GetBatchEvent requests the event at index of a batch from the validator that created it
+func GetBatchEvent(config *configs.MainConfiguration, validator entities.PublicKeyString, batch string, index uint16) (*entities.Event, error) {
+	request := BatchEventRequest{Batch: batch, Index: index}
+	pl := P2pPayload{Action: P2pActionGetBatchEvent, Data: request.MsgPack(), Id: utils.RandomString(12), ChainId: config.ChainId}
+	pl.config = config
+	var err error
+	address := chain.NetworkInfo.SyncedValidators[string(validator)]
+	if address == nil {
+		address, err = GetNodeAddress(config.Context, string(validator))
+		if err != nil || address == nil {
+			return nil, fmt.Errorf("p2p.GetNodeAddress: %v", err)
+		}
+	}
+	resp, err := (&pl).SendRequestToAddress(pl.config.PrivateKeyEDD, address, DataRequest, string(validator))
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, apperror.Internal("timedout")
+	}
+	data, err := UnpackP2pEventResponse(resp.Data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data.Event) == 0 {
+		return nil, apperror.NotFound("event not found")
+	}
+	generic, err := entities.UnpackEventGeneric(data.Event)
+	if err != nil {
+		return nil, err
+	}
+	return entities.UnpackEvent(data.Event, entities.GetModelTypeFromEventType(constants.EventType(generic.EventType)))
+}
+
 func (p *P2pPayload) Sign (privateKey []byte) (err error) {
 	p.Timestamp = uint64(time.Now().UnixMilli())
 	b, err := p.EncodeBytes();
@@ -1338,6 +1338,21 @@ func GetCycleMessageCost(ctx context.Context, cycle uint64) (*big.Int, error) {
 	}
 }
 
+// GetCachedCycleMessageCost returns the cycles price when it has already been read from the chain, nil otherwise
+func GetCachedCycleMessageCost(ctx context.Context, cycle uint64) (*big.Int, error) {
+	priceByte, err := stores.SystemStore.Get(ctx, datastore.NewKey(fmt.Sprintf("/ml/cost/%d", cycle)))
+	if err != nil {
+		if err == datastore.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(priceByte) == 0 {
+		return nil, nil
+	}
+	return big.NewInt(0).SetBytes(priceByte), nil
+}
+
 func GetAndSaveMessageCostFromChain(ctx *context.Context, cycle uint64) (*big.Int, error) {
 	
 	cfg, _ := (*ctx).Value(constants.ConfigKey).(*configs.MainConfiguration)
@@ -286,6 +286,26 @@ func processP2pPayload(config *configs.MainConfiguration, payload *P2pPayload, m
 			 	response.Data = (&data).MsgPack()
 			// }
 		}
+	case P2pActionGetBatchEvent:
+		request, err := UnpackBatchEventRequest(payload.Data)
+		if err != nil {
+			response.ResponseCode = 500
+			response.Error = "Invalid payload data"
+			break
+		}
+		event, err := dsquery.GetBatchEvent(request.Batch, request.Index)
+		if err != nil {
+			if dsquery.IsErrorNotFound(err) {
+				response.ResponseCode = 404
+				response.Error = "Event not found"
+			} else {
+				response.ResponseCode = 500
+				response.Error = err.Error()
+			}
+			break
+		}
+		data := P2pEventResponse{Event: event.MsgPack()}
+		response.Data = (&data).MsgPack()
 	case P2pActionGetState:
 		logger.Error("ReceivedGetState Request...")
 		ePath, err := entities.UnpackEntityPath(payload.Data)
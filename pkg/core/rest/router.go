@@ -2,14 +2,18 @@ package rest
 
 import (
 	// "errors"
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
 	"time"
 
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mlayerprotocol/go-mlayer/common/apperror"
 	"github.com/mlayerprotocol/go-mlayer/common/constants"
 	"github.com/mlayerprotocol/go-mlayer/common/utils"
 	"github.com/mlayerprotocol/go-mlayer/configs"
@@ -62,6 +66,28 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// LocalOnlyMiddleware rejects requests that do not come from the node's own host.
+// The peer address is used rather than forwarded headers, which any caller can set
+func LocalOnlyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			c.AbortWithStatusJSON(http.StatusForbidden, entities.NewClientResponse(entities.ClientResponse{Error: "This endpoint is only available from the node's host"}))
+			return
+		}
+		c.Next()
+	}
+}
+
+// eventErrorStatus is the status of a rejected event, throttled payloads get 429 so clients know to retry later
+func eventErrorStatus(err error) int {
+	if code, _ := apperror.Parse(err); code == apperror.TooManyRequestsError {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusBadRequest
+}
+
 func (p *RestService) Initialize() *gin.Engine {
 	router := gin.Default()
 	if p.Cfg.LogLevel == "info" || p.Cfg.LogLevel == "debug" {
@@ -121,7 +147,13 @@ func (p *RestService) Initialize() *gin.Engine {
 		var authEntity entities.Authorization
 
 		json.Unmarshal(*b, &authEntity)
-		auths, err := client.GetAuthorizations(&authEntity)
+		var auths *[]models.AuthorizationState
+		var err error
+		if c.Query("history") == "true" {
+			auths, err = client.GetAuthorizationHistory(&authEntity)
+		} else {
+			auths, err = client.GetAuthorizations(&authEntity)
+		}
 
 		if err != nil {
 			logger.Error("router/GetAuthorizations: ", err)
@@ -168,7 +200,7 @@ func (p *RestService) Initialize() *gin.Engine {
 
 		if err != nil {
 			logger.Error(err)
-			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			c.JSON(eventErrorStatus(err), entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
 			return
 		}
 
@@ -197,7 +229,7 @@ func (p *RestService) Initialize() *gin.Engine {
 		logger.Infof("%+v", event)
 		if err != nil {
 			logger.Error(err)
-			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			c.JSON(eventErrorStatus(err), entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
 			return
 		}
 
@@ -365,7 +397,7 @@ func (p *RestService) Initialize() *gin.Engine {
 
 		if err != nil {
 			logger.Error(err)
-			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			c.JSON(eventErrorStatus(err), entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
 			return
 		}
 
@@ -394,7 +426,7 @@ func (p *RestService) Initialize() *gin.Engine {
 
 		if err != nil {
 			logger.Error(err)
-			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			c.JSON(eventErrorStatus(err), entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
 			return
 		}
 
@@ -423,7 +455,7 @@ func (p *RestService) Initialize() *gin.Engine {
 
 		if err != nil {
 			logger.Error(err)
-			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			c.JSON(eventErrorStatus(err), entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
 			return
 		}
 
@@ -454,7 +486,7 @@ func (p *RestService) Initialize() *gin.Engine {
 		event, err := client.CreateEvent(payload, p.Ctx)
 
 		if err != nil {
-			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			c.JSON(eventErrorStatus(err), entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
 			return
 		}
 
@@ -626,6 +658,24 @@ func (p *RestService) Initialize() *gin.Engine {
 
 		json.Unmarshal(*b, &subnetState)
 
+		if subnetState.Account == "" {
+			params := map[string]interface{}{}
+			json.Unmarshal(*b, &params)
+			filter, sortByActivity, limits, err := client.SubnetQueryFromParams(params)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+				return
+			}
+			subnets, err := client.DiscoverSubnets(filter, sortByActivity, limits)
+			if err != nil {
+				logger.Error(err)
+				c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+				return
+			}
+			c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: subnets}))
+			return
+		}
+
 		subnets, err := client.GetSubscribedSubnets(subnetState)
 
 		if err != nil {
@@ -636,6 +686,145 @@ func (p *RestService) Initialize() *gin.Engine {
 		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: subnets}))
 	})
 
+	router.GET("/api/subnets/:id/budget", func(c *gin.Context) {
+		id := c.Param("id")
+		budget, err := client.GetSubnetBudget(id, p.Ctx)
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: budget}))
+	})
+
+	router.GET("/api/subnets/:id/rate-limits", func(c *gin.Context) {
+		id := c.Param("id")
+		stats, err := client.GetSubnetRateLimitStats(id)
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: stats}))
+	})
+
+	router.GET("/api/subnets/:id/agents/:agent/topics", func(c *gin.Context) {
+		topics, err := client.GetAgentTopics(c.Param("id"), c.Param("agent"))
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: topics}))
+	})
+
+	router.GET("/api/subnets/:id/agents/:agent/chain", func(c *gin.Context) {
+		chain, err := client.GetAgentAuthorizationChain(c.Param("id"), c.Param("agent"))
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: chain}))
+	})
+
+	router.POST("/api/subnets/:id/webhooks", func(c *gin.Context) {
+		var webhook entities.Webhook
+		if err := c.BindJSON(&webhook); err != nil {
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		webhook.Subnet = c.Param("id")
+		wh, err := client.RegisterWebhook(webhook, p.Ctx)
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: wh}))
+	})
+
+	router.GET("/api/subnets/:id/webhooks", func(c *gin.Context) {
+		webhooks, err := client.GetSubnetWebhooks(c.Param("id"))
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: webhooks}))
+	})
+
+	router.GET("/api/webhooks/:id/deliveries", func(c *gin.Context) {
+		deliveries, err := client.GetWebhookDeliveries(c.Param("id"), nil)
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: deliveries}))
+	})
+
+	router.POST("/api/accounts/export", func(c *gin.Context) {
+		var req entities.AccountExportRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		var bundle bytes.Buffer
+		if err := client.ExportAccount(req, p.Ctx, &bundle); err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s-export.jsonl", entities.AddressFromString(string(req.Account)).Addr))
+		c.Data(http.StatusOK, "application/x-ndjson", bundle.Bytes())
+	})
+
+	router.GET("/api/accounts/:account/devices", func(c *gin.Context) {
+		devices, err := client.GetAccountDevices(c.Param("account"))
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: devices}))
+	})
+
+	router.POST("/api/accounts/:account/devices/revoke", func(c *gin.Context) {
+		var payloads []entities.ClientPayload
+		if err := c.BindJSON(&payloads); err != nil {
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		results, err := requestProcessor.RevokeAccountDevices(c.Param("account"), payloads)
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: results}))
+	})
+
+	router.GET("/api/accounts/:account/devices/:agent/nonce", func(c *gin.Context) {
+		nonce, err := client.GetNextNonce(c.Param("account"), c.Param("agent"))
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: nonce}))
+	})
+
 	router.GET("/api/subnets/:id/by-account", func(c *gin.Context) {
 		id := c.Param("id")
 		messages, err := client.GetMessages(id)
@@ -648,6 +837,33 @@ func (p *RestService) Initialize() *gin.Engine {
 		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: messages}))
 	})
 
+	router.GET("/api/subnets/:id/proposals", func(c *gin.Context) {
+		proposals, err := client.GetSubnetProposals(c.Param("id"))
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: proposals}))
+	})
+
+	router.POST("/api/subnets/:id/proposals/:proposal/sign", func(c *gin.Context) {
+		var approval entities.ProposalApproval
+		if err := c.BindJSON(&approval); err != nil {
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		proposal, err := requestProcessor.SignSubnetProposal(c.Param("id"), c.Param("proposal"), approval)
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: proposal}))
+	})
+
 	router.POST("/api/wallets", func(c *gin.Context) {
 		var payload entities.ClientPayload
 		if err := c.BindJSON(&payload); err != nil {
@@ -665,11 +881,160 @@ func (p *RestService) Initialize() *gin.Engine {
 		payload.Data = Wallet
 		event, err := client.CreateEvent(payload, p.Ctx)
 
+		if err != nil {
+			logger.Error(err)
+			c.JSON(eventErrorStatus(err), entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: map[string]any{
+			"event": event,
+		}}))
+	})
+
+	router.GET("/api/subnets/:id/accounts/:account/balances", func(c *gin.Context) {
+		balances, err := client.GetAccountBalances(c.Param("id"), c.Param("account"))
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: balances}))
+	})
+
+	router.GET("/api/wallets/:id/accounts/:account/transactions", func(c *gin.Context) {
+		params := map[string]interface{}{"page": c.Query("page"), "perPage": c.Query("perPage")}
+		transactions, err := client.GetWalletTransactions(c.Param("id"), c.Param("account"), client.QueryLimitFromParams(params))
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: transactions}))
+	})
+
+	router.GET("/api/fees/quote", func(c *gin.Context) {
+		params := map[string]interface{}{}
+		for _, key := range []string{"events", "history"} {
+			if v, ok := c.GetQuery(key); ok {
+				params[key] = v
+			}
+		}
+		quote, err := client.GetFeeQuote(p.Ctx, params)
+
 		if err != nil {
 			logger.Error(err)
 			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
 			return
 		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: quote}))
+	})
+
+	router.GET("/api/:model/:id/conflicts", func(c *gin.Context) {
+		params := map[string]interface{}{"page": c.Query("page"), "perPage": c.Query("perPage")}
+		conflicts, err := client.GetEntityConflicts(c.Param("model"), c.Param("id"), client.QueryLimitFromParams(params))
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: conflicts}))
+	})
+
+	router.GET("/api/:model/:id/history", func(c *gin.Context) {
+		params := map[string]interface{}{"page": c.Query("page"), "perPage": c.Query("perPage")}
+		history, err := client.GetEntityHistory(c.Param("model"), c.Param("id"), client.QueryLimitFromParams(params))
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: history}))
+	})
+
+	router.GET("/api/wallets/:id/supply", func(c *gin.Context) {
+		supply, err := client.GetWalletSupply(c.Param("id"))
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: supply}))
+	})
+
+	router.POST("/api/db/verify", LocalOnlyMiddleware(), func(c *gin.Context) {
+		var req client.VerifyRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		report, err := client.VerifyEventStore(p.Cfg, req)
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: report}))
+	})
+
+	router.POST("/api/simulate", func(c *gin.Context) {
+		var payload entities.ClientPayload
+		if err := c.BindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		result, err := client.SimulateEvent(payload, p.Ctx)
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: result}))
+	})
+
+	router.POST("/api/batch", func(c *gin.Context) {
+		var batch entities.ClientPayloadBatch
+		if err := c.BindJSON(&batch); err != nil {
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		result, err := client.CreateEventBatch(batch, p.Ctx)
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		c.JSON(utils.IfThenElse(result.Applied, http.StatusOK, http.StatusBadRequest), entities.NewClientResponse(entities.ClientResponse{Data: result}))
+	})
+
+	router.POST("/api/wallets/transactions", func(c *gin.Context) {
+		var payload entities.ClientPayload
+		if err := c.BindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
+		transaction := entities.TokenTransaction{}
+		d, _ := json.Marshal(payload.Data)
+		if e := json.Unmarshal(d, &transaction); e != nil {
+			c.JSON(http.StatusBadRequest, entities.NewClientResponse(entities.ClientResponse{Error: e.Error()}))
+			return
+		}
+		payload.Data = transaction
+		event, err := client.CreateEvent(payload, p.Ctx)
+
+		if err != nil {
+			logger.Error(err)
+			c.JSON(eventErrorStatus(err), entities.NewClientResponse(entities.ClientResponse{Error: err.Error()}))
+			return
+		}
 
 		c.JSON(http.StatusOK, entities.NewClientResponse(entities.ClientResponse{Data: map[string]any{
 			"event": event,
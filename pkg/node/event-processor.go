@@ -98,6 +98,10 @@ func (ep *EventProcessor) processSubnet(ctx context.Context, subnet string, ch c
 					logger.Error(fmt.Errorf("not signed by a validator"))
 					return
 				}
+				if err := service.CheckPeerRateLimit(event); err != nil {
+					logger.Errorf("processSubnet/CheckPeerRateLimit: validator %s: %v", event.Validator, err)
+					continue
+				}
 				event.Broadcasted = true
 				service.SaveEvent(modelType, entities.Event{}, event, nil, nil)
 			}
@@ -34,6 +34,7 @@ import (
 	"github.com/mlayerprotocol/go-mlayer/internal/chain"
 	"github.com/mlayerprotocol/go-mlayer/internal/channelpool"
 	"github.com/mlayerprotocol/go-mlayer/internal/crypto"
+	"github.com/mlayerprotocol/go-mlayer/internal/service"
 	"github.com/mlayerprotocol/go-mlayer/pkg/core/ds"
 	p2p "github.com/mlayerprotocol/go-mlayer/pkg/core/p2p"
 	"github.com/mlayerprotocol/go-mlayer/pkg/core/rest"
@@ -81,6 +82,21 @@ func Start(mainCtx *context.Context) {
 	for _, store := range stores {
 		defer store.Close()
 	}
+	if err := dsquery.IndexSubnets(); err != nil {
+		logger.Errorf("IndexSubnets: %v", err)
+	}
+	if err := dsquery.MigrateHistoricStates(); err != nil {
+		logger.Errorf("MigrateHistoricStates: %v", err)
+	}
+	if err := dsquery.RekeyExpiryQueue(); err != nil {
+		logger.Errorf("RekeyExpiryQueue: %v", err)
+	}
+	if err := dsquery.RekeyAgentEvents(); err != nil {
+		logger.Errorf("RekeyAgentEvents: %v", err)
+	}
+	if err := dsquery.RekeyWalletLedgers(); err != nil {
+		logger.Errorf("RekeyWalletLedgers: %v", err)
+	}
 
 	eventCountStore := ds.New(&ctx, string(constants.EventCountStore))
 	defer eventCountStore.Close()
@@ -296,6 +312,36 @@ func Start(mainCtx *context.Context) {
 		
 	}()
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				service.SweepExpiredAuthorizations(&ctx)
+			}
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(service.BatchWaitTimeout)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				service.RefetchPendingBatches(&ctx)
+			}
+		}
+	}()
+
 	// load network params
 	wg.Add(1)
 	go func() {